@@ -2,16 +2,21 @@ package handler
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"log/slog"
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/pmes126/tx-parser-service/internal/store"
+	"github.com/pmes126/tx-parser-service/pkg/bitcoin"
+	"github.com/pmes126/tx-parser-service/pkg/ens"
+	"github.com/pmes126/tx-parser-service/pkg/labels"
 	"github.com/pmes126/tx-parser-service/pkg/parser"
 )
 
@@ -172,6 +177,18 @@ func TestHandler_handleGetTransactionsBadInput(t *testing.T) {
 				codeWant: http.StatusBadRequest,
 			},
 		},
+		{
+			name: "Test handleGetTransactions Wrong Checksum",
+			fields: fields{
+				logger:      slog.New(slog.NewTextHandler(os.Stdout, nil)),
+				httpTimeout: 5,
+			},
+			args: args{
+				w:        httptest.NewRecorder(),
+				r:        httptest.NewRequest(http.MethodGet, fmt.Sprintf("/v1/transactions?address=%s", "0xc0ffee254729296a45a3885639Ac7E10F9d54979"), nil),
+				codeWant: http.StatusBadRequest,
+			},
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -291,3 +308,440 @@ func TestHandler_handleSubscribeBadInput(t *testing.T) {
 		})
 	}
 }
+
+func TestHandler_handleSubscribeContractAndGetLogs(t *testing.T) {
+	type Subscription struct {
+		Contract string   `json:"contract"`
+		Topics   []string `json:"topics"`
+	}
+	contract := "0xa0b86991c6218b36c1d19d4a2e9eb0ce3606eb48"
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+	txParser := parser.NewEthTxParser(store.NewMemTxStore[parser.EthTransaction](), &http.Client{}, logger, 0)
+	h := NewHandler(logger, txParser, 5*time.Second)
+	tests := []struct {
+		name     string
+		sub      Subscription
+		codeWant int
+	}{
+		{
+			name:     "Test subscribe contract",
+			sub:      Subscription{Contract: contract, Topics: []string{parser.TransferEventTopic}},
+			codeWant: http.StatusOK,
+		},
+		{
+			name:     "Test subscribe contract invalid topic",
+			sub:      Subscription{Contract: contract, Topics: []string{"0x1234"}},
+			codeWant: http.StatusBadRequest,
+		},
+		{
+			name:     "Test subscribe invalid contract",
+			sub:      Subscription{Contract: "0xa0b86991"},
+			codeWant: http.StatusBadRequest,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reqBody, _ := json.Marshal(tt.sub)
+			rr := httptest.NewRecorder()
+			http.HandlerFunc(h.handleSubscribeAddress).ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/v1/subscribe", bytes.NewBuffer(reqBody)))
+			if rr.Code != tt.codeWant {
+				t.Errorf("Handler.handleSubscribeAddress() = %v, want %v", rr.Code, tt.codeWant)
+			}
+		})
+	}
+
+	txParser.UpdateLogsInStore([]parser.EthLog{
+		{Address: contract, Topics: []string{parser.TransferEventTopic}, TransactionHash: "0x1"},
+	})
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(h.handleGetLogs).ServeHTTP(rr, httptest.NewRequest(http.MethodGet, fmt.Sprintf("/v1/logs?contract=%s&topic0=%s", contract, parser.TransferEventTopic), nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Handler.handleGetLogs() = %v, want %v", rr.Code, http.StatusOK)
+	}
+	var logs []parser.EthLog
+	if err := json.NewDecoder(rr.Body).Decode(&logs); err != nil {
+		t.Fatalf("Handler.handleGetLogs() error = %v", err)
+	}
+	if len(logs) != 1 {
+		t.Errorf("Handler.handleGetLogs() = %v, want %v", len(logs), 1)
+	}
+}
+
+func TestHandler_chainParser(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+	address := "0xc0ffee254729296a45a3885639AC7E10F9d54979"
+	mainnet := parser.NewEthTxParser(store.NewMemTxStore[parser.EthTransaction](), &http.Client{}, logger, 0)
+	polygon := parser.NewEthTxParser(store.NewMemTxStore[parser.EthTransaction](), &http.Client{}, logger, 0)
+	polygon.Subscribe(address)
+	polygon.UpdateTransactionsInStore([]parser.EthTransaction{
+		{Hash: "0x1", From: address, To: "0x456", Value: "100"},
+	})
+	h := NewHandler(logger, mainnet, 5*time.Second,
+		Chain{ID: "1", Name: "ethereum", Parser: mainnet},
+		Chain{ID: "137", Name: "polygon", Parser: polygon},
+	)
+	tests := []struct {
+		name     string
+		chain    string
+		codeWant int
+	}{
+		{name: "Test default chain", chain: "", codeWant: http.StatusNotFound},
+		{name: "Test chain without the address", chain: "1", codeWant: http.StatusNotFound},
+		{name: "Test chain with the address", chain: "137", codeWant: http.StatusOK},
+		{name: "Test unknown chain", chain: "56", codeWant: http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodGet, fmt.Sprintf("/v1/transactions?address=%s&chain=%s", address, tt.chain), nil)
+			Routes(h).ServeHTTP(rr, r)
+			if rr.Code != tt.codeWant {
+				t.Errorf("Handler.handleGetTransactions() = %v, want %v", rr.Code, tt.codeWant)
+			}
+		})
+	}
+
+	rr := httptest.NewRecorder()
+	Routes(h).ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/v1/chains", nil))
+	var chains []Chain
+	if err := json.NewDecoder(rr.Body).Decode(&chains); err != nil || len(chains) != 2 {
+		t.Errorf("Handler.handleGetChains() = %v, %v, want 2 chains", chains, err)
+	}
+}
+
+func TestHandler_checksummedOutput(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+	from := "0xc0ffee254729296a45a3885639AC7E10F9d54979"
+	to := "0x999999cf1046e68e36E1aA2E0E07105eDDD1f08E"
+	txParser := parser.NewEthTxParser(store.NewMemTxStore[parser.EthTransaction](), &http.Client{}, logger, 0)
+	txParser.Subscribe(strings.ToLower(from))
+	txParser.UpdateTransactionsInStore([]parser.EthTransaction{
+		{Hash: "0x1", From: strings.ToLower(from), To: strings.ToLower(to), Value: "100"},
+	})
+	h := NewHandler(logger, txParser, 5*time.Second)
+	rr := httptest.NewRecorder()
+	Routes(h).ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/v1/transactions?address="+from, nil))
+	var txs []parser.EthTransaction
+	if err := json.NewDecoder(rr.Body).Decode(&txs); err != nil || len(txs) != 1 {
+		t.Fatalf("Handler.handleGetTransactions() = %v, %v, want 1 transaction", txs, err)
+	}
+	if txs[0].From != from || txs[0].To != to {
+		t.Errorf("Handler.handleGetTransactions() = %v -> %v, want %v -> %v", txs[0].From, txs[0].To, from, to)
+	}
+}
+
+// fakeResolver resolves the names of a map, recording the watched ones.
+type fakeResolver struct {
+	names   map[string]string
+	watched map[string]string
+}
+
+func (f *fakeResolver) Resolve(ctx context.Context, name string) (string, error) {
+	address, ok := f.names[name]
+	if !ok {
+		return "", ens.ErrNameNotFound
+	}
+	return address, nil
+}
+
+func (f *fakeResolver) Watch(name, address string) {
+	f.watched[name] = address
+}
+
+func TestHandler_resolveAddress(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+	address := "0xc0ffee254729296a45a3885639ac7e10f9d54979"
+	mainnet := parser.NewEthTxParser(store.NewMemTxStore[parser.EthTransaction](), &http.Client{}, logger, 0)
+	polygon := parser.NewEthTxParser(store.NewMemTxStore[parser.EthTransaction](), &http.Client{}, logger, 0)
+	resolver := &fakeResolver{names: map[string]string{"alice.eth": address}, watched: map[string]string{}}
+	h := NewHandler(logger, mainnet, 5*time.Second,
+		Chain{ID: "1", Name: "ethereum", Parser: mainnet, Resolver: resolver},
+		Chain{ID: "137", Name: "polygon", Parser: polygon},
+	)
+	tests := []struct {
+		name     string
+		r        *http.Request
+		codeWant int
+	}{
+		{name: "Test subscribe name", r: httptest.NewRequest(http.MethodPost, "/v1/subscribe", strings.NewReader(`{"address":"alice.eth"}`)), codeWant: http.StatusOK},
+		{name: "Test subscribe unknown name", r: httptest.NewRequest(http.MethodPost, "/v1/subscribe", strings.NewReader(`{"address":"bob.eth"}`)), codeWant: http.StatusNotFound},
+		{name: "Test subscribe name on chain without resolver", r: httptest.NewRequest(http.MethodPost, "/v1/subscribe?chain=137", strings.NewReader(`{"address":"alice.eth"}`)), codeWant: http.StatusBadRequest},
+		{name: "Test transactions of name", r: httptest.NewRequest(http.MethodGet, "/v1/transactions?address=alice.eth", nil), codeWant: http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			Routes(h).ServeHTTP(rr, tt.r)
+			if rr.Code != tt.codeWant {
+				t.Errorf("Handler.resolveAddress() = %v %s, want %v", rr.Code, rr.Body, tt.codeWant)
+			}
+		})
+	}
+	if resolver.watched["alice.eth"] != address {
+		t.Errorf("NameResolver.Watch() = %v, want alice.eth watched", resolver.watched)
+	}
+	mainnet.UpdateTransactionsInStore([]parser.EthTransaction{{Hash: "0x1", From: address, To: "0x456", Value: "100"}})
+	rr := httptest.NewRecorder()
+	Routes(h).ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/v1/transactions?address=alice.eth", nil))
+	if rr.Code != http.StatusOK {
+		t.Errorf("Handler.handleGetTransactions() = %v, want %v", rr.Code, http.StatusOK)
+	}
+}
+
+func TestHandler_handleGetPendingTransactions(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+	address := "0xc0ffee254729296a45a3885639AC7E10F9d54979"
+	txParser := parser.NewEthTxParser(store.NewMemTxStore[parser.EthTransaction](), &http.Client{}, logger, 0)
+	h := NewHandler(logger, txParser, 5*time.Second)
+	tests := []struct {
+		name      string
+		subscribe bool
+		codeWant  int
+	}{
+		{name: "Test untracked address", codeWant: http.StatusNotFound},
+		{name: "Test tracked address", subscribe: true, codeWant: http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.subscribe {
+				txParser.Subscribe(address)
+			}
+			rr := httptest.NewRecorder()
+			Routes(h).ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/v1/pending-transactions?address="+address, nil))
+			if rr.Code != tt.codeWant {
+				t.Errorf("Handler.handleGetPendingTransactions() = %v, want %v", rr.Code, tt.codeWant)
+			}
+		})
+	}
+}
+
+func TestHandler_handleGetBalance(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+	address := "0xc0ffee254729296a45a3885639AC7E10F9d54979"
+	txParser := parser.NewEthTxParser(store.NewMemTxStore[parser.EthTransaction](), &http.Client{}, logger, 0)
+	txParser.Subscribe("0x999999cf1046e68e36E1aA2E0E07105eDDD1f08E")
+	h := NewHandler(logger, txParser, 5*time.Second)
+	tests := []struct {
+		name     string
+		address  string
+		codeWant int
+	}{
+		{name: "Test invalid address", address: "0xc0ffee", codeWant: http.StatusBadRequest},
+		{name: "Test untracked address", address: address, codeWant: http.StatusNotFound},
+		{name: "Test balance not tracked", address: "0x999999cf1046e68e36E1aA2E0E07105eDDD1f08E", codeWant: http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			Routes(h).ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/v1/balances/"+tt.address, nil))
+			if rr.Code != tt.codeWant {
+				t.Errorf("Handler.handleGetBalance() = %v, want %v", rr.Code, tt.codeWant)
+			}
+		})
+	}
+}
+
+func TestHandler_handleGetStats(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+	address := "0xc0ffee254729296a45a3885639AC7E10F9d54979"
+	tracked := "0x999999cf1046e68e36E1aA2E0E07105eDDD1f08E"
+	txParser := parser.NewEthTxParser(store.NewMemTxStore[parser.EthTransaction](), &http.Client{}, logger, 0, parser.WithStats(time.Hour))
+	txParser.Subscribe(tracked)
+	noStats := parser.NewEthTxParser(store.NewMemTxStore[parser.EthTransaction](), &http.Client{}, logger, 0)
+	noStats.Subscribe(tracked)
+	h := NewHandler(logger, txParser, 5*time.Second,
+		Chain{ID: "1", Name: "ethereum", Parser: txParser},
+		Chain{ID: "2", Name: "no-stats", Parser: noStats},
+	)
+	tests := []struct {
+		name     string
+		path     string
+		codeWant int
+	}{
+		{name: "Test invalid address", path: "/v1/addresses/0xc0ffee/stats", codeWant: http.StatusBadRequest},
+		{name: "Test untracked address", path: "/v1/addresses/" + address + "/stats", codeWant: http.StatusNotFound},
+		{name: "Test tracked address", path: "/v1/addresses/" + tracked + "/stats", codeWant: http.StatusOK},
+		{name: "Test stats not tracked", path: "/v1/addresses/" + tracked + "/stats?chain=2", codeWant: http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			Routes(h).ServeHTTP(rr, httptest.NewRequest(http.MethodGet, tt.path, nil))
+			if rr.Code != tt.codeWant {
+				t.Errorf("Handler.handleGetStats() = %v, want %v", rr.Code, tt.codeWant)
+			}
+			if rr.Code != http.StatusOK {
+				return
+			}
+			var stats parser.AddressStats
+			if err := json.NewDecoder(rr.Body).Decode(&stats); err != nil {
+				t.Fatalf("Handler.handleGetStats() error = %v", err)
+			}
+			if stats.Address != tracked || stats.TxCount != 0 || stats.TotalIn != "0" {
+				t.Errorf("Handler.handleGetStats() = %+v, want the empty statistics of %v", stats, tracked)
+			}
+		})
+	}
+}
+
+func TestHandler_handleExportTransactions(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+	from := "0xc0ffee254729296a45a3885639AC7E10F9d54979"
+	to := "0x999999cf1046e68e36E1aA2E0E07105eDDD1f08E"
+	txParser := parser.NewEthTxParser(store.NewMemTxStore[parser.EthTransaction](), &http.Client{}, logger, 0)
+	txParser.Subscribe(from)
+	txParser.Subscribe(to)
+	txParser.UpdateTransactionsInStore([]parser.EthTransaction{
+		{Hash: "0x1", BlockNumber: "0x1", BlockHash: "0xb1", Timestamp: "0x6553f100", From: from, To: "0x0000000000000000000000000000000000000456", Value: "0x64"},
+	})
+	h := NewHandler(logger, txParser, 5*time.Second)
+	tests := []struct {
+		name     string
+		query    string
+		codeWant int
+		want     string
+	}{
+		{
+			name: "Test CSV", query: "address=" + from, codeWant: http.StatusOK,
+			want: "hash,kind,block_number,block_hash,timestamp,fee,side,address,value\n" +
+				"0x1,external,1,0xb1,1700000000,,input," + from + ",100\n" +
+				"0x1,external,1,0xb1,1700000000,,output,0x0000000000000000000000000000000000000456,100\n",
+		},
+		{
+			name: "Test NDJSON", query: "format=ndjson&address=" + from, codeWant: http.StatusOK,
+			want: `{"kind":"external","hash":"0x1","blockHash":"0xb1","blockNumber":1,"inputs":[{"address":"` + from +
+				`","value":"100"}],"outputs":[{"address":"0x0000000000000000000000000000000000000456","value":"100"}],"timestamp":1700000000}` + "\n",
+		},
+		{name: "Test invalid format", query: "format=xlsx&address=" + from, codeWant: http.StatusBadRequest},
+		{name: "Test missing address", query: "format=csv", codeWant: http.StatusBadRequest},
+		{name: "Test untracked address", query: "address=0x0000000000000000000000000000000000000456", codeWant: http.StatusNotFound},
+		{name: "Test no transactions", query: "address=" + to, codeWant: http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			Routes(h).ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/v1/transactions/export?"+tt.query, nil))
+			if rr.Code != tt.codeWant {
+				t.Errorf("Handler.handleExportTransactions() = %v, want %v", rr.Code, tt.codeWant)
+			}
+			if tt.want != "" && rr.Body.String() != tt.want {
+				t.Errorf("Handler.handleExportTransactions() = %v, want %v", rr.Body.String(), tt.want)
+			}
+		})
+	}
+}
+
+func TestHandler_groupTransactions(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+	hot1 := "0xc0ffee254729296a45a3885639ac7e10f9d54979"
+	hot2 := "0x999999cf1046e68e36e1aa2e0e07105eddd1f08e"
+	exchange := "0x0000000000000000000000000000000000000456"
+	txParser := parser.NewEthTxParser(store.NewMemTxStore[parser.EthTransaction](), &http.Client{}, logger, 0)
+	registry := labels.NewRegistry(labels.Entry{Address: exchange, Label: "Exchange"})
+	h := NewHandler(logger, txParser, 5*time.Second, Chain{ID: "1", Name: "ethereum", Parser: txParser, Labels: registry})
+	for _, body := range []string{
+		`{"address":"` + hot1 + `","label":"Hot wallet 1","group":"hot-wallets","tags":["exchange"]}`,
+		`{"address":"` + hot2 + `","label":"Hot wallet 2","group":"hot-wallets"}`,
+	} {
+		rr := httptest.NewRecorder()
+		Routes(h).ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/v1/subscribe", strings.NewReader(body)))
+		if rr.Code != http.StatusOK {
+			t.Fatalf("Handler.handleSubscribeAddress() = %v, want %v", rr.Code, http.StatusOK)
+		}
+	}
+	txParser.UpdateTransactionsInStore([]parser.EthTransaction{
+		{Hash: "0x2", BlockNumber: "0x2", From: exchange, To: hot2, Value: "0x1"},
+		{Hash: "0x1", BlockNumber: "0x1", From: hot1, To: hot2, Value: "0x1"},
+	})
+	tests := []struct {
+		name     string
+		query    string
+		codeWant int
+		want     []string
+	}{
+		{name: "Test group", query: "group=hot-wallets", codeWant: http.StatusOK, want: []string{"0x1", "0x2"}},
+		{name: "Test unknown group", query: "group=cold-wallets", codeWant: http.StatusNotFound},
+		{name: "Test group and address", query: "group=hot-wallets&address=" + hot1, codeWant: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			Routes(h).ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/v1/transactions?"+tt.query, nil))
+			if rr.Code != tt.codeWant {
+				t.Fatalf("Handler.handleGetTransactions() = %v, want %v", rr.Code, tt.codeWant)
+			}
+			if tt.codeWant != http.StatusOK {
+				return
+			}
+			var txs []LabeledTransaction
+			json.NewDecoder(rr.Body).Decode(&txs)
+			var hashes []string
+			for _, tx := range txs {
+				hashes = append(hashes, tx.Hash)
+			}
+			if fmt.Sprint(hashes) != fmt.Sprint(tt.want) {
+				t.Errorf("Handler.handleGetTransactions() = %v, want %v", hashes, tt.want)
+			}
+			if len(txs) == 2 && txs[1].Labels["0x0000000000000000000000000000000000000456"] != "Exchange" {
+				t.Errorf("Handler.handleGetTransactions() labels = %v, want the exchange label", txs[1].Labels)
+			}
+		})
+	}
+
+	rr := httptest.NewRecorder()
+	Routes(h).ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/v1/labels?tag=exchange", nil))
+	var entries []labels.Entry
+	if err := json.NewDecoder(rr.Body).Decode(&entries); err != nil || len(entries) != 1 || entries[0].Label != "Hot wallet 1" {
+		t.Errorf("Handler.handleGetLabels() = %v, %v, want Hot wallet 1", entries, err)
+	}
+}
+
+func TestHandler_sharedLabels(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+	evmAddr := "0xc0ffee254729296a45a3885639ac7e10f9d54979"
+	btcAddr := "bc1qw508d6qejxtdg4y5r3zarvary0c5xw7kv8f3t4"
+	ethParser := parser.NewEthTxParser(store.NewMemTxStore[parser.EthTransaction](), &http.Client{}, logger, 0)
+	btcStore := store.NewMemTxStore[parser.Transaction]()
+	btcParser := parser.NewChainParser(bitcoin.NewAdapter(nil, bitcoin.MainNet), btcStore, logger, 0)
+	btcParser.Subscribe(btcAddr)
+	btcStore.AddTransaction(btcAddr, parser.Transaction{Kind: parser.TxKindExternal, Hash: "tx1", BlockNumber: 1,
+		Outputs: []parser.Transfer{{Address: btcAddr, Value: "100"}}})
+	// a registry shared by the chains holds the addresses of both.
+	registry := labels.NewRegistry(
+		labels.Entry{Address: evmAddr, Label: "Hot wallet ETH", Group: "hot-wallets"},
+		labels.Entry{Address: btcAddr, Label: "Hot wallet BTC", Group: "hot-wallets"},
+	)
+	h := NewHandler(logger, ethParser, 5*time.Second,
+		Chain{ID: "1", Name: "ethereum", Parser: ethParser, Labels: registry},
+		Chain{ID: "bitcoin", Name: "bitcoin", Parser: btcParser, Labels: registry},
+	)
+
+	rr := httptest.NewRecorder()
+	Routes(h).ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/v1/transactions?group=hot-wallets&chain=bitcoin", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Handler.handleGetTransactions() = %v, want %v", rr.Code, http.StatusOK)
+	}
+	var txs []LabeledChainTransaction
+	if err := json.NewDecoder(rr.Body).Decode(&txs); err != nil || len(txs) != 1 || txs[0].Labels[btcAddr] != "Hot wallet BTC" {
+		t.Errorf("Handler.handleGetTransactions() = %+v, %v, want tx1 with its label", txs, err)
+	}
+
+	tests := []struct {
+		name  string
+		chain string
+		want  string
+	}{
+		{name: "Test EVM chain", chain: "1", want: "0xc0ffee254729296a45a3885639AC7E10F9d54979"},
+		{name: "Test bitcoin chain", chain: "bitcoin", want: btcAddr},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			Routes(h).ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/v1/labels?chain="+tt.chain, nil))
+			var entries []labels.Entry
+			if err := json.NewDecoder(rr.Body).Decode(&entries); err != nil || len(entries) != 1 || entries[0].Address != tt.want {
+				t.Errorf("Handler.handleGetLabels() = %v, %v, want %v", entries, err, tt.want)
+			}
+		})
+	}
+}
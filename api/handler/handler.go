@@ -1,32 +1,72 @@
 package handler
 
 import (
-	"encoding/hex"
+	"cmp"
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"log/slog"
 	"net/http"
+	"slices"
 	"time"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
 	"github.com/pmes126/tx-parser-service/internal/store"
+	"github.com/pmes126/tx-parser-service/pkg/address"
+	"github.com/pmes126/tx-parser-service/pkg/ens"
+	"github.com/pmes126/tx-parser-service/pkg/export"
+	"github.com/pmes126/tx-parser-service/pkg/labels"
 	"github.com/pmes126/tx-parser-service/pkg/parser"
 )
 
 // Handler service
 type Handler struct {
-	logger      *slog.Logger
+	logger *slog.Logger
+	// txParser serves the requests without a chain parameter, chains the ones with one.
 	txParser    parser.Parser
+	chains      []Chain
 	httpTimeout time.Duration
 }
 
-// NewHandler creates a new handler
-func NewHandler(logger *slog.Logger, txParser parser.Parser, httpTimeout time.Duration) *Handler {
+// Chain is a chain served by the handler, selected by its id with the chain query parameter.
+type Chain struct {
+	ID     string        `json:"id"`
+	Name   string        `json:"name"`
+	Parser parser.Parser `json:"-"`
+	// Resolver resolves the names given instead of an address, nil when the chain has none.
+	Resolver NameResolver `json:"-"`
+	// Labels holds the labels, tags and groups of the addresses of the chain, nil when it has none.
+	Labels *labels.Registry `json:"-"`
+}
+
+// LabeledTransaction is an EthTransaction with the labels of its addresses.
+type LabeledTransaction struct {
+	parser.EthTransaction
+	// Labels are the labels of the addresses of the transaction that have one, by address.
+	Labels map[string]string `json:"labels,omitempty"`
+}
+
+// LabeledChainTransaction is a chain agnostic Transaction with the labels of its addresses.
+type LabeledChainTransaction struct {
+	parser.Transaction
+	Labels map[string]string `json:"labels,omitempty"`
+}
+
+// NameResolver resolves names, e.g. ENS names, to the addresses of a chain.
+type NameResolver interface {
+	Resolve(ctx context.Context, name string) (string, error)
+	// Watch re-resolves a subscribed name periodically to follow it to its new addresses.
+	Watch(name, address string)
+}
+
+// NewHandler creates a new handler serving txParser by default and the given chains by id
+func NewHandler(logger *slog.Logger, txParser parser.Parser, httpTimeout time.Duration, chains ...Chain) *Handler {
 	return &Handler{
 		logger:      logger,
 		txParser:    txParser,
+		chains:      chains,
 		httpTimeout: httpTimeout,
 	}
 }
@@ -36,37 +76,104 @@ func Routes(h *Handler) *chi.Mux {
 	r := chi.NewRouter()
 	r.Use(middleware.Logger)
 	r.Use(middleware.Recoverer)
-	r.Use(middleware.Timeout(h.httpTimeout))
 	r.Route("/v1", func(r chi.Router) {
-		r.Get("/transactions", h.handleGetTransactions)
-		r.Post("/subscribe", h.handleSubscribeAddress)
+		// an export streams for as long as the store has transactions, the timeout would cancel it
+		// and write its status after the body.
+		r.Get("/transactions/export", h.handleExportTransactions)
+		r.Group(func(r chi.Router) {
+			r.Use(middleware.Timeout(h.httpTimeout))
+			r.Get("/chains", h.handleGetChains)
+			r.Get("/labels", h.handleGetLabels)
+			r.Get("/transactions", h.handleGetTransactions)
+			r.Get("/token-transfers", h.handleGetTokenTransfers)
+			r.Get("/nft-transfers", h.handleGetNFTTransfers)
+			r.Get("/logs", h.handleGetLogs)
+			r.Get("/pending-transactions", h.handleGetPendingTransactions)
+			r.Get("/balances/{address}", h.handleGetBalance)
+			r.Get("/addresses/{address}/stats", h.handleGetStats)
+			r.Post("/subscribe", h.handleSubscribeAddress)
+			r.Route("/admin", func(r chi.Router) {
+				r.Get("/dead-letters", h.handleGetDeadLetters)
+				r.Post("/dead-letters/replay", h.handleReplayDeadLetters)
+				r.Get("/queue", h.handleGetQueueStats)
+			})
+		})
 	})
 	return r
 }
 
 // handleGetTransactions godoc
-// @Summary Get transactions for an address
-// @Description Get transactions for an address
+// @Summary Get transactions for an address or a group of addresses
+// @Description Get transactions for an address, or for all the addresses of a group, with the
+// @Description labels of their addresses
 // @Produce json
-// @Param address query string true "Address to get transactions for"
-// @Success 200 {array} EthTransaction
-// @Failure 400 {string} string "Address parameter missing"
-// @Failure 400 {string} string "Invalid address"
-// @Failure 404 {string} string "Address not tracked"
-// @Failure 404 {string} string "Transactions not found"
+// @Param address query string false "Address or ENS name to get transactions for"
+// @Param group query string false "Group of addresses to get transactions for, instead of an address"
+// @Param kind query string false "Transaction kind, external or internal"
+// @Param chain query string false "Chain id, the default chain when missing"
+// @Success 200 {array} LabeledTransaction "LabeledTransaction on EVM chains, LabeledChainTransaction on the others"
+// @Failure 400 {string} string "Address parameter missing, Address and group parameters are exclusive, Invalid address, Invalid transaction kind or Names not supported by the chain"
+// @Failure 404 {string} string "Address not tracked, Transactions not found, Unknown group, Unknown chain or Name not found"
 // @Failure 500 {string} string
+// @Failure 502 {string} string "Failed to resolve name"
 // @Router /v1/transactions [get]
 func (h *Handler) handleGetTransactions(w http.ResponseWriter, r *http.Request) {
+	p, ok := h.chainParser(w, r)
+	if !ok {
+		return
+	}
 	address := r.URL.Query().Get("address")
-	if address == "" {
-		http.Error(w, "Address parameter missing", http.StatusBadRequest)
+	group := r.URL.Query().Get("group")
+	if address != "" && group != "" {
+		http.Error(w, "Address and group parameters are exclusive", http.StatusBadRequest)
 		return
 	}
-	if !isValidEthAddress(address) {
-		http.Error(w, "Invalid address", http.StatusBadRequest)
+	var addresses []string
+	if group != "" {
+		if registry := h.chainOf(p).Labels; registry != nil {
+			addresses = chainAddresses(p, registry.Group(group))
+		}
+		if len(addresses) == 0 {
+			http.Error(w, "Unknown group", http.StatusNotFound)
+			return
+		}
+	} else {
+		if address == "" {
+			http.Error(w, "Address parameter missing", http.StatusBadRequest)
+			return
+		}
+		address, ok = h.resolveAddress(w, r, p, address)
+		if !ok {
+			return
+		}
+		if !isValidAddress(p, address) {
+			http.Error(w, "Invalid address", http.StatusBadRequest)
+			return
+		}
+		addresses = []string{address}
+	}
+	kind := r.URL.Query().Get("kind")
+	if kind != "" && kind != parser.TxKindExternal && kind != parser.TxKindInternal {
+		http.Error(w, "Invalid transaction kind", http.StatusBadRequest)
 		return
 	}
-	txs, err := h.txParser.GetTransactions(address)
+	// EVM chains keep serving the Ethereum transactions, the others the chain agnostic ones.
+	registry := h.chainOf(p).Labels
+	var txs any
+	var err error
+	if evm, ok := p.(parser.EVMParser); ok {
+		var ethTxs []parser.EthTransaction
+		ethTxs, err = collectTransactions(addresses, group != "", evm.GetTransactions,
+			func(tx parser.EthTransaction) string { return fmt.Sprint(tx.Kind, tx.Hash, tx.TraceAddress) },
+			func(tx parser.EthTransaction) int64 { n, _ := parser.ParseHex(tx.BlockNumber); return n })
+		txs = labelTransactions(registry, filterKind(ethTxs, kind, func(tx parser.EthTransaction) string { return tx.Kind }))
+	} else {
+		var chainTxs []parser.Transaction
+		chainTxs, err = collectTransactions(addresses, group != "", p.ListTransactions,
+			func(tx parser.Transaction) string { return tx.Kind + tx.Hash },
+			func(tx parser.Transaction) int64 { return tx.BlockNumber })
+		txs = labelChainTransactions(registry, filterKind(chainTxs, kind, func(tx parser.Transaction) string { return tx.Kind }))
+	}
 	if err != nil {
 		if errors.Is(err, store.ErrNoTransactions) {
 			http.Error(w, "No transactions found for address", http.StatusNotFound)
@@ -84,20 +191,385 @@ func (h *Handler) handleGetTransactions(w http.ResponseWriter, r *http.Request)
 	w.WriteHeader(http.StatusOK)
 }
 
+// handleExportTransactions godoc
+// @Summary Export the transactions of an address
+// @Description Export the transactions of an address in the chain agnostic model, streamed from
+// @Description the store. The CSV and Parquet exports have a row per input and output of a
+// @Description transaction, the NDJSON export a transaction per line.
+// @Produce text/csv
+// @Produce application/x-ndjson
+// @Produce application/vnd.apache.parquet
+// @Param address query string true "Address or ENS name to export the transactions of"
+// @Param format query string false "Export format, csv (default), ndjson or parquet"
+// @Param chain query string false "Chain id, the default chain when missing"
+// @Success 200 {file} file
+// @Failure 400 {string} string "Address parameter missing, Invalid address, Invalid format or Names not supported by the chain"
+// @Failure 404 {string} string "Address not tracked, Transactions not found, Unknown chain or Name not found"
+// @Failure 500 {string} string
+// @Failure 502 {string} string "Failed to resolve name"
+// @Router /v1/transactions/export [get]
+func (h *Handler) handleExportTransactions(w http.ResponseWriter, r *http.Request) {
+	p, ok := h.chainParser(w, r)
+	if !ok {
+		return
+	}
+	addr := r.URL.Query().Get("address")
+	if addr == "" {
+		http.Error(w, "Address parameter missing", http.StatusBadRequest)
+		return
+	}
+	addr, ok = h.resolveAddress(w, r, p, addr)
+	if !ok {
+		return
+	}
+	if !isValidAddress(p, addr) {
+		http.Error(w, "Invalid address", http.StatusBadRequest)
+		return
+	}
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = export.FormatCSV
+	}
+	ew, err := export.NewWriter(w, format)
+	if err != nil {
+		http.Error(w, "Invalid format", http.StatusBadRequest)
+		return
+	}
+	_, evm := p.(parser.EVMParser)
+	// an export outlives the write timeout of the server.
+	http.NewResponseController(w).SetWriteDeadline(time.Time{})
+	w.Header().Set("Content-Type", export.ContentType(format))
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", addr+"."+format))
+	written := 0
+	err = p.ForEachTransaction(addr, func(tx parser.Transaction) error {
+		written++
+		if evm {
+			checksumTransfers(tx.Inputs)
+			checksumTransfers(tx.Outputs)
+		}
+		return ew.Write(tx)
+	})
+	if err != nil && written == 0 {
+		w.Header().Del("Content-Disposition")
+		if errors.Is(err, store.ErrNoTransactions) {
+			http.Error(w, "No transactions found for address", http.StatusNotFound)
+		} else if errors.Is(err, parser.ErrAddressNotTracked) {
+			http.Error(w, "Address not Tracked", http.StatusNotFound)
+		} else {
+			h.logger.Error("Failed to export transactions of address", slog.String("address", addr), slog.String("error", err.Error()))
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+	if err == nil {
+		err = ew.Close()
+	}
+	// the response is already streaming, the export is left truncated.
+	if err != nil {
+		h.logger.Error("Failed to export transactions of address", slog.String("address", addr), slog.String("error", err.Error()))
+	}
+}
+
+// handleGetTokenTransfers godoc
+// @Summary Get ERC-20 token transfers for an address
+// @Description Get ERC-20 token transfers sent or received by an address
+// @Produce json
+// @Param address query string true "Address to get token transfers for"
+// @Param chain query string false "Chain id, the default chain when missing"
+// @Success 200 {array} TokenTransfer
+// @Failure 400 {string} string "Address parameter missing, Invalid address or Not supported by the chain"
+// @Failure 404 {string} string "Address not tracked, Token transfers not found or Unknown chain"
+// @Failure 500 {string} string
+// @Router /v1/token-transfers [get]
+func (h *Handler) handleGetTokenTransfers(w http.ResponseWriter, r *http.Request) {
+	p, ok := h.evmParser(w, r)
+	if !ok {
+		return
+	}
+	address := r.URL.Query().Get("address")
+	if address == "" {
+		http.Error(w, "Address parameter missing", http.StatusBadRequest)
+		return
+	}
+	if !isValidAddress(p, address) {
+		http.Error(w, "Invalid address", http.StatusBadRequest)
+		return
+	}
+	transfers, err := p.GetTokenTransfers(address)
+	if err != nil {
+		if errors.Is(err, store.ErrNoTransactions) {
+			http.Error(w, "No token transfers found for address", http.StatusNotFound)
+			return
+		} else if errors.Is(err, parser.ErrAddressNotTracked) {
+			http.Error(w, "Address not Tracked", http.StatusNotFound)
+			return
+		} else {
+			h.logger.Error("Failed to get token transfers for address", slog.String("address", address), slog.String("error", err.Error()))
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+	json.NewEncoder(w).Encode(checksumTokenTransfers(transfers))
+}
+
+// handleGetNFTTransfers godoc
+// @Summary Get NFT transfers for an address
+// @Description Get ERC-721 and ERC-1155 transfers sent or received by an address
+// @Produce json
+// @Param address query string true "Address to get NFT transfers for"
+// @Param contract query string false "NFT contract address"
+// @Param tokenId query string false "Token id, decimal or 0x prefixed hex"
+// @Param chain query string false "Chain id, the default chain when missing"
+// @Success 200 {array} NFTTransfer
+// @Failure 400 {string} string "Address parameter missing, Invalid address, Invalid contract address, Invalid token id or Not supported by the chain"
+// @Failure 404 {string} string "Address not tracked, NFT transfers not found or Unknown chain"
+// @Failure 500 {string} string
+// @Router /v1/nft-transfers [get]
+func (h *Handler) handleGetNFTTransfers(w http.ResponseWriter, r *http.Request) {
+	p, ok := h.evmParser(w, r)
+	if !ok {
+		return
+	}
+	address := r.URL.Query().Get("address")
+	if address == "" {
+		http.Error(w, "Address parameter missing", http.StatusBadRequest)
+		return
+	}
+	if !isValidAddress(p, address) {
+		http.Error(w, "Invalid address", http.StatusBadRequest)
+		return
+	}
+	filter := parser.NFTFilter{
+		Contract: r.URL.Query().Get("contract"),
+		TokenID:  r.URL.Query().Get("tokenId"),
+	}
+	if filter.Contract != "" && !isValidAddress(p, filter.Contract) {
+		http.Error(w, "Invalid contract address", http.StatusBadRequest)
+		return
+	}
+	transfers, err := p.GetNFTTransfers(address, filter)
+	if err != nil {
+		if errors.Is(err, store.ErrNoTransactions) {
+			http.Error(w, "No NFT transfers found for address", http.StatusNotFound)
+			return
+		} else if errors.Is(err, parser.ErrAddressNotTracked) {
+			http.Error(w, "Address not Tracked", http.StatusNotFound)
+			return
+		} else if errors.Is(err, parser.ErrInvalidTokenID) {
+			http.Error(w, "Invalid token id", http.StatusBadRequest)
+			return
+		} else {
+			h.logger.Error("Failed to get NFT transfers for address", slog.String("address", address), slog.String("error", err.Error()))
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+	json.NewEncoder(w).Encode(checksumNFTTransfers(transfers))
+}
+
+// handleGetLogs godoc
+// @Summary Get event logs of a contract
+// @Description Get the observed event logs of a subscribed contract, optionally filtered by topics
+// @Produce json
+// @Param contract query string true "Contract to get logs for"
+// @Param topic0 query string false "Event signature topic"
+// @Param topic1 query string false "First indexed topic"
+// @Param topic2 query string false "Second indexed topic"
+// @Param topic3 query string false "Third indexed topic"
+// @Param chain query string false "Chain id, the default chain when missing"
+// @Success 200 {array} EthLog
+// @Failure 400 {string} string "Contract parameter missing, Invalid contract address, Invalid topic or Not supported by the chain"
+// @Failure 404 {string} string "Contract not tracked, Logs not found or Unknown chain"
+// @Failure 500 {string} string
+// @Router /v1/logs [get]
+func (h *Handler) handleGetLogs(w http.ResponseWriter, r *http.Request) {
+	p, ok := h.evmParser(w, r)
+	if !ok {
+		return
+	}
+	contract := r.URL.Query().Get("contract")
+	if contract == "" {
+		http.Error(w, "Contract parameter missing", http.StatusBadRequest)
+		return
+	}
+	if !isValidAddress(p, contract) {
+		http.Error(w, "Invalid contract address", http.StatusBadRequest)
+		return
+	}
+	topics := make([]string, parser.MaxLogTopics)
+	for i := range topics {
+		topics[i] = r.URL.Query().Get(fmt.Sprintf("topic%d", i))
+	}
+	logs, err := p.GetLogs(contract, topics)
+	if err != nil {
+		if errors.Is(err, store.ErrNoTransactions) {
+			http.Error(w, "No logs found for contract", http.StatusNotFound)
+			return
+		} else if errors.Is(err, parser.ErrContractNotTracked) {
+			http.Error(w, "Contract not Tracked", http.StatusNotFound)
+			return
+		} else if errors.Is(err, parser.ErrInvalidTopic) {
+			http.Error(w, "Invalid topic", http.StatusBadRequest)
+			return
+		} else {
+			h.logger.Error("Failed to get logs for contract", slog.String("contract", contract), slog.String("error", err.Error()))
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+	json.NewEncoder(w).Encode(checksumLogs(logs))
+}
+
+// handleGetPendingTransactions godoc
+// @Summary Get pending transactions for an address
+// @Description Get the transactions of an address seen before being mined, with their status:
+// @Description pending, mined, replaced (by a transaction with the same nonce) or dropped
+// @Produce json
+// @Param address query string true "Address to get pending transactions for"
+// @Param chain query string false "Chain id, the default chain when missing"
+// @Success 200 {array} PendingTransaction
+// @Failure 400 {string} string "Address parameter missing, Invalid address or Not supported by the chain"
+// @Failure 404 {string} string "Address not tracked or Unknown chain"
+// @Failure 500 {string} string
+// @Router /v1/pending-transactions [get]
+func (h *Handler) handleGetPendingTransactions(w http.ResponseWriter, r *http.Request) {
+	p, ok := h.evmParser(w, r)
+	if !ok {
+		return
+	}
+	address := r.URL.Query().Get("address")
+	if address == "" {
+		http.Error(w, "Address parameter missing", http.StatusBadRequest)
+		return
+	}
+	if !isValidAddress(p, address) {
+		http.Error(w, "Invalid address", http.StatusBadRequest)
+		return
+	}
+	pending, err := p.GetPendingTransactions(address)
+	if err != nil {
+		if errors.Is(err, parser.ErrAddressNotTracked) {
+			http.Error(w, "Address not Tracked", http.StatusNotFound)
+			return
+		}
+		h.logger.Error("Failed to get pending transactions for address", slog.String("address", address), slog.String("error", err.Error()))
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	json.NewEncoder(w).Encode(checksumPendingTransactions(pending))
+}
+
+// handleGetBalance godoc
+// @Summary Get the balance of an address
+// @Description Get the native balance of a subscribed address, in wei, with its history per block
+// @Produce json
+// @Param address path string true "Address to get the balance of"
+// @Param chain query string false "Chain id, the default chain when missing"
+// @Success 200 {object} Balance
+// @Failure 400 {string} string "Invalid address or Not supported by the chain"
+// @Failure 404 {string} string "Address not tracked, Balance not tracked or Unknown chain"
+// @Failure 500 {string} string
+// @Router /v1/balances/{address} [get]
+func (h *Handler) handleGetBalance(w http.ResponseWriter, r *http.Request) {
+	p, ok := h.evmParser(w, r)
+	if !ok {
+		return
+	}
+	addr := chi.URLParam(r, "address")
+	if !isValidAddress(p, addr) {
+		http.Error(w, "Invalid address", http.StatusBadRequest)
+		return
+	}
+	balance, err := p.GetBalance(addr)
+	if err != nil {
+		if errors.Is(err, parser.ErrAddressNotTracked) {
+			http.Error(w, "Address not Tracked", http.StatusNotFound)
+			return
+		} else if errors.Is(err, parser.ErrBalanceNotTracked) {
+			http.Error(w, "Balance not tracked", http.StatusNotFound)
+			return
+		}
+		h.logger.Error("Failed to get balance of address", slog.String("address", addr), slog.String("error", err.Error()))
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	balance.Address = address.Checksum(balance.Address)
+	json.NewEncoder(w).Encode(balance)
+}
+
+// handleGetStats godoc
+// @Summary Get the statistics of an address
+// @Description Get the total value received and sent, the transaction counts, the fees paid, the
+// @Description first and last seen blocks, the unique counterparties and the volume per time
+// @Description bucket of a subscribed address, in the smallest unit of the chain
+// @Produce json
+// @Param address path string true "Address to get the statistics of"
+// @Param chain query string false "Chain id, the default chain when missing"
+// @Success 200 {object} AddressStats
+// @Failure 400 {string} string "Invalid address"
+// @Failure 404 {string} string "Address not tracked, Statistics not tracked or Unknown chain"
+// @Failure 500 {string} string
+// @Router /v1/addresses/{address}/stats [get]
+func (h *Handler) handleGetStats(w http.ResponseWriter, r *http.Request) {
+	p, ok := h.chainParser(w, r)
+	if !ok {
+		return
+	}
+	addr := chi.URLParam(r, "address")
+	if !isValidAddress(p, addr) {
+		http.Error(w, "Invalid address", http.StatusBadRequest)
+		return
+	}
+	stats, err := p.GetStats(addr)
+	if err != nil {
+		if errors.Is(err, parser.ErrAddressNotTracked) {
+			http.Error(w, "Address not Tracked", http.StatusNotFound)
+			return
+		} else if errors.Is(err, parser.ErrStatsNotTracked) {
+			http.Error(w, "Statistics not tracked", http.StatusNotFound)
+			return
+		}
+		h.logger.Error("Failed to get statistics of address", slog.String("address", addr), slog.String("error", err.Error()))
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if _, ok := p.(parser.EVMParser); ok {
+		stats.Address = address.Checksum(stats.Address)
+	}
+	json.NewEncoder(w).Encode(stats)
+}
+
 // handleSubscribeAddress godoc
-// @Summary Subscribe to an address
-// @Description Subscribe to an address to receive notifications of transactions
+// @Summary Subscribe to an address or to contract event logs
+// @Description Subscribe to an address to receive notifications of transactions, or to a contract
+// @Description with optional topic0..3 filters to receive its event logs
 // @Tags subscribe
-// @Param address query string true "Address to subscribe to"
+// @Param address body string false "Address or ENS name to subscribe to"
+// @Param contract body string false "Contract to subscribe to the logs of"
+// @Param topics body []string false "Topic filters of the contract logs, empty topics match any value"
+// @Param label body string false "Label of the address"
+// @Param tags body []string false "Tags of the address"
+// @Param group body string false "Group of the address, e.g. hot-wallets"
 // @Accept json
+// @Param chain query string false "Chain id, the default chain when missing"
 // @Success 200 {string} string "OK"
-// @Failure 400 {string} string "Address parameter missing"
-// @Failure 400 {string} string "Invalid address"
+// @Failure 400 {string} string "Address parameter missing, Invalid address, Invalid contract address, Invalid topic, Not supported by the chain, Labels not supported by the chain or Names not supported by the chain"
+// @Failure 404 {string} string "Unknown chain or Name not found"
 // @Failure 500 {string} string "Failed to subscribe to address"
+// @Failure 502 {string} string "Failed to resolve name"
 // @Router /v1/subscribe [post]
 func (h *Handler) handleSubscribeAddress(w http.ResponseWriter, r *http.Request) {
+	p, ok := h.chainParser(w, r)
+	if !ok {
+		return
+	}
 	type Address struct {
-		Address string `json:"address"`
+		Address  string   `json:"address"`
+		Contract string   `json:"contract"`
+		Topics   []string `json:"topics"`
+		Label    string   `json:"label"`
+		Tags     []string `json:"tags"`
+		Group    string   `json:"group"`
 	}
 	var address Address
 	if err := json.NewDecoder(r.Body).Decode(&address); err != nil {
@@ -105,16 +577,41 @@ func (h *Handler) handleSubscribeAddress(w http.ResponseWriter, r *http.Request)
 		http.Error(w, "Failed to decode request body", http.StatusBadRequest)
 		return
 	}
+	if address.Contract != "" {
+		h.subscribeLogs(w, p, parser.LogSubscription{Contract: address.Contract, Topics: address.Topics})
+		return
+	}
 	addr := address.Address
 	if addr == "" {
 		http.Error(w, "Address parameter missing", http.StatusBadRequest)
 		return
 	}
-	if !isValidEthAddress(addr) {
+	registry := h.chainOf(p).Labels
+	labeled := address.Label != "" || len(address.Tags) > 0 || address.Group != ""
+	if labeled && registry == nil {
+		http.Error(w, "Labels not supported by the chain", http.StatusBadRequest)
+		return
+	}
+	resolved, ok := h.resolveAddress(w, r, p, addr)
+	if !ok {
+		return
+	}
+	canonical, err := p.ValidateAddress(resolved)
+	if err != nil {
 		http.Error(w, "Invalid address", http.StatusBadRequest)
 		return
 	}
-	if h.txParser.Subscribe(addr) {
+	if p.Subscribe(resolved) {
+		if resolved != addr {
+			h.chainOf(p).Resolver.Watch(addr, resolved)
+			// a name is the label of its address unless another one is given.
+			if registry != nil && address.Label == "" {
+				labeled, address.Label = true, addr
+			}
+		}
+		if labeled {
+			registry.Set(labels.Entry{Address: canonical, Label: address.Label, Tags: address.Tags, Group: address.Group})
+		}
 		w.WriteHeader(http.StatusOK)
 		return
 	} else {
@@ -123,12 +620,371 @@ func (h *Handler) handleSubscribeAddress(w http.ResponseWriter, r *http.Request)
 	}
 }
 
-func isValidEthAddress(address string) bool {
-	if len(address) != parser.EthAddressLength || address[:2] != "0x" {
-		return false
+// handleGetDeadLetters godoc
+// @Summary Get the dead-letter queue
+// @Description Get the blocks that could not be processed after all of their retries
+// @Tags admin
+// @Produce json
+// @Param chain query string false "Chain id, the default chain when missing"
+// @Success 200 {array} FailedBlock
+// @Failure 404 {string} string "Unknown chain"
+// @Router /v1/admin/dead-letters [get]
+func (h *Handler) handleGetDeadLetters(w http.ResponseWriter, r *http.Request) {
+	p, ok := h.chainParser(w, r)
+	if !ok {
+		return
+	}
+	json.NewEncoder(w).Encode(p.FailedBlocks())
+}
+
+// handleReplayDeadLetters godoc
+// @Summary Replay the dead-letter queue
+// @Description Push the blocks of the dead-letter queue back for processing
+// @Tags admin
+// @Produce json
+// @Param chain query string false "Chain id, the default chain when missing"
+// @Success 200 {object} object "Number of replayed blocks"
+// @Failure 404 {string} string "Unknown chain"
+// @Failure 503 {string} string "Failed to replay dead-letter queue"
+// @Router /v1/admin/dead-letters/replay [post]
+func (h *Handler) handleReplayDeadLetters(w http.ResponseWriter, r *http.Request) {
+	p, ok := h.chainParser(w, r)
+	if !ok {
+		return
+	}
+	replayed, err := p.ReplayFailedBlocks(r.Context())
+	h.logger.Info("Replayed dead-letter queue", slog.Int("blocks", replayed))
+	if err != nil {
+		h.logger.Error("Failed to replay dead-letter queue", slog.String("error", err.Error()))
+		http.Error(w, fmt.Sprintf("Failed to replay dead-letter queue after %d blocks: %v", replayed, err), http.StatusServiceUnavailable)
+		return
+	}
+	json.NewEncoder(w).Encode(map[string]int{"replayed": replayed})
+}
+
+// handleGetQueueStats godoc
+// @Summary Get the block queue state
+// @Description Get the depth, capacity, policy and number of dropped blocks of the queue of blocks waiting for a worker
+// @Tags admin
+// @Produce json
+// @Param chain query string false "Chain id, the default chain when missing"
+// @Success 200 {object} QueueStats
+// @Failure 404 {string} string "Unknown chain"
+// @Router /v1/admin/queue [get]
+func (h *Handler) handleGetQueueStats(w http.ResponseWriter, r *http.Request) {
+	p, ok := h.chainParser(w, r)
+	if !ok {
+		return
 	}
-	if _, err := hex.DecodeString(address[2:]); err != nil {
-		return false
+	json.NewEncoder(w).Encode(p.QueueStats())
+}
+
+// subscribeLogs subscribes to the event logs of a contract.
+func (h *Handler) subscribeLogs(w http.ResponseWriter, p parser.Parser, sub parser.LogSubscription) {
+	evm, ok := p.(parser.EVMParser)
+	if !ok {
+		http.Error(w, "Not supported by the chain", http.StatusBadRequest)
+		return
+	}
+	if !isValidAddress(p, sub.Contract) {
+		http.Error(w, "Invalid contract address", http.StatusBadRequest)
+		return
+	}
+	if err := evm.SubscribeLogs(sub); err != nil {
+		if errors.Is(err, parser.ErrInvalidTopic) {
+			http.Error(w, "Invalid topic", http.StatusBadRequest)
+			return
+		}
+		http.Error(w, "Failed to subscribe to contract logs", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+// handleGetLabels godoc
+// @Summary Get the labeled addresses
+// @Description Get the labels, tags and groups of the addresses of a chain, optionally of a group or with a tag
+// @Produce json
+// @Param group query string false "Group of the addresses"
+// @Param tag query string false "Tag of the addresses"
+// @Param chain query string false "Chain id, the default chain when missing"
+// @Success 200 {array} labels.Entry
+// @Failure 404 {string} string "Unknown chain"
+// @Router /v1/labels [get]
+func (h *Handler) handleGetLabels(w http.ResponseWriter, r *http.Request) {
+	p, ok := h.chainParser(w, r)
+	if !ok {
+		return
+	}
+	registry := h.chainOf(p).Labels
+	if registry == nil {
+		json.NewEncoder(w).Encode([]labels.Entry{})
+		return
+	}
+	_, evm := p.(parser.EVMParser)
+	entries := []labels.Entry{}
+	for _, e := range registry.List(r.URL.Query().Get("group"), r.URL.Query().Get("tag")) {
+		if !isChainAddress(p, e.Address) {
+			continue
+		}
+		if evm {
+			e.Address = address.Checksum(e.Address)
+		}
+		entries = append(entries, e)
+	}
+	json.NewEncoder(w).Encode(entries)
+}
+
+// chainAddresses returns the addresses of the chain of a parser, a registry shared by chains
+// holding the addresses of all of them.
+func chainAddresses(p parser.Parser, addrs []string) []string {
+	var res []string
+	for _, a := range addrs {
+		if isChainAddress(p, a) {
+			res = append(res, a)
+		}
+	}
+	return res
+}
+
+// isChainAddress reports whether an address in its canonical form is one of the chain of a parser.
+// The canonical EVM addresses are in lower case, without the checksum a strict parser asks for.
+func isChainAddress(p parser.Parser, addr string) bool {
+	if _, evm := p.(parser.EVMParser); evm {
+		return address.Validate(addr) == nil
+	}
+	return isValidAddress(p, addr)
+}
+
+// handleGetChains godoc
+// @Summary Get the chains
+// @Description Get the ids and names of the chains that can be selected with the chain parameter
+// @Produce json
+// @Success 200 {array} Chain
+// @Router /v1/chains [get]
+func (h *Handler) handleGetChains(w http.ResponseWriter, r *http.Request) {
+	chains := h.chains
+	if chains == nil {
+		chains = []Chain{}
+	}
+	json.NewEncoder(w).Encode(chains)
+}
+
+// chainParser returns the parser of the chain selected by the chain query parameter, writing a
+// 404 response for an unknown chain.
+func (h *Handler) chainParser(w http.ResponseWriter, r *http.Request) (parser.Parser, bool) {
+	id := r.URL.Query().Get("chain")
+	if id == "" {
+		return h.txParser, true
+	}
+	for _, c := range h.chains {
+		if c.ID == id {
+			return c.Parser, true
+		}
+	}
+	http.Error(w, "Unknown chain", http.StatusNotFound)
+	return nil, false
+}
+
+// chainOf returns the chain of p, the zero Chain when p is not one of the chains.
+func (h *Handler) chainOf(p parser.Parser) Chain {
+	for _, c := range h.chains {
+		if c.Parser == p {
+			return c
+		}
+	}
+	return Chain{}
+}
+
+// resolveAddress returns the address a name resolves to on the chain of p, addresses being
+// returned as is, writing an error response when the name cannot be resolved.
+func (h *Handler) resolveAddress(w http.ResponseWriter, r *http.Request, p parser.Parser, name string) (string, bool) {
+	if !ens.IsName(name) {
+		return name, true
+	}
+	resolver := h.chainOf(p).Resolver
+	if resolver == nil {
+		http.Error(w, "Names not supported by the chain", http.StatusBadRequest)
+		return "", false
+	}
+	resolved, err := resolver.Resolve(r.Context(), name)
+	switch {
+	case errors.Is(err, ens.ErrInvalidName):
+		http.Error(w, "Invalid address", http.StatusBadRequest)
+		return "", false
+	case errors.Is(err, ens.ErrNameNotFound):
+		http.Error(w, "Name not found", http.StatusNotFound)
+		return "", false
+	case err != nil:
+		h.logger.Error("Failed to resolve name", slog.String("name", name), slog.String("error", err.Error()))
+		http.Error(w, "Failed to resolve name", http.StatusBadGateway)
+		return "", false
+	}
+	return resolved, true
+}
+
+// evmParser returns the parser of the chain selected by the chain query parameter, writing a
+// 400 response for a chain that is not an EVM chain.
+func (h *Handler) evmParser(w http.ResponseWriter, r *http.Request) (parser.EVMParser, bool) {
+	p, ok := h.chainParser(w, r)
+	if !ok {
+		return nil, false
+	}
+	evm, ok := p.(parser.EVMParser)
+	if !ok {
+		http.Error(w, "Not supported by the chain", http.StatusBadRequest)
+		return nil, false
+	}
+	return evm, true
+}
+
+// filterKind returns the transactions of the given kind, all of them when kind is empty.
+func filterKind[T any](txs []T, kind string, kindOf func(T) string) []T {
+	if kind == "" {
+		return txs
+	}
+	filtered := txs[:0]
+	for _, tx := range txs {
+		if kindOf(tx) == kind {
+			filtered = append(filtered, tx)
+		}
+	}
+	return filtered
+}
+
+// collectTransactions returns the transactions of addresses in block order, a transaction between
+// two of them once. The errors of a single address are returned, whereas the addresses of a group
+// which are not tracked or have no transactions are skipped.
+func collectTransactions[T any](addresses []string, group bool, get func(string) ([]T, error), key func(T) string, block func(T) int64) ([]T, error) {
+	if !group {
+		return get(addresses[0])
+	}
+	res := []T{}
+	seen := make(map[string]bool)
+	for _, addr := range addresses {
+		txs, err := get(addr)
+		if errors.Is(err, parser.ErrAddressNotTracked) || errors.Is(err, store.ErrNoTransactions) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		for _, tx := range txs {
+			if k := key(tx); !seen[k] {
+				seen[k] = true
+				res = append(res, tx)
+			}
+		}
+	}
+	slices.SortStableFunc(res, func(a, b T) int {
+		return cmp.Compare(block(a), block(b))
+	})
+	return res, nil
+}
+
+// labelTransactions returns the transactions, with their addresses in their EIP-55 form, along
+// with the labels of their addresses.
+func labelTransactions(registry *labels.Registry, txs []parser.EthTransaction) []LabeledTransaction {
+	res := make([]LabeledTransaction, len(txs))
+	for i, tx := range txs {
+		res[i].Labels = addressLabels(registry, address.Checksum, tx.From, tx.To)
+	}
+	for i, tx := range checksumTransactions(txs) {
+		res[i].EthTransaction = tx
+	}
+	return res
+}
+
+// labelChainTransactions returns the chain agnostic transactions along with the labels of their
+// addresses.
+func labelChainTransactions(registry *labels.Registry, txs []parser.Transaction) []LabeledChainTransaction {
+	res := make([]LabeledChainTransaction, len(txs))
+	for i, tx := range txs {
+		res[i] = LabeledChainTransaction{Transaction: tx, Labels: addressLabels(registry, func(a string) string { return a }, tx.Addresses()...)}
+	}
+	return res
+}
+
+// addressLabels returns the labels of the addresses which have one, keyed by the display form of
+// the addresses, nil when none has.
+func addressLabels(registry *labels.Registry, display func(string) string, addrs ...string) map[string]string {
+	if registry == nil {
+		return nil
+	}
+	var res map[string]string
+	for _, addr := range addrs {
+		if label := registry.Label(addr); label != "" {
+			if res == nil {
+				res = make(map[string]string)
+			}
+			res[display(addr)] = label
+		}
+	}
+	return res
+}
+
+// isValidAddress reports whether addr is an address of the chain of p, an Ethereum address when
+// there is no parser.
+func isValidAddress(p parser.Parser, addr string) bool {
+	if p == nil {
+		return address.Validate(addr) == nil
+	}
+	_, err := p.ValidateAddress(addr)
+	return err == nil
+}
+
+// checksumTransactions returns the transactions with their addresses in their EIP-55 form, the
+// stores keeping them lower case.
+func checksumTransactions(txs []parser.EthTransaction) []parser.EthTransaction {
+	for i := range txs {
+		txs[i].From = address.Checksum(txs[i].From)
+		txs[i].To = address.Checksum(txs[i].To)
+	}
+	return txs
+}
+
+// checksumTransfers sets the addresses of transfers to their EIP-55 form.
+func checksumTransfers(transfers []parser.Transfer) {
+	for i := range transfers {
+		transfers[i].Address = address.Checksum(transfers[i].Address)
+	}
+}
+
+// checksumPendingTransactions returns the pending transactions with their addresses in their
+// EIP-55 form.
+func checksumPendingTransactions(txs []parser.PendingTransaction) []parser.PendingTransaction {
+	for i := range txs {
+		txs[i].From = address.Checksum(txs[i].From)
+		txs[i].To = address.Checksum(txs[i].To)
+	}
+	return txs
+}
+
+// checksumTokenTransfers returns the token transfers with their addresses in their EIP-55 form.
+func checksumTokenTransfers(transfers []parser.TokenTransfer) []parser.TokenTransfer {
+	for i := range transfers {
+		transfers[i].Token = address.Checksum(transfers[i].Token)
+		transfers[i].From = address.Checksum(transfers[i].From)
+		transfers[i].To = address.Checksum(transfers[i].To)
+	}
+	return transfers
+}
+
+// checksumNFTTransfers returns the NFT transfers with their addresses in their EIP-55 form.
+func checksumNFTTransfers(transfers []parser.NFTTransfer) []parser.NFTTransfer {
+	for i := range transfers {
+		transfers[i].Contract = address.Checksum(transfers[i].Contract)
+		transfers[i].Operator = address.Checksum(transfers[i].Operator)
+		transfers[i].From = address.Checksum(transfers[i].From)
+		transfers[i].To = address.Checksum(transfers[i].To)
+	}
+	return transfers
+}
+
+// checksumLogs returns the logs with their contract address in its EIP-55 form.
+func checksumLogs(logs []parser.EthLog) []parser.EthLog {
+	for i := range logs {
+		logs[i].Address = address.Checksum(logs[i].Address)
 	}
-	return true
+	return logs
 }
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/pmes126/tx-parser-service/pkg/export"
+)
+
+// runExport is the export subcommand, dumping the transactions of an address from a running
+// service to a file with /v1/transactions/export.
+func runExport(args []string) error {
+	fs := flag.NewFlagSet("export", flag.ContinueOnError)
+	server := fs.String("server", "http://localhost:8080", "URL of the tx-parser-service")
+	addr := fs.String("address", "", "address or ENS name to export the transactions of")
+	format := fs.String("format", export.FormatCSV, "export format: csv, ndjson or parquet")
+	chain := fs.String("chain", "", "chain id, the default chain of the service when empty")
+	out := fs.String("o", "", "file to write the export to, stdout when empty")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *addr == "" {
+		return fmt.Errorf("export: -address missing")
+	}
+	query := url.Values{"address": {*addr}, "format": {*format}}
+	if *chain != "" {
+		query.Set("chain", *chain)
+	}
+	resp, err := http.Get(strings.TrimSuffix(*server, "/") + "/v1/transactions/export?" + query.Encode())
+	if err != nil {
+		return fmt.Errorf("export: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("export: %s: %s", resp.Status, strings.TrimSpace(string(msg)))
+	}
+	w := os.Stdout
+	if *out != "" {
+		f, err := os.Create(*out)
+		if err != nil {
+			return fmt.Errorf("export: %w", err)
+		}
+		defer f.Close()
+		w = f
+	}
+	if _, err := io.Copy(w, resp.Body); err != nil {
+		return fmt.Errorf("export: %w", err)
+	}
+	return nil
+}
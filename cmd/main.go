@@ -8,27 +8,106 @@ import (
 	"net/http"
 	"os"
 	"os/signal"
+	"strconv"
+	"sync"
 	"syscall"
 	"time"
 
 	"github.com/spf13/viper"
 
 	"github.com/pmes126/tx-parser-service/api/handler"
+	"github.com/pmes126/tx-parser-service/internal/rpc"
 	"github.com/pmes126/tx-parser-service/internal/store"
+	"github.com/pmes126/tx-parser-service/pkg/address"
+	"github.com/pmes126/tx-parser-service/pkg/bitcoin"
+	"github.com/pmes126/tx-parser-service/pkg/decoder"
+	"github.com/pmes126/tx-parser-service/pkg/ens"
+	"github.com/pmes126/tx-parser-service/pkg/labels"
 	"github.com/pmes126/tx-parser-service/pkg/parser"
 )
 
 type Config struct {
-	HTTPPort     int `mapstructure:"http_port"`
-	ReadTimeout  int `mapstructure:"readTimeout"`
-	WriteTimeout int `mapstructure:"writeTimeout"`
-	IdleTimeout  int `mapstructure:"idleTimeout"`
-	HTTPTimeout  int `mapstructure:"httpTimeout"`
-	PollInterval int `mapstructure:"pollInterval"`
-	WorkerCount  int `mapstructure:"workerCount"`
+	HTTPPort     int    `mapstructure:"http_port"`
+	ReadTimeout  int    `mapstructure:"readTimeout"`
+	WriteTimeout int    `mapstructure:"writeTimeout"`
+	IdleTimeout  int    `mapstructure:"idleTimeout"`
+	HTTPTimeout  int    `mapstructure:"httpTimeout"`
+	PollInterval int    `mapstructure:"pollInterval"`
+	WorkerCount  int    `mapstructure:"workerCount"`
+	TraceMode    string `mapstructure:"traceMode"`
+	ABIDir       string `mapstructure:"abiDir"`
+	// Retries of a failed block before it is moved to the dead-letter queue.
+	RetryMaxAttempts int `mapstructure:"retryMaxAttempts"`
+	RetryBaseDelayMs int `mapstructure:"retryBaseDelayMs"`
+	RetryMaxDelayMs  int `mapstructure:"retryMaxDelayMs"`
+	// Queue of blocks waiting for a worker, per lane, and the policy applied when it is full.
+	QueueSize   int    `mapstructure:"queueSize"`
+	QueuePolicy string `mapstructure:"queuePolicy"`
+	// Worker pool autoscaling bounds and RPC requests per second budget, disabled when MaxWorkers is 0.
+	MinWorkers int     `mapstructure:"minWorkers"`
+	MaxWorkers int     `mapstructure:"maxWorkers"`
+	RPCBudget  float64 `mapstructure:"rpcBudget"`
+	// Seconds given to the workers to process the queued blocks on shutdown.
+	DrainTimeout int `mapstructure:"drainTimeout"`
+	// Maximum JSON-RPC calls per batch request and milliseconds to wait to coalesce concurrent calls.
+	RPCBatchSize   int `mapstructure:"rpcBatchSize"`
+	RPCBatchWaitMs int `mapstructure:"rpcBatchWaitMs"`
+	// Seconds before a JSON-RPC request times out per method, HTTPTimeout for the other methods.
+	RPCMethodTimeouts map[string]int `mapstructure:"rpcMethodTimeouts"`
+	// WebSocket RPC endpoint of the newHeads subscription, polling only when empty.
+	WSRPCURL string `mapstructure:"wsRpcUrl"`
+	// Reject the mixed case EVM addresses which are not EIP-55 checksummed, instead of only the wrongly checksummed ones.
+	StrictAddresses bool `mapstructure:"strictAddresses"`
+	// ENS names are resolved on the EVM chain of this chain id, not at all when 0.
+	ENSChainID int `mapstructure:"ensChainId"`
+	// Seconds an ENS resolution is cached and between the re-resolutions of the subscribed names.
+	ENSTTL             int `mapstructure:"ensTtl"`
+	ENSRefreshInterval int `mapstructure:"ensRefreshInterval"`
+	// Tracking of the pending transactions, "poll" or "subscribe", none when empty.
+	PendingMode string `mapstructure:"pendingMode"`
+	// Seconds between two polls of the pending block and before a pending transaction is dropped.
+	PendingInterval int `mapstructure:"pendingInterval"`
+	PendingTimeout  int `mapstructure:"pendingTimeout"`
+	// Track the native balances of the subscribed addresses, verified against the node every
+	// BalanceVerifyInterval seconds.
+	Balances              bool `mapstructure:"balances"`
+	BalanceVerifyInterval int  `mapstructure:"balanceVerifyInterval"`
+	// Seconds of the volume buckets of the address statistics, not maintained when 0.
+	StatsBucket int `mapstructure:"statsBucket"`
+	// Labels, tags and groups of known addresses, e.g. exchanges or the wallets of the customers.
+	Labels []labels.Entry `mapstructure:"labels"`
+	// Chains parsed by the service, a single Ethereum mainnet chain when empty.
+	Chains []ChainConfig `mapstructure:"chains"`
 }
 
+// ChainConfig configures the parser of one chain, the zero fields defaulting to the top level config.
+type ChainConfig struct {
+	Name string `mapstructure:"name"`
+	// Type of the chain, ChainTypeEVM when empty or ChainTypeBitcoin.
+	Type string `mapstructure:"type"`
+	// Network of a bitcoin chain: mainnet, testnet or regtest.
+	Network       string   `mapstructure:"network"`
+	ChainID       int      `mapstructure:"chainId"`
+	RPCURLs       []string `mapstructure:"rpcUrls"`
+	WSRPCURL      string   `mapstructure:"wsRpcUrl"`
+	PollInterval  int      `mapstructure:"pollInterval"`
+	Confirmations int      `mapstructure:"confirmations"`
+	// Namespace of the chain records in the shared stores, the chain id when empty.
+	Namespace string `mapstructure:"namespace"`
+}
+
+const (
+	ChainTypeEVM     = "evm"
+	ChainTypeBitcoin = "bitcoin"
+)
+
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "export" {
+		if err := runExport(os.Args[2:]); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
 	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
 	if err := initConfig(); err != nil {
 		log.Fatal(err)
@@ -50,17 +129,176 @@ func run(c context.Context, logger *slog.Logger, cfg *Config) error {
 	signal.Notify(shutdown, syscall.SIGINT, syscall.SIGTERM, os.Interrupt)
 	defer signal.Stop(shutdown)
 
-	ethTxParser := parser.NewEthTxParser(store.NewMemTxStore[parser.EthTransaction](), &http.Client{}, logger, cfg.PollInterval)
+	queuePolicy, err := parser.ParseQueuePolicy(cfg.QueuePolicy)
+	if err != nil {
+		return err
+	}
 
-	go func() {
-		logger.Info("Starting tx-parser block polling")
-		ethTxParser.Start(ctx)
+	if cfg.TraceMode != "" && cfg.TraceMode != parser.TraceModeDebug && cfg.TraceMode != parser.TraceModeTrace {
+		return fmt.Errorf("unknown trace mode %q", cfg.TraceMode)
+	}
+	if cfg.PendingMode != "" && cfg.PendingMode != parser.PendingModePoll && cfg.PendingMode != parser.PendingModeSubscribe {
+		return fmt.Errorf("unknown pending mode %q", cfg.PendingMode)
+	}
+
+	abiDecoder := decoder.NewDecoder()
+	if cfg.ABIDir != "" {
+		if err := abiDecoder.LoadABIDir(cfg.ABIDir); err != nil {
+			return fmt.Errorf("failed to load ABIs: %w", err)
+		}
+	}
+
+	rpcTimeout := time.Duration(cfg.HTTPTimeout) * time.Second
+	methodTimeouts := make(map[string]time.Duration, len(cfg.RPCMethodTimeouts))
+	for method, seconds := range cfg.RPCMethodTimeouts {
+		methodTimeouts[method] = time.Duration(seconds) * time.Second
+	}
+	// The client timeout is only a backstop for the per request timeouts of the parser.
+	httpClient := &http.Client{Timeout: max(rpcTimeout, parser.DefaultRPCTimeout, parser.DefaultTraceTimeout)}
+	for _, d := range methodTimeouts {
+		httpClient.Timeout = max(httpClient.Timeout, d)
+	}
+
+	chains := cfg.Chains
+	if len(chains) == 0 {
+		chains = []ChainConfig{{Name: "ethereum", ChainID: 1, WSRPCURL: cfg.WSRPCURL}}
+	} else if err := validateChains(chains); err != nil {
+		return err
+	}
+	// The chains share the stores, each in its own namespace.
+	txStore := store.NewMemTxStore[parser.EthTransaction]()
+	tokenStore := store.NewMemTxStore[parser.TokenTransfer]()
+	nftStore := store.NewMemTxStore[parser.NFTTransfer]()
+	logStore := store.NewMemTxStore[parser.EthLog]()
+	chainTxStore := store.NewMemTxStore[parser.Transaction]()
+	balanceStore := store.NewMemTxStore[parser.BalanceChange]()
+	statsBucket := time.Duration(cfg.StatsBucket) * time.Second
+
+	var parsers []handler.Chain
+	var parsersDone sync.WaitGroup
+	// on every return, the parsers are stopped and drain their queued blocks.
+	defer func() {
+		cancel()
+		parsersDone.Wait()
 	}()
+	for _, chain := range chains {
+		id, namespace := chain.id(), chain.namespace()
+		// the labels are per chain, the addresses being in their canonical form.
+		registry := labels.NewRegistry()
+		pollInterval := chain.PollInterval
+		if pollInterval == 0 {
+			pollInterval = cfg.PollInterval
+		}
+		chainLogger := logger.With(slog.String("chain", chain.Name))
+		if chain.Type == ChainTypeBitcoin {
+			network, err := bitcoin.ParseNetwork(chain.Network)
+			if err != nil {
+				return err
+			}
+			client := rpc.NewClient(httpClient, chain.RPCURLs[0],
+				rpc.WithFallbacks(chain.RPCURLs[1:]...),
+				rpc.WithBatchSize(cfg.RPCBatchSize),
+				rpc.WithTimeouts(rpcTimeout, methodTimeouts),
+			)
+			btcParser := parser.NewChainParser(bitcoin.NewAdapter(client, network), store.NewNamespacedTxStore(chainTxStore, namespace), chainLogger, pollInterval,
+				parser.WithChainCursorStore(store.NewMemCursorStore()),
+				parser.WithChainWorkers(cfg.WorkerCount),
+				parser.WithChainDrainTimeout(time.Duration(cfg.DrainTimeout)*time.Second),
+				parser.WithChainQueue(cfg.QueueSize, queuePolicy),
+				parser.WithChainRetry(cfg.RetryMaxAttempts, time.Duration(cfg.RetryBaseDelayMs)*time.Millisecond, time.Duration(cfg.RetryMaxDelayMs)*time.Millisecond),
+				parser.WithChainConfirmations(int64(chain.Confirmations)),
+				parser.WithChainStats(statsBucket),
+			)
+			parsers = append(parsers, handler.Chain{ID: id, Name: chain.Name, Parser: btcParser, Labels: registry})
+			parsersDone.Add(1)
+			go func() {
+				defer parsersDone.Done()
+				chainLogger.Info("Starting tx-parser block polling")
+				btcParser.Start(ctx)
+			}()
+			continue
+		}
+		// balances are not tracked without a history store.
+		var balanceHistory store.TxStore[parser.BalanceChange]
+		if cfg.Balances {
+			balanceHistory = store.NewNamespacedTxStore(balanceStore, namespace)
+		}
+		ethTxParser := parser.NewEthTxParser(store.NewNamespacedTxStore(txStore, namespace), httpClient, chainLogger, pollInterval,
+			parser.WithTokenStore(store.NewNamespacedTxStore(tokenStore, namespace)),
+			parser.WithNFTStore(store.NewNamespacedTxStore(nftStore, namespace)),
+			parser.WithLogStore(store.NewNamespacedTxStore(logStore, namespace)),
+			parser.WithCursorStore(store.NewMemCursorStore()),
+			parser.WithTraceMode(cfg.TraceMode),
+			parser.WithDecoder(abiDecoder),
+			parser.WithWorkers(cfg.WorkerCount),
+			parser.WithAutoscale(cfg.MinWorkers, cfg.MaxWorkers, cfg.RPCBudget),
+			parser.WithDrainTimeout(time.Duration(cfg.DrainTimeout)*time.Second),
+			parser.WithQueue(cfg.QueueSize, queuePolicy),
+			parser.WithRetry(cfg.RetryMaxAttempts, time.Duration(cfg.RetryBaseDelayMs)*time.Millisecond, time.Duration(cfg.RetryMaxDelayMs)*time.Millisecond),
+			parser.WithBatch(cfg.RPCBatchSize, time.Duration(cfg.RPCBatchWaitMs)*time.Millisecond),
+			parser.WithRPCTimeouts(rpcTimeout, methodTimeouts),
+			parser.WithEndpoints(chain.RPCURLs...),
+			parser.WithWebSocket(chain.WSRPCURL),
+			parser.WithConfirmations(int64(chain.Confirmations)),
+			parser.WithStrictAddresses(cfg.StrictAddresses),
+			parser.WithPending(cfg.PendingMode, time.Duration(cfg.PendingInterval)*time.Second, time.Duration(cfg.PendingTimeout)*time.Second),
+			parser.WithBalances(balanceHistory, time.Duration(cfg.BalanceVerifyInterval)*time.Second),
+			parser.WithStats(statsBucket),
+		)
+		if err := checkChainID(ctx, ethTxParser, chain, rpcTimeout, chainLogger); err != nil {
+			return err
+		}
+		evmChain := handler.Chain{ID: id, Name: chain.Name, Parser: ethTxParser, Labels: registry}
+		if cfg.ENSChainID != 0 && chain.ChainID == cfg.ENSChainID {
+			endpoints := chain.RPCURLs
+			if len(endpoints) == 0 {
+				endpoints = []string{parser.RpcUrl}
+			}
+			client := rpc.NewClient(httpClient, endpoints[0],
+				rpc.WithFallbacks(endpoints[1:]...),
+				rpc.WithTimeouts(rpcTimeout, methodTimeouts),
+			)
+			resolver := ens.NewResolver(client, chainLogger,
+				ens.WithTTL(time.Duration(cfg.ENSTTL)*time.Second),
+				ens.WithOnChange(followName(ethTxParser, registry)),
+			)
+			evmChain.Resolver = resolver
+			if cfg.ENSRefreshInterval > 0 {
+				go resolver.Start(ctx, time.Duration(cfg.ENSRefreshInterval)*time.Second)
+			}
+		}
+		parsers = append(parsers, evmChain)
+
+		parsersDone.Add(1)
+		go func() {
+			defer parsersDone.Done()
+			chainLogger.Info("Starting tx-parser block polling")
+			ethTxParser.Start(ctx)
+		}()
+	}
+
+	// a label is set on every chain its address is valid on.
+	for _, entry := range cfg.Labels {
+		labeled := false
+		for _, chain := range parsers {
+			canonical, err := chain.Parser.ValidateAddress(entry.Address)
+			if err != nil {
+				continue
+			}
+			e := entry
+			e.Address = canonical
+			chain.Labels.Set(e)
+			labeled = true
+		}
+		if !labeled {
+			logger.Warn("Label of an address invalid on every chain", slog.String("address", entry.Address), slog.String("label", entry.Label))
+		}
+	}
 
 	// Construct an HTTP server to service requests.
 	server := http.Server{
 		Addr:         fmt.Sprintf(":%d", cfg.HTTPPort),
-		Handler:      handler.Routes(handler.NewHandler(logger, ethTxParser, 5*time.Second)),
+		Handler:      handler.Routes(handler.NewHandler(logger, parsers[0].Parser, 5*time.Second, parsers...)),
 		ReadTimeout:  time.Duration(cfg.ReadTimeout) * time.Second,
 		WriteTimeout: time.Duration(cfg.WriteTimeout) * time.Second,
 		IdleTimeout:  time.Duration(cfg.IdleTimeout) * time.Second,
@@ -81,8 +319,8 @@ func run(c context.Context, logger *slog.Logger, cfg *Config) error {
 	case <-shutdown:
 		// Cancel existing context.
 		cancel()
-		// Shutdown the server gracefully.
-		sctx, cancel := context.WithTimeout(ctx, time.Second)
+		// Shutdown the server gracefully, ctx being done already.
+		sctx, cancel := context.WithTimeout(context.Background(), time.Second)
 		defer cancel()
 		if err := server.Shutdown(sctx); err != nil {
 			if err := server.Close(); err != nil {
@@ -94,6 +332,79 @@ func run(c context.Context, logger *slog.Logger, cfg *Config) error {
 	}
 }
 
+// id is the id of a chain on the API, its name when it has no chain id, e.g. bitcoin.
+func (c ChainConfig) id() string {
+	if c.ChainID == 0 {
+		return c.Name
+	}
+	return strconv.Itoa(c.ChainID)
+}
+
+// namespace is the namespace of the records of a chain in the stores, its id when not set.
+func (c ChainConfig) namespace() string {
+	if c.Namespace == "" {
+		return c.id()
+	}
+	return c.Namespace
+}
+
+// validateChains checks the configured chains: each has its own RPC endpoints, id and namespace,
+// a chain falling back to the endpoint or the records of another one otherwise.
+func validateChains(chains []ChainConfig) error {
+	ids := make(map[string]string, len(chains))
+	namespaces := make(map[string]string, len(chains))
+	for _, chain := range chains {
+		if chain.Type != "" && chain.Type != ChainTypeEVM && chain.Type != ChainTypeBitcoin {
+			return fmt.Errorf("chain %s: unknown type %q", chain.Name, chain.Type)
+		}
+		if len(chain.RPCURLs) == 0 {
+			return fmt.Errorf("chain %s: rpcUrls missing", chain.Name)
+		}
+		if other, ok := ids[chain.id()]; ok {
+			return fmt.Errorf("chain %s: id %s already used by chain %s", chain.Name, chain.id(), other)
+		}
+		ids[chain.id()] = chain.Name
+		if other, ok := namespaces[chain.namespace()]; ok {
+			return fmt.Errorf("chain %s: namespace %s already used by chain %s", chain.Name, chain.namespace(), other)
+		}
+		namespaces[chain.namespace()] = chain.Name
+	}
+	return nil
+}
+
+// checkChainID checks the node of an EVM chain serves the configured chain id, if any. An
+// unreachable node is only logged, the parser retrying it.
+// followName returns the function following a subscribed name pointing to another address: the
+// new address is tracked instead of the previous one and the entry of the previous one, its label
+// being the name unless another one was given, is moved to it.
+func followName(p *parser.EthTxParser, registry *labels.Registry) func(name, previous, current string) {
+	return func(name, previous, current string) {
+		// the resolved address is lower case, its checksum form passing the strict validation.
+		if !p.Subscribe(address.Checksum(current)) {
+			return
+		}
+		p.Unsubscribe(previous)
+		registry.Move(previous, current)
+	}
+}
+
+func checkChainID(ctx context.Context, p *parser.EthTxParser, chain ChainConfig, timeout time.Duration, logger *slog.Logger) error {
+	if chain.ChainID == 0 {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	id, err := p.ChainID(ctx)
+	if err != nil {
+		logger.Warn("Error getting the chain id of the node", slog.String("error", err.Error()))
+		return nil
+	}
+	if id != int64(chain.ChainID) {
+		return fmt.Errorf("chain %s: the node serves chain id %d, want %d", chain.Name, id, chain.ChainID)
+	}
+	return nil
+}
+
 func initConfig() error {
 	// Load configuration from environment variables.
 	viper.SetConfigName("config")
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"log/slog"
+	"net/http"
+	"os"
+	"testing"
+
+	"github.com/pmes126/tx-parser-service/internal/store"
+	"github.com/pmes126/tx-parser-service/pkg/labels"
+	"github.com/pmes126/tx-parser-service/pkg/parser"
+)
+
+func TestFollowName(t *testing.T) {
+	const (
+		previous = "0x1111111111111111111111111111111111111111"
+		current  = "0xd8da6bf26964af9d7eed9e03e53415d37aa96045"
+	)
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+	// the resolver reports lower case addresses, the strict validation needing their checksum.
+	p := parser.NewEthTxParser(store.NewMemTxStore[parser.EthTransaction](), &http.Client{}, logger, 0, parser.WithStrictAddresses(true))
+	p.Subscribe(previous)
+	registry := labels.NewRegistry(labels.Entry{Address: previous, Label: "alice.eth"})
+
+	followName(p, registry)("alice.eth", previous, current)
+
+	if _, err := p.GetTransactions(previous); err != parser.ErrAddressNotTracked {
+		t.Errorf("EthTxParser.GetTransactions(previous) error = %v, want %v", err, parser.ErrAddressNotTracked)
+	}
+	// the address is tracked, no transaction being stored yet.
+	if _, err := p.GetTransactions(current); err == parser.ErrAddressNotTracked {
+		t.Errorf("EthTxParser.GetTransactions(current) error = %v, want a tracked address", err)
+	}
+	if got := registry.Label(previous); got != "" {
+		t.Errorf("Registry.Label(previous) = %v, want none", got)
+	}
+	if got := registry.Label(current); got != "alice.eth" {
+		t.Errorf("Registry.Label(current) = %v, want alice.eth", got)
+	}
+}
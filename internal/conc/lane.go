@@ -0,0 +1,103 @@
+package conc
+
+import (
+	"errors"
+	"fmt"
+)
+
+// DefaultLane is the name of the single lane of a WorkerPool created without WithLanes.
+const DefaultLane = "default"
+
+var ErrUnknownLane = errors.New("unknown worker pool lane")
+
+// Lane is a priority queue of a WorkerPool. The workers take the tasks of the lanes with queued
+// tasks in proportion to their weights, so a heavier lane is served first without starving the others.
+type Lane struct {
+	Name string
+	// Weight is the share of the workers given to the lane, values below 1 mean 1.
+	Weight int
+}
+
+// LaneStats is a snapshot of the queue of a lane.
+type LaneStats struct {
+	Name     string `json:"name"`
+	Weight   int    `json:"weight"`
+	Depth    int    `json:"depth"`
+	Capacity int    `json:"capacity"`
+}
+
+// WithLanes replaces the default lane with the given lanes, each with its own queue of the pool
+// buffer size. PushTask pushes into the first lane.
+func WithLanes(lanes ...Lane) Option {
+	return func(c *config) {
+		c.lanes = lanes
+	}
+}
+
+// WithReplayLane makes Replay push the dead-letter tasks into the given lane instead of the
+// lane they were pushed into.
+func WithReplayLane(name string) Option {
+	return func(c *config) {
+		c.replayLane = name
+	}
+}
+
+type lane[I any] struct {
+	name   string
+	weight int
+	in     In[I]
+	// current is the smooth weighted round-robin credit of the lane, only used by the dispatcher.
+	current int
+}
+
+// queued is a task taken from a lane by the dispatcher, along with the name of the lane.
+type queued[I any] struct {
+	task I
+	lane string
+}
+
+// newLanes creates the queues of the lanes, it panics on duplicate lane names.
+func newLanes[I any](lanes []Lane, bufferSize int) []*lane[I] {
+	if len(lanes) == 0 {
+		lanes = []Lane{{Name: DefaultLane, Weight: 1}}
+	}
+	res := make([]*lane[I], 0, len(lanes))
+	seen := make(map[string]bool, len(lanes))
+	for _, l := range lanes {
+		if seen[l.Name] {
+			panic(fmt.Sprintf("conc: duplicate worker pool lane %q", l.Name))
+		}
+		seen[l.Name] = true
+		res = append(res, &lane[I]{name: l.Name, weight: max(l.Weight, 1), in: make(In[I], bufferSize)})
+	}
+	return res
+}
+
+// next takes a task from the lanes using smooth weighted round-robin among the lanes with queued
+// tasks. It returns false when every lane is empty.
+func next[I any](lanes []*lane[I]) (queued[I], bool) {
+	for {
+		var best *lane[I]
+		total := 0
+		for _, l := range lanes {
+			if len(l.in) == 0 {
+				continue
+			}
+			l.current += l.weight
+			total += l.weight
+			if best == nil || l.current > best.current {
+				best = l
+			}
+		}
+		if best == nil {
+			return queued[I]{}, false
+		}
+		best.current -= total
+		select {
+		case task := <-best.in:
+			return queued[I]{task: task, lane: best.name}, true
+		default:
+			// the task was evicted by a concurrent drop-oldest push, pick again.
+		}
+	}
+}
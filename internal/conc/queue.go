@@ -0,0 +1,56 @@
+package conc
+
+import (
+	"errors"
+	"fmt"
+)
+
+// QueuePolicy decides what happens when a task is pushed into a full queue.
+type QueuePolicy int
+
+const (
+	// QueueBlock waits for room in the queue.
+	QueueBlock QueuePolicy = iota
+	// QueueDropOldest evicts the oldest queued task to the dead-letter queue to make room.
+	QueueDropOldest
+	// QueueReject fails the push with ErrQueueFull.
+	QueueReject
+)
+
+var (
+	ErrQueueFull   = errors.New("worker pool queue is full")
+	ErrTaskDropped = errors.New("task dropped from a full queue")
+)
+
+// ParseQueuePolicy parses the name of a queue policy: block, drop-oldest or reject.
+func ParseQueuePolicy(name string) (QueuePolicy, error) {
+	switch name {
+	case "", "block":
+		return QueueBlock, nil
+	case "drop-oldest":
+		return QueueDropOldest, nil
+	case "reject":
+		return QueueReject, nil
+	}
+	return QueueBlock, fmt.Errorf("unknown queue policy %q", name)
+}
+
+func (p QueuePolicy) String() string {
+	switch p {
+	case QueueDropOldest:
+		return "drop-oldest"
+	case QueueReject:
+		return "reject"
+	}
+	return "block"
+}
+
+// QueueStats is a snapshot of the state of the queue of a WorkerPool.
+type QueueStats struct {
+	Depth    int         `json:"depth"`
+	Capacity int         `json:"capacity"`
+	Dropped  int64       `json:"dropped"`
+	Policy   string      `json:"policy"`
+	Workers  int         `json:"workers"`
+	Lanes    []LaneStats `json:"lanes"`
+}
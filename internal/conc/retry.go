@@ -0,0 +1,81 @@
+package conc
+
+import (
+	"errors"
+	"math/rand/v2"
+	"time"
+)
+
+// RetryPolicy controls how many times and how often a failed task is retried.
+type RetryPolicy struct {
+	// MaxAttempts is the total number of attempts of a task, values below 1 mean a single attempt.
+	MaxAttempts int
+	// BaseDelay is the backoff before the first retry, doubled on each following retry.
+	BaseDelay time.Duration
+	// MaxDelay caps the backoff between two attempts.
+	MaxDelay time.Duration
+	// Retryable classifies the errors worth retrying, nil retries every error not marked Permanent.
+	Retryable func(error) bool
+	// Requeue pushes the tasks failing all of their attempts with a retryable error back into the
+	// replay lane after MaxDelay, instead of the dead-letter queue, e.g. when the tasks after them
+	// wait for them to succeed.
+	Requeue bool
+}
+
+// DeadLetter is a task that failed all of its attempts.
+type DeadLetter[I any] struct {
+	Task     I
+	Lane     string
+	Err      error
+	Attempts int
+	FailedAt time.Time
+}
+
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string { return e.err.Error() }
+
+func (e *permanentError) Unwrap() error { return e.err }
+
+// Permanent marks an error returned by a Job as not retryable.
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &permanentError{err: err}
+}
+
+// IsPermanent reports whether an error was marked with Permanent.
+func IsPermanent(err error) bool {
+	var pe *permanentError
+	return errors.As(err, &pe)
+}
+
+func (p RetryPolicy) retryable(err error) bool {
+	if IsPermanent(err) {
+		return false
+	}
+	if p.Retryable == nil {
+		return true
+	}
+	return p.Retryable(err)
+}
+
+// backoff returns the delay before the given retry, exponential in the attempt with jitter
+// in [delay/2, delay] so that tasks failing together do not retry together.
+func (p RetryPolicy) backoff(attempt int) time.Duration {
+	if p.BaseDelay <= 0 {
+		return 0
+	}
+	delay := p.BaseDelay
+	for i := 1; i < attempt && (p.MaxDelay <= 0 || delay < p.MaxDelay); i++ {
+		delay *= 2
+	}
+	if p.MaxDelay > 0 && delay > p.MaxDelay {
+		delay = p.MaxDelay
+	}
+	half := delay / 2
+	return half + rand.N(delay-half+1)
+}
@@ -2,74 +2,281 @@ package conc
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"sync"
+	"sync/atomic"
+	"time"
 )
 
+var ErrPoolClosed = errors.New("worker pool is closed")
+
 // In is used from the WorkerPool for adding tasks to the worker pool.
 type In[I any] chan I
 
+// Result is the outcome of a task run by the workers, with the time it took including retries.
+type Result[I, O any] struct {
+	Task     I
+	Value    O
+	Err      error
+	Duration time.Duration
+}
+
 // Out is used from the WorkerPool for returning Result.
-type Out chan error
+type Out[I, O any] chan Result[I, O]
+
+// Job is the job run by the workers in the pool. I is the input of the job and O is the output type.
+type Job[I, O any] func(ctx context.Context, task I) (O, error)
+
+// Option configures optional behaviour of a WorkerPool.
+type Option func(*config)
+
+type config struct {
+	retry       RetryPolicy
+	queuePolicy QueuePolicy
+	lanes       []Lane
+	replayLane  string
+}
+
+// WithRetryPolicy retries failed tasks according to p before moving them to the dead-letter queue.
+func WithRetryPolicy(p RetryPolicy) Option {
+	return func(c *config) {
+		c.retry = p
+	}
+}
 
-// Job is the job run by the workers in the pool. I is the input of the job and T is the output type.
-type Job[I any] func(ctx context.Context, task I) error
+// WithQueuePolicy sets the behaviour of PushTask when the queue is full, QueueBlock by default.
+func WithQueuePolicy(p QueuePolicy) Option {
+	return func(c *config) {
+		c.queuePolicy = p
+	}
+}
 
 // WorkerPool creates maxWorkers goroutines to handle incoming tasks.
-type WorkerPool[I any] struct {
+type WorkerPool[I, O any] struct {
 	maxWorkers int
-	job        Job[I]
+	job        Job[I, O]
 	wg         *sync.WaitGroup
-	in         In[I]
-	out        Out
+	lanes      []*lane[I]
+	laneIndex  map[string]*lane[I]
+	// work hands the tasks picked from the lanes by the dispatcher to the workers.
+	work chan queued[I]
+	// notify wakes up the dispatcher when a task is pushed or the pool is closed.
+	notify      chan struct{}
+	out         Out[I, O]
+	cfg         config
+	deadLetters []DeadLetter[I]
+	dropped     int64
+	mx          sync.Mutex
+	// closeMx guards closing the pool, pushes hold it for reading so that it is never closed under them.
+	closeMx sync.RWMutex
+	closed  atomic.Bool
+	cancel  context.CancelFunc
+	// ctx is the context of the running workers and quits their stop channels, used to resize the pool.
+	ctx   context.Context
+	quits []chan struct{}
 }
 
 // NewWorkerPool creates new WorkerPool with max workers and buffer size for input/output channels.
-func NewWorkerPool[I any](
+func NewWorkerPool[I, O any](
 	maxWorkers int,
-	job Job[I],
+	job Job[I, O],
 	bufferSize int,
-) *WorkerPool[I] {
-	return &WorkerPool[I]{
+	opts ...Option,
+) *WorkerPool[I, O] {
+	wp := &WorkerPool[I, O]{
 		maxWorkers: maxWorkers,
 		job:        job,
 		wg:         &sync.WaitGroup{},
-		in:         make(In[I], bufferSize),
-		out:        make(Out, bufferSize),
+		work:       make(chan queued[I]),
+		notify:     make(chan struct{}, 1),
+		out:        make(Out[I, O], bufferSize),
+		cancel:     func() {},
 	}
+	for _, opt := range opts {
+		opt(&wp.cfg)
+	}
+	wp.lanes = newLanes[I](wp.cfg.lanes, bufferSize)
+	wp.laneIndex = make(map[string]*lane[I], len(wp.lanes))
+	for _, l := range wp.lanes {
+		wp.laneIndex[l.name] = l
+	}
+	return wp
 }
 
-// PushTask used to push tasks into the worker pool.
-func (wp *WorkerPool[I]) PushTask(task I) {
-	wp.in <- task
+// PushTask used to push tasks into the first lane of the worker pool. When the queue is full it
+// waits, evicts the oldest task or fails with ErrQueueFull depending on the queue policy. It returns
+// the context error if the context is done while waiting and ErrPoolClosed once the pool is shut down.
+func (wp *WorkerPool[I, O]) PushTask(ctx context.Context, task I) error {
+	return wp.PushTaskTo(ctx, wp.lanes[0].name, task)
 }
 
-// CloseInputChannel is used to indicate no more incoming tasks.
-func (wp *WorkerPool[I]) CloseInputChannel() {
-	close(wp.in)
+// PushTaskTo is PushTask for the given lane, it returns ErrUnknownLane if the pool has no such lane.
+func (wp *WorkerPool[I, O]) PushTaskTo(ctx context.Context, lane string, task I) error {
+	if wp.cfg.queuePolicy != QueueBlock {
+		return wp.TryPushTo(lane, task)
+	}
+	l, ok := wp.laneIndex[lane]
+	if !ok {
+		return fmt.Errorf("%w: %q", ErrUnknownLane, lane)
+	}
+	wp.closeMx.RLock()
+	defer wp.closeMx.RUnlock()
+	if wp.closed.Load() {
+		return ErrPoolClosed
+	}
+	select {
+	case l.in <- task:
+		wp.wake()
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
 }
 
-// Start starts the workerPool by creating maxWorkers goroutines and a goroutine to watch the wait group
-// it accepts a context to receive termination signals an returns the out channel that can be used to return
-// results to the callers
-func (wp *WorkerPool[I]) Start(ctx context.Context) Out {
-	for i := 0; i < wp.maxWorkers; i++ {
-		wp.wg.Add(1)
-		go func() {
-			defer wp.wg.Done()
-			for {
+// TryPush pushes a task into the first lane of the worker pool without waiting. When the queue is
+// full the oldest task is evicted under QueueDropOldest, otherwise ErrQueueFull is returned.
+func (wp *WorkerPool[I, O]) TryPush(task I) error {
+	return wp.TryPushTo(wp.lanes[0].name, task)
+}
+
+// TryPushTo is TryPush for the given lane, it returns ErrUnknownLane if the pool has no such lane.
+func (wp *WorkerPool[I, O]) TryPushTo(lane string, task I) error {
+	l, ok := wp.laneIndex[lane]
+	if !ok {
+		return fmt.Errorf("%w: %q", ErrUnknownLane, lane)
+	}
+	wp.closeMx.RLock()
+	defer wp.closeMx.RUnlock()
+	if wp.closed.Load() {
+		return ErrPoolClosed
+	}
+	for {
+		select {
+		case l.in <- task:
+			wp.wake()
+			return nil
+		default:
+		}
+		if wp.cfg.queuePolicy != QueueDropOldest {
+			return ErrQueueFull
+		}
+		select {
+		case old := <-l.in:
+			wp.mx.Lock()
+			wp.dropped++
+			wp.deadLetters = append(wp.deadLetters, DeadLetter[I]{Task: old, Lane: lane, Err: ErrTaskDropped, FailedAt: time.Now()})
+			wp.mx.Unlock()
+		default:
+		}
+	}
+}
+
+// Available returns the number of tasks that can be pushed into a lane without waiting, 0 for
+// an unknown lane.
+func (wp *WorkerPool[I, O]) Available(lane string) int {
+	l, ok := wp.laneIndex[lane]
+	if !ok {
+		return 0
+	}
+	return cap(l.in) - len(l.in)
+}
+
+// QueueStats returns the current depth of the queues and the number of evicted tasks.
+func (wp *WorkerPool[I, O]) QueueStats() QueueStats {
+	wp.mx.Lock()
+	defer wp.mx.Unlock()
+	stats := QueueStats{
+		Dropped: wp.dropped,
+		Policy:  wp.cfg.queuePolicy.String(),
+		Workers: wp.maxWorkers,
+	}
+	for _, l := range wp.lanes {
+		stats.Depth += len(l.in)
+		stats.Capacity += cap(l.in)
+		stats.Lanes = append(stats.Lanes, LaneStats{Name: l.name, Weight: l.weight, Depth: len(l.in), Capacity: cap(l.in)})
+	}
+	return stats
+}
+
+// CloseInputChannel is used to indicate no more incoming tasks. The queued tasks are still run.
+func (wp *WorkerPool[I, O]) CloseInputChannel() {
+	wp.closeMx.Lock()
+	defer wp.closeMx.Unlock()
+	if !wp.closed.Load() {
+		wp.closed.Store(true)
+		wp.wake()
+	}
+}
+
+// wake notifies the dispatcher of a pushed task or of the pool being closed.
+func (wp *WorkerPool[I, O]) wake() {
+	select {
+	case wp.notify <- struct{}{}:
+	default:
+	}
+}
+
+// dispatch hands the queued tasks to the workers, picking the lanes by weight, until the pool is
+// closed and every lane is drained or the context is done.
+func (wp *WorkerPool[I, O]) dispatch(ctx context.Context) {
+	defer close(wp.work)
+	for {
+		task, ok := next(wp.lanes)
+		if !ok {
+			// once closed no push is in flight, so empty lanes stay empty.
+			if wp.closed.Load() {
+				if task, ok = next(wp.lanes); !ok {
+					return
+				}
+			} else {
 				select {
-				case task, ok := <-wp.in:
-					if !ok {
-						return
-					}
-					err := wp.job(ctx, task)
-					wp.out <- err
+				case <-wp.notify:
+					continue
 				case <-ctx.Done():
 					return
 				}
 			}
-		}()
+		}
+		select {
+		case wp.work <- task:
+		case <-ctx.Done():
+			return
+		}
 	}
+}
+
+// Shutdown stops accepting tasks and waits for the workers to drain the queue. If the context is
+// done first the workers are stopped, abandoning the remaining tasks, and the context error is returned.
+func (wp *WorkerPool[I, O]) Shutdown(ctx context.Context) error {
+	wp.CloseInputChannel()
+	drained := make(chan struct{})
+	go func() {
+		wp.wg.Wait()
+		close(drained)
+	}()
+	select {
+	case <-drained:
+		return nil
+	case <-ctx.Done():
+		wp.mx.Lock()
+		wp.cancel()
+		wp.mx.Unlock()
+		return ctx.Err()
+	}
+}
+
+// Start starts the workerPool by creating maxWorkers goroutines, the dispatcher feeding them from the
+// lanes and a goroutine to watch the wait group it accepts a context to receive termination signals
+// an returns the out channel that can be used to return results to the callers
+func (wp *WorkerPool[I, O]) Start(ctx context.Context) Out[I, O] {
+	wp.mx.Lock()
+	wp.ctx, wp.cancel = context.WithCancel(ctx)
+	for i := 0; i < wp.maxWorkers; i++ {
+		wp.spawn()
+	}
+	go wp.dispatch(wp.ctx)
+	wp.mx.Unlock()
 
 	go func() {
 		wp.wg.Wait()
@@ -78,3 +285,157 @@ func (wp *WorkerPool[I]) Start(ctx context.Context) Out {
 
 	return wp.out
 }
+
+// Resize grows or shrinks the number of workers to n, at least one. Removed workers finish their
+// current task before exiting.
+func (wp *WorkerPool[I, O]) Resize(n int) {
+	if n < 1 {
+		n = 1
+	}
+	wp.mx.Lock()
+	defer wp.mx.Unlock()
+	wp.maxWorkers = n
+	// Nothing to resize before Start, nor once the workers are exiting.
+	if wp.ctx == nil || wp.ctx.Err() != nil || wp.closed.Load() {
+		return
+	}
+	for len(wp.quits) < n {
+		wp.spawn()
+	}
+	for len(wp.quits) > n {
+		last := len(wp.quits) - 1
+		close(wp.quits[last])
+		wp.quits = wp.quits[:last]
+	}
+}
+
+// Workers returns the number of workers of the pool.
+func (wp *WorkerPool[I, O]) Workers() int {
+	wp.mx.Lock()
+	defer wp.mx.Unlock()
+	return wp.maxWorkers
+}
+
+// spawn starts a worker, the caller must hold wp.mx.
+func (wp *WorkerPool[I, O]) spawn() {
+	ctx := wp.ctx
+	quit := make(chan struct{})
+	wp.quits = append(wp.quits, quit)
+	wp.wg.Add(1)
+	go func() {
+		defer wp.wg.Done()
+		for {
+			select {
+			case task, ok := <-wp.work:
+				if !ok {
+					return
+				}
+				res := wp.run(ctx, task)
+				select {
+				case wp.out <- res:
+				case <-ctx.Done():
+					return
+				}
+			case <-quit:
+				return
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+}
+
+// run runs the job of a task, retrying it with backoff according to the retry policy. A task
+// failing all of its attempts is moved to the dead-letter queue, unless it was cancelled, e.g. on
+// shutdown, the task not having failed, or it is requeued by the policy.
+func (wp *WorkerPool[I, O]) run(ctx context.Context, q queued[I]) Result[I, O] {
+	policy := wp.cfg.retry
+	task := q.task
+	start := time.Now()
+	for attempt := 1; ; attempt++ {
+		value, err := wp.job(ctx, task)
+		if err == nil {
+			return Result[I, O]{Task: task, Value: value, Duration: time.Since(start)}
+		}
+		retryable := policy.retryable(err)
+		if attempt >= policy.MaxAttempts || !retryable || !Sleep(ctx, policy.backoff(attempt)) {
+			dl := DeadLetter[I]{Task: task, Lane: q.lane, Err: err, Attempts: attempt, FailedAt: time.Now()}
+			switch {
+			case ctx.Err() != nil || errors.Is(err, context.Canceled):
+			case retryable && policy.Requeue && wp.cfg.replayLane != "":
+				go wp.requeue(ctx, dl, policy.MaxDelay)
+			default:
+				wp.deadLetter(dl)
+			}
+			return Result[I, O]{
+				Task:     task,
+				Value:    value,
+				Err:      fmt.Errorf("task failed after %d attempt(s): %w", attempt, err),
+				Duration: time.Since(start),
+			}
+		}
+	}
+}
+
+// Sleep waits for d, returning false if the context is done first.
+func Sleep(ctx context.Context, d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// requeue pushes a task that failed all of its attempts into the replay lane after delay, it is
+// dead-lettered if the lane does not take it. A requeue on shutdown is dropped.
+func (wp *WorkerPool[I, O]) requeue(ctx context.Context, dl DeadLetter[I], delay time.Duration) {
+	if !Sleep(ctx, delay) {
+		return
+	}
+	err := wp.PushTaskTo(ctx, wp.cfg.replayLane, dl.Task)
+	if err != nil && !errors.Is(err, ErrPoolClosed) && ctx.Err() == nil {
+		wp.deadLetter(dl)
+	}
+}
+
+// deadLetter moves a task to the dead-letter queue.
+func (wp *WorkerPool[I, O]) deadLetter(dl DeadLetter[I]) {
+	wp.mx.Lock()
+	defer wp.mx.Unlock()
+	wp.deadLetters = append(wp.deadLetters, dl)
+}
+
+// DeadLetters returns a snapshot of the tasks that failed all of their attempts.
+func (wp *WorkerPool[I, O]) DeadLetters() []DeadLetter[I] {
+	wp.mx.Lock()
+	defer wp.mx.Unlock()
+	res := make([]DeadLetter[I], len(wp.deadLetters))
+	copy(res, wp.deadLetters)
+	return res
+}
+
+// Replay removes the tasks from the dead-letter queue and pushes them back into the pool, into the
+// replay lane if set or else the lane they were pushed into. It returns the number of replayed
+// tasks, the ones that could not be pushed are put back in the dead-letter queue.
+func (wp *WorkerPool[I, O]) Replay(ctx context.Context) (int, error) {
+	wp.mx.Lock()
+	deadLetters := wp.deadLetters
+	wp.deadLetters = nil
+	wp.mx.Unlock()
+	for i, dl := range deadLetters {
+		lane := dl.Lane
+		if wp.cfg.replayLane != "" {
+			lane = wp.cfg.replayLane
+		}
+		if err := wp.PushTaskTo(ctx, lane, dl.Task); err != nil {
+			wp.mx.Lock()
+			wp.deadLetters = append(wp.deadLetters, deadLetters[i:]...)
+			wp.mx.Unlock()
+			return i, err
+		}
+	}
+	return len(deadLetters), nil
+}
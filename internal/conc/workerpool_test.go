@@ -0,0 +1,329 @@
+package conc
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+)
+
+var errTransient = errors.New("transient")
+
+func TestWorkerPool_Retry(t *testing.T) {
+	tests := []struct {
+		name            string
+		failures        int
+		err             error
+		wantErr         bool
+		wantAttempts    int
+		wantDeadLetters int
+	}{
+		{name: "Test success after retries", failures: 2, err: errTransient, wantErr: false, wantAttempts: 3},
+		{name: "Test attempts exhausted", failures: 5, err: errTransient, wantErr: true, wantAttempts: 3, wantDeadLetters: 1},
+		{name: "Test permanent error", failures: 5, err: Permanent(errTransient), wantErr: true, wantAttempts: 1, wantDeadLetters: 1},
+		{name: "Test cancelled", failures: 5, err: errors.Join(errTransient, context.Canceled), wantErr: true, wantAttempts: 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var mx sync.Mutex
+			attempts := 0
+			job := func(ctx context.Context, task int) (int, error) {
+				mx.Lock()
+				defer mx.Unlock()
+				attempts++
+				if attempts <= tt.failures {
+					return 0, tt.err
+				}
+				return task * 2, nil
+			}
+			wp := NewWorkerPool(1, job, 1, WithRetryPolicy(RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond, MaxDelay: 2 * time.Millisecond}))
+			out := wp.Start(context.Background())
+			wp.PushTask(context.Background(), 42)
+			res := <-out
+			err := res.Err
+			wp.CloseInputChannel()
+			if res.Task != 42 || (err == nil && res.Value != 84) {
+				t.Errorf("WorkerPool.run() result = %+v, want task 42 and value 84", res)
+			}
+			if (err != nil) != tt.wantErr {
+				t.Errorf("WorkerPool.run() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil && !errors.Is(err, errTransient) {
+				t.Errorf("WorkerPool.run() error = %v, want wrapped %v", err, errTransient)
+			}
+			if attempts != tt.wantAttempts {
+				t.Errorf("WorkerPool.run() attempts = %v, want %v", attempts, tt.wantAttempts)
+			}
+			deadLetters := wp.DeadLetters()
+			if len(deadLetters) != tt.wantDeadLetters {
+				t.Fatalf("WorkerPool.DeadLetters() = %v, want %v", len(deadLetters), tt.wantDeadLetters)
+			}
+			if len(deadLetters) == 1 && (deadLetters[0].Task != 42 || deadLetters[0].Attempts != tt.wantAttempts) {
+				t.Errorf("WorkerPool.DeadLetters() = %+v, want task 42 after %d attempts", deadLetters[0], tt.wantAttempts)
+			}
+		})
+	}
+}
+
+func TestWorkerPool_Replay(t *testing.T) {
+	fail := true
+	var mx sync.Mutex
+	job := func(ctx context.Context, task int) (struct{}, error) {
+		mx.Lock()
+		defer mx.Unlock()
+		if fail {
+			return struct{}{}, errTransient
+		}
+		return struct{}{}, nil
+	}
+	wp := NewWorkerPool(1, job, 2)
+	out := wp.Start(context.Background())
+	defer wp.CloseInputChannel()
+	wp.PushTask(context.Background(), 1)
+	if res := <-out; res.Err == nil {
+		t.Fatalf("WorkerPool.run() error = nil, want %v", errTransient)
+	}
+	mx.Lock()
+	fail = false
+	mx.Unlock()
+	if n, err := wp.Replay(context.Background()); n != 1 || err != nil {
+		t.Fatalf("WorkerPool.Replay() = %v, %v, want %v", n, err, 1)
+	}
+	if res := <-out; res.Err != nil {
+		t.Errorf("WorkerPool.run() replay error = %v", res.Err)
+	}
+	if n := len(wp.DeadLetters()); n != 0 {
+		t.Errorf("WorkerPool.DeadLetters() = %v, want %v", n, 0)
+	}
+}
+
+func TestRetryPolicy_Backoff(t *testing.T) {
+	p := RetryPolicy{BaseDelay: 100 * time.Millisecond, MaxDelay: time.Second}
+	tests := []struct {
+		attempt int
+		max     time.Duration
+	}{
+		{attempt: 1, max: 100 * time.Millisecond},
+		{attempt: 2, max: 200 * time.Millisecond},
+		{attempt: 3, max: 400 * time.Millisecond},
+		{attempt: 10, max: time.Second},
+	}
+	for _, tt := range tests {
+		for i := 0; i < 20; i++ {
+			got := p.backoff(tt.attempt)
+			if got < tt.max/2 || got > tt.max {
+				t.Errorf("RetryPolicy.backoff(%d) = %v, want in [%v, %v]", tt.attempt, got, tt.max/2, tt.max)
+			}
+		}
+	}
+}
+
+func TestWorkerPool_QueuePolicy(t *testing.T) {
+	tests := []struct {
+		name        string
+		policy      QueuePolicy
+		wantErr     error
+		wantDepth   int
+		wantDropped int64
+	}{
+		{name: "Test block", policy: QueueBlock, wantErr: context.DeadlineExceeded, wantDepth: 2},
+		{name: "Test reject", policy: QueueReject, wantErr: ErrQueueFull, wantDepth: 2},
+		{name: "Test drop oldest", policy: QueueDropOldest, wantErr: nil, wantDepth: 2, wantDropped: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// The pool is not started so the queue fills up.
+			wp := NewWorkerPool(1, func(ctx context.Context, task int) (int, error) { return task, nil }, 2, WithQueuePolicy(tt.policy))
+			for i := 0; i < 2; i++ {
+				if err := wp.TryPush(i); err != nil {
+					t.Fatalf("WorkerPool.TryPush() error = %v", err)
+				}
+			}
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+			defer cancel()
+			if err := wp.PushTask(ctx, 2); !errors.Is(err, tt.wantErr) {
+				t.Errorf("WorkerPool.PushTask() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			stats := wp.QueueStats()
+			if stats.Depth != tt.wantDepth || stats.Capacity != 2 || stats.Dropped != tt.wantDropped || stats.Policy != tt.policy.String() {
+				t.Errorf("WorkerPool.QueueStats() = %+v, want depth %d dropped %d", stats, tt.wantDepth, tt.wantDropped)
+			}
+			if tt.wantDropped > 0 {
+				deadLetters := wp.DeadLetters()
+				if len(deadLetters) != 1 || deadLetters[0].Task != 0 || !errors.Is(deadLetters[0].Err, ErrTaskDropped) {
+					t.Errorf("WorkerPool.DeadLetters() = %+v, want task 0 dropped", deadLetters)
+				}
+			}
+		})
+	}
+}
+
+func TestWorkerPool_Shutdown(t *testing.T) {
+	tests := []struct {
+		name     string
+		jobDelay time.Duration
+		timeout  time.Duration
+		wantErr  error
+		wantDone int
+	}{
+		{name: "Test queue drained", jobDelay: time.Millisecond, timeout: time.Second, wantDone: 5},
+		{name: "Test deadline exceeded", jobDelay: time.Second, timeout: 20 * time.Millisecond, wantErr: context.DeadlineExceeded},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			job := func(ctx context.Context, task int) (int, error) {
+				select {
+				case <-time.After(tt.jobDelay):
+				case <-ctx.Done():
+					return 0, ctx.Err()
+				}
+				return task, nil
+			}
+			wp := NewWorkerPool(1, job, 5)
+			out := wp.Start(context.Background())
+			for i := 0; i < 5; i++ {
+				if err := wp.PushTask(context.Background(), i); err != nil {
+					t.Fatalf("WorkerPool.PushTask() error = %v", err)
+				}
+			}
+			done := 0
+			collected := make(chan struct{})
+			go func() {
+				for res := range out {
+					if res.Err == nil {
+						done++
+					}
+				}
+				close(collected)
+			}()
+			ctx, cancel := context.WithTimeout(context.Background(), tt.timeout)
+			defer cancel()
+			if err := wp.Shutdown(ctx); !errors.Is(err, tt.wantErr) {
+				t.Errorf("WorkerPool.Shutdown() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			<-collected
+			if done != tt.wantDone {
+				t.Errorf("WorkerPool.Shutdown() completed tasks = %v, want %v", done, tt.wantDone)
+			}
+			if err := wp.PushTask(context.Background(), 6); !errors.Is(err, ErrPoolClosed) {
+				t.Errorf("WorkerPool.PushTask() after shutdown error = %v, want %v", err, ErrPoolClosed)
+			}
+		})
+	}
+}
+
+func TestWorkerPool_Resize(t *testing.T) {
+	var mx sync.Mutex
+	running, peak := 0, 0
+	release := make(chan struct{})
+	job := func(ctx context.Context, task int) (int, error) {
+		mx.Lock()
+		running++
+		if running > peak {
+			peak = running
+		}
+		mx.Unlock()
+		<-release
+		mx.Lock()
+		running--
+		mx.Unlock()
+		return task, nil
+	}
+	wp := NewWorkerPool(1, job, 10)
+	out := wp.Start(context.Background())
+	wp.Resize(4)
+	if n := wp.Workers(); n != 4 {
+		t.Errorf("WorkerPool.Workers() = %v, want %v", n, 4)
+	}
+	for i := 0; i < 8; i++ {
+		wp.PushTask(context.Background(), i)
+	}
+	// Let the workers pick up their tasks.
+	time.Sleep(20 * time.Millisecond)
+	wp.Resize(0)
+	if n := wp.Workers(); n != 1 {
+		t.Errorf("WorkerPool.Workers() = %v, want %v", n, 1)
+	}
+	close(release)
+	for i := 0; i < 8; i++ {
+		<-out
+	}
+	if peak != 4 {
+		t.Errorf("WorkerPool peak concurrency = %v, want %v", peak, 4)
+	}
+	wp.Shutdown(context.Background())
+}
+
+func TestWorkerPool_Lanes(t *testing.T) {
+	job := func(ctx context.Context, task int) (int, error) { return task, nil }
+	wp := NewWorkerPool(1, job, 4,
+		WithLanes(Lane{Name: "live", Weight: 3}, Lane{Name: "backfill", Weight: 1}),
+	)
+	// The pool is not started so that both lanes are queued before the first pick.
+	for i := 0; i < 4; i++ {
+		if err := wp.PushTaskTo(context.Background(), "backfill", 10+i); err != nil {
+			t.Fatalf("WorkerPool.PushTaskTo() error = %v", err)
+		}
+		if err := wp.PushTaskTo(context.Background(), "live", i); err != nil {
+			t.Fatalf("WorkerPool.PushTaskTo() error = %v", err)
+		}
+	}
+	if err := wp.PushTaskTo(context.Background(), "replay", 0); !errors.Is(err, ErrUnknownLane) {
+		t.Errorf("WorkerPool.PushTaskTo() error = %v, want %v", err, ErrUnknownLane)
+	}
+	stats := wp.QueueStats()
+	if stats.Depth != 8 || stats.Capacity != 8 || len(stats.Lanes) != 2 || stats.Lanes[0].Depth != 4 {
+		t.Errorf("WorkerPool.QueueStats() = %+v, want 2 lanes of depth 4", stats)
+	}
+	if n := wp.Available("backfill"); n != 0 {
+		t.Errorf("WorkerPool.Available() = %v, want %v", n, 0)
+	}
+
+	out := wp.Start(context.Background())
+	wp.CloseInputChannel()
+	var got []int
+	for res := range out {
+		got = append(got, res.Task)
+	}
+	// Smooth weighted round-robin serves the live lane three times out of four while both have tasks.
+	want := []int{0, 1, 10, 2, 3, 11, 12, 13}
+	if !slices.Equal(got, want) {
+		t.Errorf("WorkerPool lanes order = %v, want %v", got, want)
+	}
+}
+
+func TestWorkerPool_RetryRequeue(t *testing.T) {
+	var mx sync.Mutex
+	attempts := 0
+	job := func(ctx context.Context, task int) (int, error) {
+		mx.Lock()
+		defer mx.Unlock()
+		attempts++
+		if attempts <= 4 {
+			return 0, errTransient
+		}
+		return task * 2, nil
+	}
+	wp := NewWorkerPool(1, job, 1,
+		WithRetryPolicy(RetryPolicy{MaxAttempts: 2, BaseDelay: time.Millisecond, MaxDelay: time.Millisecond, Requeue: true}),
+		WithLanes(Lane{Name: "live", Weight: 1}, Lane{Name: "replay", Weight: 1}),
+		WithReplayLane("replay"),
+	)
+	out := wp.Start(context.Background())
+	defer wp.CloseInputChannel()
+	wp.PushTask(context.Background(), 42)
+	// the task fails its two attempts twice, being requeued each time, then succeeds.
+	for i := 0; i < 2; i++ {
+		if res := <-out; !errors.Is(res.Err, errTransient) {
+			t.Fatalf("WorkerPool.run() error = %v, want %v", res.Err, errTransient)
+		}
+	}
+	if res := <-out; res.Err != nil || res.Value != 84 {
+		t.Errorf("WorkerPool.run() = %+v, want value 84", res)
+	}
+	if n := len(wp.DeadLetters()); n != 0 {
+		t.Errorf("WorkerPool.DeadLetters() = %v, want %v", n, 0)
+	}
+}
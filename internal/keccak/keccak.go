@@ -0,0 +1,19 @@
+// Package keccak computes the legacy Keccak-256 hash used by Ethereum, which differs from the
+// standardised SHA3-256 only in its padding byte.
+package keccak
+
+import (
+	"golang.org/x/crypto/sha3"
+)
+
+// Size is the size of a Keccak-256 digest in bytes.
+const Size = 32
+
+// Sum256 returns the Keccak-256 digest of data.
+func Sum256(data []byte) [Size]byte {
+	h := sha3.NewLegacyKeccak256()
+	h.Write(data)
+	var sum [Size]byte
+	h.Sum(sum[:0])
+	return sum
+}
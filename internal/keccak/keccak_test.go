@@ -0,0 +1,29 @@
+package keccak
+
+import (
+	"encoding/hex"
+	"strings"
+	"testing"
+)
+
+func TestSum256(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{name: "Test empty", input: "", want: "c5d2460186f7233c927e7db2dcc703c0e500b653ca82273b7bfad8045d85a470"},
+		{name: "Test Transfer event", input: "Transfer(address,address,uint256)", want: "ddf252ad1be2c89b69c2b068fc378daa952ba7f163c4a11628f55a4df523b3ef"},
+		{name: "Test fox", input: "The quick brown fox jumps over the lazy dog", want: "4d741b6f1eb29cb2a9b9911c82f56fa8d73b04959d3d9d222895df6c0b28aa15"},
+		{name: "Test multi block", input: strings.Repeat("ab", 150), want: "e4f997223d2ec1d99131efe9d1bcb0a06abc499b7b101c75abab626eb1fb7d72"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sum := Sum256([]byte(tt.input))
+			got := hex.EncodeToString(sum[:])
+			if got != tt.want {
+				t.Errorf("Sum256() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
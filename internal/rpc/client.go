@@ -0,0 +1,366 @@
+package rpc
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Version is the JSON-RPC version of the requests.
+const Version = "2.0"
+
+// Request is a JSON-RPC request.
+type Request struct {
+	Jsonrpc string        `json:"jsonrpc"`
+	Method  string        `json:"method"`
+	Params  []interface{} `json:"params"`
+	Id      uint64        `json:"id"`
+}
+
+// Response is a JSON-RPC response, holding either a result or an error. Id is nil when the node
+// could not read the id of the request.
+type Response struct {
+	Jsonrpc string          `json:"jsonrpc"`
+	Id      *uint64         `json:"id"`
+	Result  json.RawMessage `json:"result"`
+	Error   *RPCError       `json:"error"`
+}
+
+// BatchElem is a call of a batch. Result is decoded from the response and Err is set when the
+// call failed, either on its own or with the whole batch. A null result fails with ErrNullResult.
+type BatchElem struct {
+	Method string
+	Params []interface{}
+	Result interface{}
+	Err    error
+}
+
+// Option configures optional behaviour of a Client.
+type Option func(*Client)
+
+// WithBatchSize caps the number of calls sent in a single batch, larger batches are split.
+func WithBatchSize(n int) Option {
+	return func(c *Client) {
+		if n > 0 {
+			c.batchSize = n
+		}
+	}
+}
+
+// WithBatchWait makes the client wait up to d for concurrent calls to coalesce them into the
+// same batch, a batch is sent as soon as it is full.
+func WithBatchWait(d time.Duration) Option {
+	return func(c *Client) {
+		if d > 0 {
+			c.batchWait = d
+		}
+	}
+}
+
+// WithTimeouts sets the timeout of the requests, per method with a default for the others. The
+// method names are matched case insensitively. A batch gets the longest timeout of its calls.
+func WithTimeouts(timeout time.Duration, methods map[string]time.Duration) Option {
+	return func(c *Client) {
+		c.timeout = timeout
+		c.timeouts = make(map[string]time.Duration, len(methods))
+		for method, d := range methods {
+			c.timeouts[strings.ToLower(method)] = d
+		}
+	}
+}
+
+// WithFallbacks adds endpoints of the same chain used in turn when the current one fails.
+func WithFallbacks(urls ...string) Option {
+	return func(c *Client) {
+		c.urls = append(c.urls, urls...)
+	}
+}
+
+// Client is a JSON-RPC 2.0 client over HTTP supporting batch requests.
+type Client struct {
+	client *http.Client
+	// urls are the endpoints of the node, current is the index of the one in use.
+	urls      []string
+	current   atomic.Int32
+	batchSize int
+	batchWait time.Duration
+	timeout   time.Duration
+	timeouts  map[string]time.Duration
+	ids       atomic.Uint64
+	mx        sync.Mutex
+	queue     []*call
+	timer     *time.Timer
+}
+
+// call is a queued BatchCall waiting to be coalesced with the others.
+type call struct {
+	elems []*BatchElem
+	done  chan error
+	// sent is set once the call is taken from the queue, it can no longer be withdrawn.
+	sent bool
+}
+
+// NewClient creates a client sending its requests to url.
+func NewClient(client *http.Client, url string, opts ...Option) *Client {
+	c := &Client{
+		client:    client,
+		urls:      []string{url},
+		batchSize: 1,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// Call sends a single request and decodes its result into result.
+func (c *Client) Call(ctx context.Context, result interface{}, method string, params ...interface{}) error {
+	if params == nil {
+		params = []interface{}{}
+	}
+	elems := []BatchElem{{Method: method, Params: params, Result: result}}
+	if err := c.BatchCall(ctx, elems); err != nil {
+		return err
+	}
+	return elems[0].Err
+}
+
+// BatchCall sends the calls in as few batches of at most the batch size as possible, coalescing
+// them with concurrent calls when a batch wait is set. The responses are matched to the calls by
+// id and the error of each call is set in its Err. The returned error is the transport error of
+// the first batch that failed, the calls of a failed batch have it as their Err too.
+//
+// The requests are cancelled with ctx. Coalesced calls are sent with the other calls of their batch
+// though, so a call cancelled after its batch was sent waits for the batch, within its timeout.
+func (c *Client) BatchCall(ctx context.Context, elems []BatchElem) error {
+	if len(elems) == 0 {
+		return nil
+	}
+	ptrs := make([]*BatchElem, len(elems))
+	for i := range elems {
+		ptrs[i] = &elems[i]
+	}
+	if c.batchWait == 0 {
+		return c.send(ctx, ptrs)
+	}
+	cl := &call{elems: ptrs, done: make(chan error, 1)}
+	c.mx.Lock()
+	c.queue = append(c.queue, cl)
+	queued := 0
+	for _, q := range c.queue {
+		queued += len(q.elems)
+	}
+	if queued >= c.batchSize {
+		c.mx.Unlock()
+		c.flush()
+	} else {
+		if c.timer == nil {
+			c.timer = time.AfterFunc(c.batchWait, c.flush)
+		}
+		c.mx.Unlock()
+	}
+	select {
+	case err := <-cl.done:
+		return err
+	case <-ctx.Done():
+	}
+	c.mx.Lock()
+	if !cl.sent {
+		c.queue = slices.DeleteFunc(c.queue, func(q *call) bool { return q == cl })
+		c.mx.Unlock()
+		return ctx.Err()
+	}
+	c.mx.Unlock()
+	return <-cl.done
+}
+
+// flush sends the queued calls and notifies their callers.
+func (c *Client) flush() {
+	c.mx.Lock()
+	queue := c.queue
+	c.queue = nil
+	for _, cl := range queue {
+		cl.sent = true
+	}
+	if c.timer != nil {
+		c.timer.Stop()
+		c.timer = nil
+	}
+	c.mx.Unlock()
+	if len(queue) == 0 {
+		return
+	}
+	var elems []*BatchElem
+	for _, cl := range queue {
+		elems = append(elems, cl.elems...)
+	}
+	c.send(context.Background(), elems)
+	for _, cl := range queue {
+		var err error
+		for _, elem := range cl.elems {
+			var be *batchError
+			if errors.As(elem.Err, &be) {
+				err = be.err
+				break
+			}
+		}
+		cl.done <- err
+	}
+}
+
+// send posts the calls in chunks of the batch size, setting the error of every call. A chunk
+// failing as a whole is sent to the next endpoint, until each one has been tried.
+func (c *Client) send(ctx context.Context, elems []*BatchElem) error {
+	var first error
+	for start := 0; start < len(elems); start += c.batchSize {
+		chunk := elems[start:min(start+c.batchSize, len(elems))]
+		var err error
+		for range c.urls {
+			current := c.current.Load()
+			if err = c.post(ctx, c.urls[current], chunk); err == nil || ctx.Err() != nil {
+				break
+			}
+			// concurrent failures of the same endpoint only move to the next one once.
+			c.current.CompareAndSwap(current, (current+1)%int32(len(c.urls)))
+		}
+		if err != nil {
+			for _, elem := range chunk {
+				elem.Err = &batchError{err: err}
+			}
+			if first == nil {
+				first = err
+			}
+		}
+	}
+	return first
+}
+
+// timeoutOf returns the longest timeout of the calls, 0 if none is set.
+func (c *Client) timeoutOf(elems []*BatchElem) time.Duration {
+	var timeout time.Duration
+	for _, elem := range elems {
+		d, ok := c.timeouts[strings.ToLower(elem.Method)]
+		if !ok {
+			d = c.timeout
+		}
+		timeout = max(timeout, d)
+	}
+	return timeout
+}
+
+// post sends a batch, a single call being sent as a plain request, and decodes the responses
+// into the calls. The returned error is the transport error failing the whole batch.
+func (c *Client) post(ctx context.Context, url string, elems []*BatchElem) error {
+	if timeout := c.timeoutOf(elems); timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	reqs := make([]Request, len(elems))
+	index := make(map[uint64]*BatchElem, len(elems))
+	for i, elem := range elems {
+		reqs[i] = Request{Jsonrpc: Version, Method: elem.Method, Params: elem.Params, Id: c.ids.Add(1)}
+		index[reqs[i].Id] = elem
+		elem.Err = ErrMissingResponse
+	}
+	var body interface{} = reqs
+	if len(reqs) == 1 {
+		body = reqs[0]
+	}
+	reqBody, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewBuffer(reqBody))
+	if err != nil {
+		return err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpResp, err := c.client.Do(httpReq)
+	if err != nil {
+		return transportError(err)
+	}
+	defer httpResp.Body.Close()
+
+	respBody, err := io.ReadAll(httpResp.Body)
+	if err != nil {
+		return transportError(err)
+	}
+	if httpResp.StatusCode < 200 || httpResp.StatusCode > 299 {
+		return &HTTPError{StatusCode: httpResp.StatusCode, Body: truncate(string(respBody), maxErrorBody)}
+	}
+	var resps []Response
+	if trimmed := bytes.TrimSpace(respBody); len(trimmed) > 0 && trimmed[0] == '{' {
+		// a single response, to a single call or an error failing the whole batch.
+		var resp Response
+		if err := json.Unmarshal(respBody, &resp); err != nil {
+			return fmt.Errorf("%w: %w", ErrInvalidResponse, err)
+		}
+		if resp.Error != nil && (len(reqs) > 1 || resp.Id == nil || *resp.Id != reqs[0].Id) {
+			return resp.Error
+		}
+		resps = []Response{resp}
+	} else if err := json.Unmarshal(respBody, &resps); err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidResponse, err)
+	}
+	for _, resp := range resps {
+		if resp.Id == nil {
+			continue
+		}
+		elem, ok := index[*resp.Id]
+		if !ok {
+			continue
+		}
+		delete(index, *resp.Id)
+		elem.Err = resp.decode(elem.Result)
+	}
+	return nil
+}
+
+// maxErrorBody is the length of the response body kept in an HTTPError.
+const maxErrorBody = 256
+
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return s[:n] + "..."
+}
+
+// decode validates the response and decodes its result into result.
+func (r Response) decode(result interface{}) error {
+	switch {
+	case r.Jsonrpc != Version:
+		return fmt.Errorf("%w: version %q", ErrInvalidResponse, r.Jsonrpc)
+	case r.Error != nil:
+		return r.Error
+	case len(r.Result) == 0:
+		return fmt.Errorf("%w: no result nor error", ErrInvalidResponse)
+	case string(r.Result) == "null":
+		return ErrNullResult
+	case result == nil:
+		return nil
+	}
+	if err := json.Unmarshal(r.Result, result); err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidResponse, err)
+	}
+	return nil
+}
+
+// batchError is the error of a call failed with its whole batch.
+type batchError struct {
+	err error
+}
+
+func (e *batchError) Error() string { return e.err.Error() }
+
+func (e *batchError) Unwrap() error { return e.err }
@@ -0,0 +1,221 @@
+package rpc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// newTestServer answers eth_echo with its first param and fails any other method, replying to
+// batches in reverse order. It counts the POSTs it receives.
+func newTestServer(t *testing.T, posts *atomic.Int32) *httptest.Server {
+	t.Helper()
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		posts.Add(1)
+		var raw json.RawMessage
+		if err := json.NewDecoder(r.Body).Decode(&raw); err != nil {
+			t.Errorf("decoding request: %v", err)
+			return
+		}
+		answer := func(req Request) map[string]interface{} {
+			resp := map[string]interface{}{"jsonrpc": Version, "id": req.Id}
+			if req.Method != "eth_echo" {
+				resp["error"] = map[string]interface{}{"code": -32601, "message": "method not found"}
+			} else {
+				resp["result"] = req.Params[0]
+			}
+			return resp
+		}
+		if raw[0] == '{' {
+			var req Request
+			json.Unmarshal(raw, &req)
+			json.NewEncoder(w).Encode(answer(req))
+			return
+		}
+		var reqs []Request
+		json.Unmarshal(raw, &reqs)
+		var resps []map[string]interface{}
+		for i := len(reqs) - 1; i >= 0; i-- {
+			resps = append(resps, answer(reqs[i]))
+		}
+		json.NewEncoder(w).Encode(resps)
+	}))
+}
+
+func TestClient_BatchCall(t *testing.T) {
+	tests := []struct {
+		name      string
+		batchSize int
+		calls     int // plus a failing call
+		wantPosts int32
+	}{
+		{name: "Test single batch", batchSize: 10, calls: 5, wantPosts: 1},
+		{name: "Test split batches", batchSize: 2, calls: 5, wantPosts: 3},
+		{name: "Test no batching", batchSize: 1, calls: 3, wantPosts: 4},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var posts atomic.Int32
+			srv := newTestServer(t, &posts)
+			defer srv.Close()
+			c := NewClient(srv.Client(), srv.URL, WithBatchSize(tt.batchSize))
+			results := make([]string, tt.calls)
+			elems := make([]BatchElem, tt.calls+1)
+			for i := 0; i < tt.calls; i++ {
+				elems[i] = BatchElem{Method: "eth_echo", Params: []interface{}{string(rune('a' + i))}, Result: &results[i]}
+			}
+			elems[tt.calls] = BatchElem{Method: "eth_unknown", Params: []interface{}{}}
+			if err := c.BatchCall(context.Background(), elems); err != nil {
+				t.Fatalf("Client.BatchCall() error = %v", err)
+			}
+			for i := 0; i < tt.calls; i++ {
+				if elems[i].Err != nil || results[i] != string(rune('a'+i)) {
+					t.Errorf("Client.BatchCall() elem %d = %q, %v, want %q", i, results[i], elems[i].Err, string(rune('a'+i)))
+				}
+			}
+			var rpcErr *RPCError
+			if !errors.As(elems[tt.calls].Err, &rpcErr) || rpcErr.Code != -32601 {
+				t.Errorf("Client.BatchCall() elem error = %v, want code -32601", elems[tt.calls].Err)
+			}
+			if got := posts.Load(); got != tt.wantPosts {
+				t.Errorf("Client.BatchCall() posts = %v, want %v", got, tt.wantPosts)
+			}
+		})
+	}
+}
+
+func TestClient_CallCoalesced(t *testing.T) {
+	var posts atomic.Int32
+	srv := newTestServer(t, &posts)
+	defer srv.Close()
+	c := NewClient(srv.Client(), srv.URL, WithBatchSize(4), WithBatchWait(time.Second))
+	var wg sync.WaitGroup
+	results := make([]string, 4)
+	errs := make([]error, 4)
+	for i := range results {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs[i] = c.Call(context.Background(), &results[i], "eth_echo", string(rune('a'+i)))
+		}()
+	}
+	wg.Wait()
+	// the batch is sent as soon as it is full, long before the wait.
+	if got := posts.Load(); got != 1 {
+		t.Errorf("Client.Call() posts = %v, want %v", got, 1)
+	}
+	if !slices.Equal(results, []string{"a", "b", "c", "d"}) {
+		t.Errorf("Client.Call() results = %v, errors = %v", results, errs)
+	}
+}
+
+func TestClient_CallErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		status  int
+		body    string // %d is replaced with the id of the request
+		delay   time.Duration
+		wantErr error
+	}{
+		{name: "Test result", status: http.StatusOK, body: `{"jsonrpc":"2.0","id":%d,"result":"0x1"}`},
+		{name: "Test http rate limit", status: http.StatusTooManyRequests, body: `too many requests`, wantErr: ErrRateLimited},
+		{name: "Test rpc rate limit", status: http.StatusOK, body: `{"jsonrpc":"2.0","id":null,"error":{"code":-32005,"message":"limit exceeded"}}`, wantErr: ErrRateLimited},
+		{name: "Test rpc timeout", status: http.StatusOK, body: `{"jsonrpc":"2.0","id":%d,"error":{"code":-32000,"message":"execution timeout"}}`, wantErr: ErrTimeout},
+		{name: "Test client timeout", status: http.StatusOK, body: `{"jsonrpc":"2.0","id":%d,"result":"0x1"}`, delay: 100 * time.Millisecond, wantErr: ErrTimeout},
+		{name: "Test null result", status: http.StatusOK, body: `{"jsonrpc":"2.0","id":%d,"result":null}`, wantErr: ErrNullResult},
+		{name: "Test missing result", status: http.StatusOK, body: `{"jsonrpc":"2.0","id":%d}`, wantErr: ErrInvalidResponse},
+		{name: "Test wrong version", status: http.StatusOK, body: `{"jsonrpc":"1.0","id":%d,"result":"0x1"}`, wantErr: ErrInvalidResponse},
+		{name: "Test wrong id", status: http.StatusOK, body: `{"jsonrpc":"2.0","id":1000,"result":"0x1"}`, wantErr: ErrMissingResponse},
+		{name: "Test not json", status: http.StatusOK, body: `<html>`, wantErr: ErrInvalidResponse},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				var req Request
+				json.NewDecoder(r.Body).Decode(&req)
+				time.Sleep(tt.delay)
+				w.WriteHeader(tt.status)
+				body := tt.body
+				if strings.Contains(body, "%d") {
+					body = fmt.Sprintf(body, req.Id)
+				}
+				w.Write([]byte(body))
+			}))
+			defer srv.Close()
+			client := srv.Client()
+			client.Timeout = 50 * time.Millisecond
+			c := NewClient(client, srv.URL)
+			var result string
+			err := c.Call(context.Background(), &result, "eth_blockNumber")
+			if !errors.Is(err, tt.wantErr) || (tt.wantErr == nil && result != "0x1") {
+				t.Errorf("Client.Call() = %q, %v, wantErr %v", result, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestClient_CallTimeouts(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req Request
+		json.NewDecoder(r.Body).Decode(&req)
+		select {
+		case <-time.After(50 * time.Millisecond):
+		case <-r.Context().Done():
+			return
+		}
+		fmt.Fprintf(w, `{"jsonrpc":"2.0","id":%d,"result":"0x1"}`, req.Id)
+	}))
+	defer srv.Close()
+	c := NewClient(srv.Client(), srv.URL, WithTimeouts(10*time.Millisecond, map[string]time.Duration{"debug_traceBlockByNumber": time.Second}))
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+	tests := []struct {
+		name    string
+		ctx     context.Context
+		method  string
+		wantErr error
+	}{
+		{name: "Test default timeout", ctx: context.Background(), method: "eth_blockNumber", wantErr: ErrTimeout},
+		{name: "Test method timeout", ctx: context.Background(), method: "debug_traceBlockByNumber"},
+		{name: "Test cancelled", ctx: cancelled, method: "debug_traceBlockByNumber", wantErr: context.Canceled},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var result string
+			if err := c.Call(tt.ctx, &result, tt.method); !errors.Is(err, tt.wantErr) {
+				t.Errorf("Client.Call() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestClient_CallFallbacks(t *testing.T) {
+	var downPosts, upPosts atomic.Int32
+	down := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		downPosts.Add(1)
+		http.Error(w, "unavailable", http.StatusServiceUnavailable)
+	}))
+	defer down.Close()
+	up := newTestServer(t, &upPosts)
+	defer up.Close()
+	c := NewClient(http.DefaultClient, down.URL, WithFallbacks(up.URL))
+	for i := 0; i < 2; i++ {
+		var result string
+		if err := c.Call(context.Background(), &result, "eth_echo", "a"); err != nil || result != "a" {
+			t.Fatalf("Client.Call() = %q, %v, want %q", result, err, "a")
+		}
+	}
+	// the failed endpoint is only tried once, the next calls stick to the fallback.
+	if downPosts.Load() != 1 || upPosts.Load() != 2 {
+		t.Errorf("Client.Call() posts = %v down, %v up, want 1 and 2", downPosts.Load(), upPosts.Load())
+	}
+}
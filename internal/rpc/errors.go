@@ -0,0 +1,101 @@
+package rpc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+)
+
+var (
+	ErrMissingResponse = errors.New("no response for the request in the batch")
+	// ErrInvalidResponse is a response that is not a valid JSON-RPC 2.0 response.
+	ErrInvalidResponse = errors.New("invalid JSON-RPC response")
+	// ErrNullResult is a successful response with a null result, e.g. a block not yet known to the node.
+	ErrNullResult = errors.New("null JSON-RPC result")
+	// ErrRateLimited is a request rejected by the rate limit of the node.
+	ErrRateLimited = errors.New("rate limited by the node")
+	// ErrTimeout is a request that timed out, in the client or in the node.
+	ErrTimeout = errors.New("request timed out")
+)
+
+// JSON-RPC 2.0 error codes of requests the node will never accept, retrying them is pointless.
+const (
+	CodeParseError     = -32700
+	CodeInvalidRequest = -32600
+	CodeMethodNotFound = -32601
+	CodeInvalidParams  = -32602
+	// CodeLimitExceeded is returned by several providers when a request exceeds their rate limit.
+	CodeLimitExceeded = -32005
+)
+
+// RPCError is the error object of a JSON-RPC response.
+type RPCError struct {
+	Code    int             `json:"code"`
+	Message string          `json:"message"`
+	Data    json.RawMessage `json:"data,omitempty"`
+}
+
+func (e *RPCError) Error() string {
+	if len(e.Data) > 0 {
+		return fmt.Sprintf("rpc error %d: %s: %s", e.Code, e.Message, e.Data)
+	}
+	return fmt.Sprintf("rpc error %d: %s", e.Code, e.Message)
+}
+
+// Is matches the rate limit and timeout errors reported by the node, which providers signal with
+// various codes and messages.
+func (e *RPCError) Is(target error) bool {
+	msg := strings.ToLower(e.Message)
+	switch target {
+	case ErrRateLimited:
+		return e.Code == CodeLimitExceeded || e.Code == http.StatusTooManyRequests ||
+			strings.Contains(msg, "rate limit") || strings.Contains(msg, "too many requests")
+	case ErrTimeout:
+		return strings.Contains(msg, "timeout") || strings.Contains(msg, "timed out")
+	}
+	return false
+}
+
+// Permanent reports whether the node rejected the request itself, so that it fails the same
+// way however many times it is sent.
+func (e *RPCError) Permanent() bool {
+	switch e.Code {
+	case CodeParseError, CodeInvalidRequest, CodeMethodNotFound, CodeInvalidParams:
+		return true
+	}
+	return false
+}
+
+// HTTPError is a response with a non 2xx HTTP status.
+type HTTPError struct {
+	StatusCode int
+	Body       string
+}
+
+func (e *HTTPError) Error() string {
+	return fmt.Sprintf("http status %d: %s", e.StatusCode, e.Body)
+}
+
+// Is matches ErrRateLimited to a 429 status and ErrTimeout to the gateway timeout ones.
+func (e *HTTPError) Is(target error) bool {
+	switch target {
+	case ErrRateLimited:
+		return e.StatusCode == http.StatusTooManyRequests
+	case ErrTimeout:
+		return e.StatusCode == http.StatusRequestTimeout || e.StatusCode == http.StatusGatewayTimeout
+	}
+	return false
+}
+
+// transportError wraps the timeouts of the HTTP client with ErrTimeout.
+func transportError(err error) error {
+	var netErr net.Error
+	if errors.Is(err, context.DeadlineExceeded) || (errors.As(err, &netErr) && netErr.Timeout()) {
+		return fmt.Errorf("%w: %w", ErrTimeout, err)
+	}
+	return err
+}
@@ -0,0 +1,81 @@
+package rpc
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/coder/websocket"
+)
+
+// MaxMessageSize caps the size of a received WebSocket message, newHeads notifications are a few
+// KB but full pending transactions can be much larger.
+const MaxMessageSize = 16 << 20
+
+// notification is an eth_subscription message pushed by the node.
+type notification struct {
+	Method string `json:"method"`
+	Params struct {
+		Subscription string          `json:"subscription"`
+		Result       json.RawMessage `json:"result"`
+	} `json:"params"`
+}
+
+// Subscribe opens a WebSocket connection to url and subscribes with eth_subscribe, e.g. to
+// "newHeads". notify is called with the result of every notification of the subscription until
+// the connection fails or ctx is done, the error is then returned. It never returns nil.
+func Subscribe(ctx context.Context, url string, notify func(json.RawMessage), params ...interface{}) error {
+	conn, _, err := websocket.Dial(ctx, url, nil)
+	if err != nil {
+		return err
+	}
+	defer conn.CloseNow()
+	conn.SetReadLimit(MaxMessageSize)
+
+	req, err := json.Marshal(Request{Jsonrpc: Version, Method: "eth_subscribe", Params: params, Id: 1})
+	if err != nil {
+		return err
+	}
+	if err := conn.Write(ctx, websocket.MessageText, req); err != nil {
+		return wrapCtx(ctx, err)
+	}
+	var subscription string
+	for subscription == "" {
+		_, msg, err := conn.Read(ctx)
+		if err != nil {
+			return wrapCtx(ctx, err)
+		}
+		var resp Response
+		if err := json.Unmarshal(msg, &resp); err != nil {
+			return fmt.Errorf("%w: %w", ErrInvalidResponse, err)
+		}
+		if resp.Id == nil || *resp.Id != 1 {
+			continue
+		}
+		if err := resp.decode(&subscription); err != nil {
+			return err
+		}
+	}
+	for {
+		_, msg, err := conn.Read(ctx)
+		if err != nil {
+			return wrapCtx(ctx, err)
+		}
+		var n notification
+		if err := json.Unmarshal(msg, &n); err != nil {
+			return fmt.Errorf("%w: %w", ErrInvalidResponse, err)
+		}
+		if n.Method == "eth_subscription" && n.Params.Subscription == subscription {
+			notify(n.Params.Result)
+		}
+	}
+}
+
+// wrapCtx returns the context error when the connection failed because ctx is done, the reads and
+// writes closing the connection once it is.
+func wrapCtx(ctx context.Context, err error) error {
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	return err
+}
@@ -0,0 +1,62 @@
+package rpc
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/coder/websocket"
+)
+
+func TestSubscribe(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := websocket.Accept(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close(websocket.StatusNormalClosure, "")
+		_, msg, err := conn.Read(r.Context())
+		if err != nil {
+			return
+		}
+		var req Request
+		json.Unmarshal(msg, &req)
+		if req.Method != "eth_subscribe" || req.Params[0] != "newHeads" {
+			t.Errorf("Subscribe() request = %+v, want eth_subscribe newHeads", req)
+		}
+		fmt.Fprintf(wsWriter{r.Context(), conn}, `{"jsonrpc":"2.0","id":%d,"result":"0xabc"}`, req.Id)
+		for _, sub := range []string{"0xabc", "0xother", "0xabc"} {
+			fmt.Fprintf(wsWriter{r.Context(), conn}, `{"jsonrpc":"2.0","method":"eth_subscription","params":{"subscription":%q,"result":{"number":"0x1"}}}`, sub)
+		}
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	var got []string
+	err := Subscribe(ctx, "ws"+strings.TrimPrefix(srv.URL, "http"), func(result json.RawMessage) {
+		got = append(got, string(result))
+	}, "newHeads")
+	if status := websocket.CloseStatus(err); status != websocket.StatusNormalClosure {
+		t.Errorf("Subscribe() error = %v, want %v", err, websocket.StatusNormalClosure)
+	}
+	if want := []string{`{"number":"0x1"}`, `{"number":"0x1"}`}; !slices.Equal(got, want) {
+		t.Errorf("Subscribe() notifications = %v, want %v", got, want)
+	}
+}
+
+// wsWriter writes each Write as a WebSocket message.
+type wsWriter struct {
+	ctx  context.Context
+	conn *websocket.Conn
+}
+
+func (w wsWriter) Write(p []byte) (int, error) {
+	return len(p), w.conn.Write(w.ctx, websocket.MessageText, p)
+}
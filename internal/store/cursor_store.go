@@ -0,0 +1,37 @@
+package store
+
+import "sync"
+
+// CursorStore persists the last block whose data has been fully committed to the stores.
+type CursorStore interface {
+	// GetCursor returns the last committed block, 0 if none
+	GetCursor() (int64, error)
+	// SetCursor sets the last committed block
+	SetCursor(block int64) error
+}
+
+// MemCursorStore is an in-memory implementation of CursorStore
+type MemCursorStore struct {
+	cursor int64
+	mx     sync.Mutex
+}
+
+// NewMemCursorStore creates a new MemCursorStore
+func NewMemCursorStore() *MemCursorStore {
+	return &MemCursorStore{}
+}
+
+// GetCursor returns the last committed block
+func (mcs *MemCursorStore) GetCursor() (int64, error) {
+	mcs.mx.Lock()
+	defer mcs.mx.Unlock()
+	return mcs.cursor, nil
+}
+
+// SetCursor sets the last committed block
+func (mcs *MemCursorStore) SetCursor(block int64) error {
+	mcs.mx.Lock()
+	defer mcs.mx.Unlock()
+	mcs.cursor = block
+	return nil
+}
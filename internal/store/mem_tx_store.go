@@ -8,6 +8,8 @@ import (
 type MemTxStore[T any] struct {
 	// Transactions is a map of address to transactions
 	Transactions map[string][]T
+	// keys are the keys of the Keyed transactions of each address
+	keys map[string]map[string]bool
 	// Mutex for synchronizing access to Transactions
 	mx sync.Mutex
 }
@@ -16,13 +18,24 @@ type MemTxStore[T any] struct {
 func NewMemTxStore[T any]() *MemTxStore[T] {
 	return &MemTxStore[T]{
 		Transactions: make(map[string][]T),
+		keys:         make(map[string]map[string]bool),
 	}
 }
 
-// AddTransaction adds a transaction to the store
+// AddTransaction adds a transaction to the store, a Keyed transaction already stored for the
+// address being ignored
 func (mts *MemTxStore[T]) AddTransaction(address string, tx T) error {
 	mts.mx.Lock()
 	defer mts.mx.Unlock()
+	if k, ok := any(tx).(Keyed); ok {
+		if mts.keys[address] == nil {
+			mts.keys[address] = make(map[string]bool)
+		}
+		if mts.keys[address][k.Key()] {
+			return nil
+		}
+		mts.keys[address][k.Key()] = true
+	}
 	if _, ok := mts.Transactions[address]; !ok {
 		mts.Transactions[address] = []T{tx}
 	} else {
@@ -42,3 +55,23 @@ func (mts *MemTxStore[T]) GetTransactions(address string) ([]T, error) {
 	}
 	return nil, ErrNoTransactions
 }
+
+// ForEachTransaction calls fn with the transactions of an address stored when it is called. The
+// lock is only held to read each transaction, so that a slow fn does not block the writers.
+func (mts *MemTxStore[T]) ForEachTransaction(address string, fn func(tx T) error) error {
+	mts.mx.Lock()
+	n := len(mts.Transactions[address])
+	mts.mx.Unlock()
+	if n == 0 {
+		return ErrNoTransactions
+	}
+	for i := 0; i < n; i++ {
+		mts.mx.Lock()
+		tx := mts.Transactions[address][i]
+		mts.mx.Unlock()
+		if err := fn(tx); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -1,6 +1,8 @@
 package store
 
 import (
+	"errors"
+	"fmt"
 	"testing"
 )
 
@@ -66,3 +68,49 @@ func TestMemTxStore_AddGetTransactions(t *testing.T) {
 		})
 	}
 }
+
+func TestMemTxStore_ForEachTransaction(t *testing.T) {
+	mts := NewMemTxStore[Transaction]()
+	for _, hash := range []string{"0x1", "0x2", "0x3"} {
+		mts.AddTransaction("0x123", Transaction{Hash: hash})
+	}
+	var got []string
+	err := mts.ForEachTransaction("0x123", func(tx Transaction) error {
+		got = append(got, tx.Hash)
+		// the transactions added meanwhile are not iterated.
+		mts.AddTransaction("0x123", Transaction{Hash: "0x4"})
+		return nil
+	})
+	if err != nil || fmt.Sprint(got) != "[0x1 0x2 0x3]" {
+		t.Errorf("MemTxStore.ForEachTransaction() = %v, %v, want [0x1 0x2 0x3]", got, err)
+	}
+	stop := errors.New("stop")
+	if err := mts.ForEachTransaction("0x123", func(Transaction) error { return stop }); !errors.Is(err, stop) {
+		t.Errorf("MemTxStore.ForEachTransaction() error = %v, want %v", err, stop)
+	}
+	if err := mts.ForEachTransaction("0x124", func(Transaction) error { return nil }); !errors.Is(err, ErrNoTransactions) {
+		t.Errorf("MemTxStore.ForEachTransaction() error = %v, want %v", err, ErrNoTransactions)
+	}
+}
+
+// keyedTransaction is a Transaction keyed by its hash.
+type keyedTransaction Transaction
+
+func (tx keyedTransaction) Key() string { return tx.Hash }
+
+func TestMemTxStore_AddKeyedTransaction(t *testing.T) {
+	s := NewMemTxStore[keyedTransaction]()
+	for _, tx := range []keyedTransaction{{Hash: "0x1"}, {Hash: "0x2"}, {Hash: "0x1"}} {
+		if err := s.AddTransaction("0x123", tx); err != nil {
+			t.Fatalf("MemTxStore.AddTransaction() error = %v", err)
+		}
+	}
+	// the key is per address.
+	s.AddTransaction("0x456", keyedTransaction{Hash: "0x1"})
+	if got, _ := s.GetTransactions("0x123"); len(got) != 2 {
+		t.Errorf("MemTxStore.GetTransactions() = %v, want 2 transactions", got)
+	}
+	if got, _ := s.GetTransactions("0x456"); len(got) != 1 {
+		t.Errorf("MemTxStore.GetTransactions() = %v, want 1 transaction", got)
+	}
+}
@@ -0,0 +1,35 @@
+package store
+
+// NamespacedTxStore prefixes the addresses of an underlying TxStore with a namespace, so that
+// several chains can share the same store without mixing their transactions.
+type NamespacedTxStore[T any] struct {
+	store     TxStore[T]
+	namespace string
+}
+
+// NewNamespacedTxStore creates a NamespacedTxStore storing into store under namespace.
+func NewNamespacedTxStore[T any](store TxStore[T], namespace string) *NamespacedTxStore[T] {
+	return &NamespacedTxStore[T]{
+		store:     store,
+		namespace: namespace,
+	}
+}
+
+// AddTransaction adds a transaction to the store under the namespace
+func (nts *NamespacedTxStore[T]) AddTransaction(address string, tx T) error {
+	return nts.store.AddTransaction(nts.key(address), tx)
+}
+
+// GetTransactions returns a list of transactions for an address of the namespace
+func (nts *NamespacedTxStore[T]) GetTransactions(address string) ([]T, error) {
+	return nts.store.GetTransactions(nts.key(address))
+}
+
+// ForEachTransaction calls fn with the transactions of an address of the namespace
+func (nts *NamespacedTxStore[T]) ForEachTransaction(address string, fn func(tx T) error) error {
+	return nts.store.ForEachTransaction(nts.key(address), fn)
+}
+
+func (nts *NamespacedTxStore[T]) key(address string) string {
+	return nts.namespace + ":" + address
+}
@@ -0,0 +1,22 @@
+package store
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestNamespacedTxStore_AddGetTransactions(t *testing.T) {
+	shared := NewMemTxStore[Transaction]()
+	mainnet := NewNamespacedTxStore[Transaction](shared, "1")
+	polygon := NewNamespacedTxStore[Transaction](shared, "137")
+	if err := mainnet.AddTransaction("0x123", Transaction{Hash: "0x1"}); err != nil {
+		t.Fatalf("NamespacedTxStore.AddTransaction() error = %v", err)
+	}
+	txs, err := mainnet.GetTransactions("0x123")
+	if err != nil || len(txs) != 1 || txs[0].Hash != "0x1" {
+		t.Errorf("NamespacedTxStore.GetTransactions() = %v, %v, want 0x1", txs, err)
+	}
+	if _, err := polygon.GetTransactions("0x123"); !errors.Is(err, ErrNoTransactions) {
+		t.Errorf("NamespacedTxStore.GetTransactions() error = %v, want %v", err, ErrNoTransactions)
+	}
+}
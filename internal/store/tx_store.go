@@ -8,6 +8,16 @@ type TxStore[T any] interface {
 	AddTransaction(address string, tx T) error
 	// GetTransactions returns a list of transactions for an address
 	GetTransactions(address string) ([]T, error)
+	// ForEachTransaction calls fn with the transactions of an address in the order they were
+	// added, without copying them all, until fn returns an error
+	ForEachTransaction(address string, fn func(tx T) error) error
+}
+
+// Keyed is implemented by the transactions with a key identifying them. A store adds a keyed
+// transaction at most once per address, so that writing a block again, e.g. when its commit is
+// retried, does not duplicate the transactions already written.
+type Keyed interface {
+	Key() string
 }
 
 var (
@@ -0,0 +1,90 @@
+// Package address validates and normalizes Ethereum addresses, with the mixed case checksum of
+// EIP-55.
+package address
+
+import (
+	"encoding/hex"
+	"errors"
+	"strings"
+
+	"github.com/pmes126/tx-parser-service/internal/keccak"
+)
+
+// Length is the length of a 0x prefixed address.
+const Length = 42
+
+var (
+	ErrInvalidFormat   = errors.New("address is not 0x followed by 40 hex digits")
+	ErrInvalidChecksum = errors.New("invalid EIP-55 address checksum")
+	// ErrMissingChecksum is a lower or upper case address rejected in strict mode.
+	ErrMissingChecksum = errors.New("address without EIP-55 checksum")
+)
+
+// Validate checks that address is a 0x prefixed 20 bytes hex address and, when it is mixed case,
+// that its case matches its EIP-55 checksum. All lower and all upper case addresses carry no
+// checksum and are accepted.
+func Validate(address string) error {
+	if !isHex(address) {
+		return ErrInvalidFormat
+	}
+	digits := address[2:]
+	if digits == strings.ToLower(digits) || digits == strings.ToUpper(digits) {
+		return nil
+	}
+	if address != Checksum(address) {
+		return ErrInvalidChecksum
+	}
+	return nil
+}
+
+// ValidateStrict is Validate rejecting the addresses without a checksum, except the ones without
+// any letter, whose checksum is themselves.
+func ValidateStrict(address string) error {
+	if err := Validate(address); err != nil {
+		return err
+	}
+	if address != Checksum(address) {
+		return ErrMissingChecksum
+	}
+	return nil
+}
+
+// Normalize validates an address and returns its lower case form, the form addresses are stored
+// and compared in.
+func Normalize(address string) (string, error) {
+	if err := Validate(address); err != nil {
+		return "", err
+	}
+	return strings.ToLower(address), nil
+}
+
+// Checksum returns the EIP-55 form of an address: the hex letters are upper case where the
+// matching nibble of the Keccak-256 hash of the lower case address is 8 or more. Invalid addresses
+// are returned as is.
+func Checksum(address string) string {
+	if !isHex(address) {
+		return address
+	}
+	lower := strings.ToLower(address[2:])
+	hash := keccak.Sum256([]byte(lower))
+	res := []byte("0x" + lower)
+	for i := 0; i < len(lower); i++ {
+		nibble := hash[i/2] >> 4
+		if i%2 == 1 {
+			nibble = hash[i/2] & 0x0f
+		}
+		if c := res[i+2]; c >= 'a' && c <= 'f' && nibble >= 8 {
+			res[i+2] = c - 'a' + 'A'
+		}
+	}
+	return string(res)
+}
+
+// isHex reports whether address is 0x followed by 40 hex digits.
+func isHex(address string) bool {
+	if len(address) != Length || address[:2] != "0x" {
+		return false
+	}
+	_, err := hex.DecodeString(address[2:])
+	return err == nil
+}
@@ -0,0 +1,53 @@
+package address
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestChecksum(t *testing.T) {
+	// test vectors of EIP-55.
+	tests := []string{
+		"0x5aAeb6053F3E94C9b9A09f33669435E7Ef1BeAed",
+		"0xfB6916095ca1df60bB79Ce92cE3Ea74c37c5d359",
+		"0xdbF03B407c01E7cD3CBea99509d93f8DDDC8C6FB",
+		"0xD1220A0cf47c7B9Be7A2E6BA89F429762e7b9aDb",
+	}
+	for _, want := range tests {
+		if got := Checksum(strings.ToLower(want)); got != want {
+			t.Errorf("Checksum() = %v, want %v", got, want)
+		}
+	}
+	if got := Checksum("0x456"); got != "0x456" {
+		t.Errorf("Checksum() = %v, want %v", got, "0x456")
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name       string
+		address    string
+		want       error
+		wantStrict error
+	}{
+		{name: "Test checksummed", address: "0x5aAeb6053F3E94C9b9A09f33669435E7Ef1BeAed"},
+		{name: "Test lower case", address: "0x5aaeb6053f3e94c9b9a09f33669435e7ef1beaed", wantStrict: ErrMissingChecksum},
+		{name: "Test upper case", address: "0x5AAEB6053F3E94C9B9A09F33669435E7EF1BEAED", wantStrict: ErrMissingChecksum},
+		{name: "Test digits only", address: "0x1111111111111111111111111111111111111111"},
+		{name: "Test wrong checksum", address: "0x5aAeb6053F3E94C9b9A09f33669435E7Ef1BeAeD", want: ErrInvalidChecksum, wantStrict: ErrInvalidChecksum},
+		{name: "Test short", address: "0x5aAeb6053F3E94C9b9A09f33669435E7Ef1BeA", want: ErrInvalidFormat, wantStrict: ErrInvalidFormat},
+		{name: "Test missing prefix", address: "005aAeb6053F3E94C9b9A09f33669435E7Ef1BeAed", want: ErrInvalidFormat, wantStrict: ErrInvalidFormat},
+		{name: "Test not hex", address: "0x5aAeb6053F3E94C9b9A09f33669435E7Ef1BeAeg", want: ErrInvalidFormat, wantStrict: ErrInvalidFormat},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := Validate(tt.address); !errors.Is(err, tt.want) {
+				t.Errorf("Validate() error = %v, want %v", err, tt.want)
+			}
+			if err := ValidateStrict(tt.address); !errors.Is(err, tt.wantStrict) {
+				t.Errorf("ValidateStrict() error = %v, want %v", err, tt.wantStrict)
+			}
+		})
+	}
+}
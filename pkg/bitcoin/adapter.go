@@ -0,0 +1,259 @@
+// Package bitcoin is the chain adapter of Bitcoin and of the chains with a bitcoind compatible
+// JSON-RPC interface.
+package bitcoin
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/pmes126/tx-parser-service/internal/rpc"
+	"github.com/pmes126/tx-parser-service/pkg/parser"
+)
+
+const (
+	GetBlockCount     = "getblockcount"
+	GetBlockHash      = "getblockhash"
+	GetBlock          = "getblock"
+	GetRawTransaction = "getrawtransaction"
+
+	// BlockVerbosity is the getblock verbosity returning the decoded transactions of the block.
+	BlockVerbosity = 2
+	// CodeOutOfRange is the error of getblockhash for a height above the tip of the node.
+	CodeOutOfRange = -8
+	// satoshiDecimals is the number of decimals of the BTC amounts of the node.
+	satoshiDecimals = 8
+)
+
+// Block is a block returned by getblock with verbosity 2.
+type Block struct {
+	Hash   string `json:"hash"`
+	Height int64  `json:"height"`
+	Time   int64  `json:"time"`
+	Tx     []Tx   `json:"tx"`
+}
+
+// Tx is a decoded transaction.
+type Tx struct {
+	Txid string `json:"txid"`
+	Vin  []Vin  `json:"vin"`
+	Vout []Vout `json:"vout"`
+}
+
+// Vin is a transaction input, spending the output Vout of the transaction Txid or minting the
+// coinbase.
+type Vin struct {
+	Coinbase string `json:"coinbase,omitempty"`
+	Txid     string `json:"txid"`
+	Vout     int    `json:"vout"`
+	// Prevout is the spent output, returned by getblock with verbosity 3 or resolved by the adapter.
+	Prevout *Vout `json:"prevout,omitempty"`
+}
+
+// Vout is a transaction output.
+type Vout struct {
+	Value        json.Number  `json:"value"`
+	N            int          `json:"n"`
+	ScriptPubKey ScriptPubKey `json:"scriptPubKey"`
+}
+
+// ScriptPubKey is the locking script of an output.
+type ScriptPubKey struct {
+	Type    string `json:"type"`
+	Address string `json:"address,omitempty"`
+	// Addresses is returned instead of Address by the nodes older than Bitcoin Core 22.
+	Addresses []string `json:"addresses,omitempty"`
+}
+
+// address returns the address an output pays to, empty for the outputs without one, e.g. OP_RETURN.
+func (s ScriptPubKey) address() string {
+	if s.Address != "" {
+		return s.Address
+	}
+	if len(s.Addresses) == 1 {
+		return s.Addresses[0]
+	}
+	return ""
+}
+
+// Adapter is the parser.ChainAdapter of a bitcoind compatible node speaking JSON-RPC 2.0, e.g.
+// Bitcoin Core 28 or later. The inputs are attributed to the addresses of the outputs they spend,
+// which are looked up with getrawtransaction and thus need the transaction index of the node
+// (-txindex) unless it returns them with the block.
+type Adapter struct {
+	rpc     *rpc.Client
+	network Network
+}
+
+// NewAdapter creates a new Adapter of the node behind client, on the given network.
+func NewAdapter(client *rpc.Client, network Network) *Adapter {
+	return &Adapter{
+		rpc:     client,
+		network: network,
+	}
+}
+
+// FetchHead returns the height of the latest block.
+func (a *Adapter) FetchHead(ctx context.Context) (int64, error) {
+	var height int64
+	if err := a.rpc.Call(ctx, &height, GetBlockCount); err != nil {
+		return 0, err
+	}
+	return height, nil
+}
+
+// FetchBlock returns the block at the given height with the prevouts of its inputs.
+func (a *Adapter) FetchBlock(ctx context.Context, number int64) (*Block, error) {
+	var hash string
+	if err := a.rpc.Call(ctx, &hash, GetBlockHash, number); err != nil {
+		var rpcErr *rpc.RPCError
+		if errors.As(err, &rpcErr) && rpcErr.Code == CodeOutOfRange {
+			return nil, fmt.Errorf("%w: %d", parser.ErrBlockNotFound, number)
+		}
+		return nil, err
+	}
+	var block Block
+	if err := a.rpc.Call(ctx, &block, GetBlock, hash, BlockVerbosity); err != nil {
+		if errors.Is(err, rpc.ErrNullResult) {
+			return nil, fmt.Errorf("%w: %d", parser.ErrBlockNotFound, number)
+		}
+		return nil, err
+	}
+	if err := a.resolvePrevouts(ctx, &block); err != nil {
+		return nil, err
+	}
+	return &block, nil
+}
+
+// resolvePrevouts sets the spent output of the inputs, from the block itself for the outputs
+// created in it and from getrawtransaction, in a batch, for the others.
+func (a *Adapter) resolvePrevouts(ctx context.Context, block *Block) error {
+	txs := make(map[string]*Tx, len(block.Tx))
+	for i := range block.Tx {
+		txs[block.Tx[i].Txid] = &block.Tx[i]
+	}
+	var calls []rpc.BatchElem
+	fetched := make(map[string]*Tx)
+	for _, tx := range block.Tx {
+		for _, vin := range tx.Vin {
+			if vin.Coinbase != "" || vin.Prevout != nil || txs[vin.Txid] != nil || fetched[vin.Txid] != nil {
+				continue
+			}
+			prev := &Tx{}
+			fetched[vin.Txid] = prev
+			calls = append(calls, rpc.BatchElem{Method: GetRawTransaction, Params: []interface{}{vin.Txid, true}, Result: prev})
+		}
+	}
+	if len(calls) > 0 {
+		if err := a.rpc.BatchCall(ctx, calls); err != nil {
+			return err
+		}
+		for _, call := range calls {
+			if call.Err != nil {
+				return fmt.Errorf("resolving prevout %v: %w", call.Params[0], call.Err)
+			}
+		}
+	}
+	for k := range fetched {
+		txs[k] = fetched[k]
+	}
+	for i := range block.Tx {
+		for j := range block.Tx[i].Vin {
+			vin := &block.Tx[i].Vin[j]
+			if vin.Coinbase != "" || vin.Prevout != nil {
+				continue
+			}
+			prev := txs[vin.Txid]
+			if vin.Vout < 0 || vin.Vout >= len(prev.Vout) {
+				return fmt.Errorf("prevout %s:%d not found", vin.Txid, vin.Vout)
+			}
+			vin.Prevout = &prev.Vout[vin.Vout]
+		}
+	}
+	return nil
+}
+
+// NormaliseTransactions returns the transactions of a block, spending their prevouts to their
+// outputs, with the values in satoshis.
+func (a *Adapter) NormaliseTransactions(block *Block) ([]parser.Transaction, error) {
+	res := make([]parser.Transaction, 0, len(block.Tx))
+	for _, tx := range block.Tx {
+		t := parser.Transaction{
+			Kind:        parser.TxKindExternal,
+			Hash:        tx.Txid,
+			BlockHash:   block.Hash,
+			BlockNumber: block.Height,
+			Timestamp:   block.Time,
+		}
+		var in, out int64
+		coinbase := false
+		for _, vin := range tx.Vin {
+			if vin.Coinbase != "" {
+				coinbase = true
+				continue
+			}
+			if vin.Prevout == nil {
+				return nil, fmt.Errorf("prevout %s:%d not resolved", vin.Txid, vin.Vout)
+			}
+			value, err := toSatoshis(vin.Prevout.Value)
+			if err != nil {
+				return nil, err
+			}
+			in += value
+			t.Inputs = append(t.Inputs, parser.Transfer{Address: a.canonical(vin.Prevout.ScriptPubKey.address()), Value: strconv.FormatInt(value, 10)})
+		}
+		for _, vout := range tx.Vout {
+			value, err := toSatoshis(vout.Value)
+			if err != nil {
+				return nil, err
+			}
+			out += value
+			t.Outputs = append(t.Outputs, parser.Transfer{Address: a.canonical(vout.ScriptPubKey.address()), Value: strconv.FormatInt(value, 10)})
+		}
+		if !coinbase {
+			t.Fee = strconv.FormatInt(in-out, 10)
+		}
+		res = append(res, t)
+	}
+	return res, nil
+}
+
+// canonical returns the canonical form of an address returned by the node, as is if it is not
+// valid on the network.
+func (a *Adapter) canonical(address string) string {
+	if address == "" {
+		return ""
+	}
+	if c, err := a.network.ValidateAddress(address); err == nil {
+		return c
+	}
+	return address
+}
+
+// ValidateAddress returns the canonical form of an address of the network.
+func (a *Adapter) ValidateAddress(address string) (string, error) {
+	c, err := a.network.ValidateAddress(address)
+	if err != nil {
+		return "", fmt.Errorf("%w: %w", parser.ErrInvalidAddress, err)
+	}
+	return c, nil
+}
+
+// toSatoshis converts an amount in BTC, with at most 8 decimals, to satoshis without going through
+// a float.
+func toSatoshis(amount json.Number) (int64, error) {
+	s := amount.String()
+	whole, frac, _ := strings.Cut(s, ".")
+	if len(frac) > satoshiDecimals {
+		return 0, fmt.Errorf("invalid amount %s", s)
+	}
+	frac += strings.Repeat("0", satoshiDecimals-len(frac))
+	sats, err := strconv.ParseInt(whole+frac, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid amount %s: %w", s, err)
+	}
+	return sats, nil
+}
@@ -0,0 +1,167 @@
+package bitcoin
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/pmes126/tx-parser-service/internal/rpc"
+	"github.com/pmes126/tx-parser-service/internal/store"
+	"github.com/pmes126/tx-parser-service/pkg/parser"
+)
+
+const (
+	alice = "bc1qw508d6qejxtdg4y5r3zarvary0c5xw7kv8f3t4"
+	bob   = "1BvBMSEYstWetqTFn5Au4m4GFg7xJaNVN2"
+	carol = "3J98t1WpEZ73CNmQviecrnyiWrnqRhWNLy"
+)
+
+// fakeNode is a bitcoind JSON-RPC server with a chain of 101 blocks, block 101 spending an output
+// of an earlier transaction and an output created in the block itself.
+func fakeNode(t *testing.T) *httptest.Server {
+	earlier := Tx{Txid: "earlier", Vout: []Vout{
+		{Value: "0.5", N: 0, ScriptPubKey: ScriptPubKey{Address: carol}},
+		{Value: "1.25000000", N: 1, ScriptPubKey: ScriptPubKey{Address: "BC1QW508D6QEJXTDG4Y5R3ZARVARY0C5XW7KV8F3T4"}},
+	}}
+	block := Block{Hash: "hash101", Height: 101, Tx: []Tx{
+		{Txid: "coinbase", Vin: []Vin{{Coinbase: "03"}}, Vout: []Vout{{Value: "3.125", N: 0, ScriptPubKey: ScriptPubKey{Addresses: []string{bob}}}}},
+		{Txid: "spend", Vin: []Vin{{Txid: "earlier", Vout: 1}}, Vout: []Vout{
+			{Value: "1", N: 0, ScriptPubKey: ScriptPubKey{Address: bob}},
+			{Value: "0.2499", N: 1, ScriptPubKey: ScriptPubKey{Address: alice}},
+			{Value: "0", N: 2, ScriptPubKey: ScriptPubKey{Type: "nulldata"}},
+		}},
+		{Txid: "chained", Vin: []Vin{{Txid: "spend", Vout: 0}}, Vout: []Vout{{Value: "0.9999", N: 0, ScriptPubKey: ScriptPubKey{Address: carol}}}},
+	}}
+	handle := func(req rpc.Request) map[string]interface{} {
+		resp := map[string]interface{}{"jsonrpc": rpc.Version, "id": req.Id}
+		switch req.Method {
+		case GetBlockCount:
+			resp["result"] = 101
+		case GetBlockHash:
+			if req.Params[0].(float64) != 101 {
+				resp["error"] = rpc.RPCError{Code: CodeOutOfRange, Message: "Block height out of range"}
+			} else {
+				resp["result"] = block.Hash
+			}
+		case GetBlock:
+			resp["result"] = block
+		case GetRawTransaction:
+			if req.Params[0] != earlier.Txid {
+				t.Errorf("getrawtransaction(%v), want only %v", req.Params[0], earlier.Txid)
+			}
+			resp["result"] = earlier
+		default:
+			resp["error"] = rpc.RPCError{Code: rpc.CodeMethodNotFound, Message: "Method not found"}
+		}
+		return resp
+	}
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if bytes.HasPrefix(body, []byte("[")) {
+			var reqs []rpc.Request
+			json.Unmarshal(body, &reqs)
+			var resps []map[string]interface{}
+			for _, req := range reqs {
+				resps = append(resps, handle(req))
+			}
+			json.NewEncoder(w).Encode(resps)
+			return
+		}
+		var req rpc.Request
+		json.Unmarshal(body, &req)
+		json.NewEncoder(w).Encode(handle(req))
+	}))
+}
+
+func TestAdapter_FetchBlock(t *testing.T) {
+	srv := fakeNode(t)
+	defer srv.Close()
+	a := NewAdapter(rpc.NewClient(&http.Client{}, srv.URL), MainNet)
+
+	head, err := a.FetchHead(context.Background())
+	if err != nil || head != 101 {
+		t.Fatalf("Adapter.FetchHead() = %v, %v, want %v", head, err, 101)
+	}
+	if _, err := a.FetchBlock(context.Background(), 102); !errors.Is(err, parser.ErrBlockNotFound) {
+		t.Errorf("Adapter.FetchBlock() error = %v, want %v", err, parser.ErrBlockNotFound)
+	}
+	block, err := a.FetchBlock(context.Background(), 101)
+	if err != nil {
+		t.Fatalf("Adapter.FetchBlock() error = %v", err)
+	}
+	txs, err := a.NormaliseTransactions(block)
+	if err != nil {
+		t.Fatalf("Adapter.NormaliseTransactions() error = %v", err)
+	}
+	want := []parser.Transaction{
+		{Hash: "coinbase", Outputs: []parser.Transfer{{Address: bob, Value: "312500000"}}},
+		{
+			Hash:    "spend",
+			Inputs:  []parser.Transfer{{Address: alice, Value: "125000000"}},
+			Outputs: []parser.Transfer{{Address: bob, Value: "100000000"}, {Address: alice, Value: "24990000"}, {Address: "", Value: "0"}},
+			Fee:     "10000",
+		},
+		{
+			Hash:    "chained",
+			Inputs:  []parser.Transfer{{Address: bob, Value: "100000000"}},
+			Outputs: []parser.Transfer{{Address: carol, Value: "99990000"}},
+			Fee:     "10000",
+		},
+	}
+	if len(txs) != len(want) {
+		t.Fatalf("Adapter.NormaliseTransactions() = %d transactions, want %d", len(txs), len(want))
+	}
+	for i, tx := range txs {
+		want[i].Kind, want[i].BlockHash, want[i].BlockNumber = parser.TxKindExternal, "hash101", 101
+		got, _ := json.Marshal(tx)
+		exp, _ := json.Marshal(want[i])
+		if !bytes.Equal(got, exp) {
+			t.Errorf("Adapter.NormaliseTransactions() = %s, want %s", got, exp)
+		}
+	}
+}
+
+func TestAdapter_ChainParser(t *testing.T) {
+	srv := fakeNode(t)
+	defer srv.Close()
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+	cp := parser.NewChainParser(NewAdapter(rpc.NewClient(&http.Client{}, srv.URL), MainNet), store.NewMemTxStore[parser.Transaction](), logger, 1)
+	for _, address := range []string{"BC1QW508D6QEJXTDG4Y5R3ZARVARY0C5XW7KV8F3T4", carol} {
+		if !cp.Subscribe(address) {
+			t.Fatalf("ChainParser.Subscribe(%q) = false, want true", address)
+		}
+	}
+	if cp.Subscribe("0xc0ffee254729296a45a3885639AC7E10F9d54979") {
+		t.Errorf("ChainParser.Subscribe() = true, want false for an Ethereum address")
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		cp.Start(ctx)
+	}()
+	deadline := time.Now().Add(5 * time.Second)
+	for cursor, _ := cp.Cursor(); cursor != 101; cursor, _ = cp.Cursor() {
+		if time.Now().After(deadline) {
+			t.Fatalf("ChainParser.Cursor() = %v, want %v", cursor, 101)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	cancel()
+	<-done
+	// alice receives the change of her own spend, carol an output of a chained transaction.
+	for address, want := range map[string]string{alice: "spend", carol: "chained"} {
+		txs, err := cp.ListTransactions(address)
+		if err != nil || len(txs) != 1 || txs[0].Hash != want {
+			t.Errorf("ChainParser.ListTransactions(%v) = %v, %v, want %v", address, txs, err, want)
+		}
+	}
+}
@@ -0,0 +1,215 @@
+package bitcoin
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+var (
+	ErrInvalidChecksum = errors.New("invalid address checksum")
+	ErrInvalidNetwork  = errors.New("address of another network")
+	ErrInvalidProgram  = errors.New("invalid witness program")
+)
+
+// Network holds the address prefixes of a Bitcoin network.
+type Network struct {
+	Name string
+	// Bech32HRP is the human readable part of the segwit addresses.
+	Bech32HRP string
+	// PubKeyHashVersion and ScriptHashVersion are the version bytes of the base58 P2PKH and P2SH addresses.
+	PubKeyHashVersion byte
+	ScriptHashVersion byte
+}
+
+var (
+	MainNet = Network{Name: "mainnet", Bech32HRP: "bc", PubKeyHashVersion: 0x00, ScriptHashVersion: 0x05}
+	TestNet = Network{Name: "testnet", Bech32HRP: "tb", PubKeyHashVersion: 0x6f, ScriptHashVersion: 0xc4}
+	RegTest = Network{Name: "regtest", Bech32HRP: "bcrt", PubKeyHashVersion: 0x6f, ScriptHashVersion: 0xc4}
+)
+
+// ParseNetwork returns the network with the given name, mainnet when empty.
+func ParseNetwork(name string) (Network, error) {
+	for _, n := range []Network{MainNet, TestNet, RegTest} {
+		if strings.EqualFold(name, n.Name) {
+			return n, nil
+		}
+	}
+	if name == "" {
+		return MainNet, nil
+	}
+	return Network{}, fmt.Errorf("unknown bitcoin network %q", name)
+}
+
+// ValidateAddress checks a base58 (P2PKH, P2SH) or bech32/bech32m (segwit) address of the network
+// and returns its canonical form, segwit addresses being lower case.
+func (n Network) ValidateAddress(address string) (string, error) {
+	if i := strings.LastIndexByte(address, '1'); i > 0 && strings.EqualFold(address[:i], n.Bech32HRP) {
+		return n.validateSegwit(address)
+	}
+	if err := n.validateBase58(address); err != nil {
+		return "", err
+	}
+	return address, nil
+}
+
+// validateSegwit checks a segwit address as per BIP 173 and BIP 350.
+func (n Network) validateSegwit(address string) (string, error) {
+	hrp, data, encoding, err := decodeBech32(address)
+	if err != nil {
+		return "", err
+	}
+	if hrp != n.Bech32HRP {
+		return "", ErrInvalidNetwork
+	}
+	if len(data) < 1 || data[0] > 16 {
+		return "", ErrInvalidProgram
+	}
+	version := data[0]
+	program, err := convertBits(data[1:], 5, 8)
+	if err != nil {
+		return "", err
+	}
+	switch {
+	case len(program) < 2 || len(program) > 40:
+		return "", ErrInvalidProgram
+	case version == 0 && len(program) != 20 && len(program) != 32:
+		return "", ErrInvalidProgram
+	case version == 0 && encoding != bech32Const, version != 0 && encoding != bech32mConst:
+		return "", ErrInvalidChecksum
+	}
+	return strings.ToLower(address), nil
+}
+
+// validateBase58 checks a base58check P2PKH or P2SH address.
+func (n Network) validateBase58(address string) error {
+	payload, err := decodeBase58(address)
+	if err != nil {
+		return err
+	}
+	if len(payload) != 25 {
+		return fmt.Errorf("invalid address length %d", len(payload))
+	}
+	checksum := doubleSHA256(payload[:21])
+	if !bytes.Equal(checksum[:4], payload[21:]) {
+		return ErrInvalidChecksum
+	}
+	if payload[0] != n.PubKeyHashVersion && payload[0] != n.ScriptHashVersion {
+		return ErrInvalidNetwork
+	}
+	return nil
+}
+
+func doubleSHA256(b []byte) [32]byte {
+	h := sha256.Sum256(b)
+	return sha256.Sum256(h[:])
+}
+
+const base58Alphabet = "123456789ABCDEFGHJKLMNPQRSTUVWXYZabcdefghijkmnopqrstuvwxyz"
+
+// decodeBase58 decodes a base58 string, every leading '1' being a zero byte.
+func decodeBase58(s string) ([]byte, error) {
+	if s == "" {
+		return nil, errors.New("empty base58 string")
+	}
+	n := new(big.Int)
+	radix := big.NewInt(58)
+	for _, c := range s {
+		i := strings.IndexRune(base58Alphabet, c)
+		if i < 0 {
+			return nil, fmt.Errorf("invalid base58 character %q", c)
+		}
+		n.Mul(n, radix)
+		n.Add(n, big.NewInt(int64(i)))
+	}
+	zeros := len(s) - len(strings.TrimLeft(s, "1"))
+	return append(make([]byte, zeros), n.Bytes()...), nil
+}
+
+const (
+	bech32Charset = "qpzry9x8gf2tvdw0s3jn54khce6mua7l"
+	// bech32Const and bech32mConst are the checksum constants of BIP 173 and BIP 350.
+	bech32Const  = 1
+	bech32mConst = 0x2bc830a3
+)
+
+// decodeBech32 decodes a bech32 or bech32m string into its human readable part and 5 bits data,
+// without the checksum, and returns the checksum constant it matched.
+func decodeBech32(s string) (string, []byte, uint32, error) {
+	if len(s) > 90 {
+		return "", nil, 0, fmt.Errorf("invalid bech32 length %d", len(s))
+	}
+	if strings.ToLower(s) != s && strings.ToUpper(s) != s {
+		return "", nil, 0, errors.New("mixed case bech32 string")
+	}
+	s = strings.ToLower(s)
+	pos := strings.LastIndexByte(s, '1')
+	if pos < 1 || pos+7 > len(s) {
+		return "", nil, 0, errors.New("invalid bech32 separator position")
+	}
+	hrp := s[:pos]
+	data := make([]byte, 0, len(s)-pos-1)
+	for _, c := range s[pos+1:] {
+		i := strings.IndexRune(bech32Charset, c)
+		if i < 0 {
+			return "", nil, 0, fmt.Errorf("invalid bech32 character %q", c)
+		}
+		data = append(data, byte(i))
+	}
+	encoding := bech32Polymod(append(hrpExpand(hrp), data...))
+	if encoding != bech32Const && encoding != bech32mConst {
+		return "", nil, 0, ErrInvalidChecksum
+	}
+	return hrp, data[:len(data)-6], encoding, nil
+}
+
+func hrpExpand(hrp string) []byte {
+	res := make([]byte, 0, 2*len(hrp)+1)
+	for i := 0; i < len(hrp); i++ {
+		res = append(res, hrp[i]>>5)
+	}
+	res = append(res, 0)
+	for i := 0; i < len(hrp); i++ {
+		res = append(res, hrp[i]&31)
+	}
+	return res
+}
+
+func bech32Polymod(values []byte) uint32 {
+	gen := [5]uint32{0x3b6a57b2, 0x26508e6d, 0x1ea119fa, 0x3d4233dd, 0x2a1462b3}
+	chk := uint32(1)
+	for _, v := range values {
+		b := chk >> 25
+		chk = (chk&0x1ffffff)<<5 ^ uint32(v)
+		for i := 0; i < 5; i++ {
+			if (b>>i)&1 == 1 {
+				chk ^= gen[i]
+			}
+		}
+	}
+	return chk
+}
+
+// convertBits regroups data of fromBits bits values into toBits bits values, the leftover bits
+// being zero padding.
+func convertBits(data []byte, fromBits, toBits uint) ([]byte, error) {
+	var acc uint32
+	var bits uint
+	maxv := uint32(1)<<toBits - 1
+	var res []byte
+	for _, v := range data {
+		acc = acc<<fromBits | uint32(v)
+		bits += fromBits
+		for bits >= toBits {
+			bits -= toBits
+			res = append(res, byte(acc>>bits&maxv))
+		}
+	}
+	if bits >= fromBits || acc<<(toBits-bits)&maxv != 0 {
+		return nil, ErrInvalidProgram
+	}
+	return res, nil
+}
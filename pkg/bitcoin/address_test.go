@@ -0,0 +1,41 @@
+package bitcoin
+
+import (
+	"testing"
+)
+
+func TestNetwork_ValidateAddress(t *testing.T) {
+	tests := []struct {
+		name    string
+		network Network
+		address string
+		want    string
+		wantErr bool
+	}{
+		{name: "Test P2WPKH", network: MainNet, address: "bc1qw508d6qejxtdg4y5r3zarvary0c5xw7kv8f3t4", want: "bc1qw508d6qejxtdg4y5r3zarvary0c5xw7kv8f3t4"},
+		{name: "Test upper case P2WPKH", network: MainNet, address: "BC1QW508D6QEJXTDG4Y5R3ZARVARY0C5XW7KV8F3T4", want: "bc1qw508d6qejxtdg4y5r3zarvary0c5xw7kv8f3t4"},
+		{name: "Test P2TR", network: MainNet, address: "bc1p0xlxvlhemja6c4dqv22uapctqupfhlxm9h8z3k2e72q4k9hcz7vqzk5jj0", want: "bc1p0xlxvlhemja6c4dqv22uapctqupfhlxm9h8z3k2e72q4k9hcz7vqzk5jj0"},
+		{name: "Test P2PKH", network: MainNet, address: "1BvBMSEYstWetqTFn5Au4m4GFg7xJaNVN2", want: "1BvBMSEYstWetqTFn5Au4m4GFg7xJaNVN2"},
+		{name: "Test P2SH", network: MainNet, address: "3J98t1WpEZ73CNmQviecrnyiWrnqRhWNLy", want: "3J98t1WpEZ73CNmQviecrnyiWrnqRhWNLy"},
+		{name: "Test testnet P2WPKH", network: TestNet, address: "tb1qw508d6qejxtdg4y5r3zarvary0c5xw7kxpjzsx", want: "tb1qw508d6qejxtdg4y5r3zarvary0c5xw7kxpjzsx"},
+		{name: "Test testnet address on mainnet", network: MainNet, address: "tb1qw508d6qejxtdg4y5r3zarvary0c5xw7kxpjzsx", wantErr: true},
+		{name: "Test mainnet P2PKH on testnet", network: TestNet, address: "1BvBMSEYstWetqTFn5Au4m4GFg7xJaNVN2", wantErr: true},
+		{name: "Test segwit v0 with bech32m checksum", network: MainNet, address: "bc1qw508d6qejxtdg4y5r3zarvary0c5xw7kemeawh", wantErr: true},
+		{name: "Test mixed case", network: MainNet, address: "bc1qw508d6qejxtdg4y5r3zarvary0c5xw7kV8f3t4", wantErr: true},
+		{name: "Test bad bech32 checksum", network: MainNet, address: "bc1qw508d6qejxtdg4y5r3zarvary0c5xw7kv8f3t5", wantErr: true},
+		{name: "Test bad base58 checksum", network: MainNet, address: "1BvBMSEYstWetqTFn5Au4m4GFg7xJaNVN3", wantErr: true},
+		{name: "Test invalid base58 character", network: MainNet, address: "1BvBMSEYstWetqTFn5Au4m4GFg7xJaNVN0", wantErr: true},
+		{name: "Test Ethereum address", network: MainNet, address: "0xc0ffee254729296a45a3885639AC7E10F9d54979", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.network.ValidateAddress(tt.address)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Network.ValidateAddress() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("Network.ValidateAddress() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
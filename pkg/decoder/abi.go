@@ -0,0 +1,292 @@
+package decoder
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+const wordSize = 32
+
+type typeKind int
+
+const (
+	kindAddress typeKind = iota
+	kindBool
+	kindUint
+	kindInt
+	kindFixedBytes
+	kindBytes
+	kindString
+	kindSlice
+	kindArray
+	kindTuple
+)
+
+// abiType is a parsed canonical ABI type such as uint256, address[] or (bytes,uint8)[2].
+type abiType struct {
+	kind  typeKind
+	size  int
+	elem  *abiType
+	elems []*abiType
+}
+
+// parseType parses a canonical ABI type.
+func parseType(t string) (*abiType, error) {
+	if strings.HasSuffix(t, "]") {
+		i := strings.LastIndex(t, "[")
+		if i < 0 {
+			return nil, fmt.Errorf("invalid type %q", t)
+		}
+		elem, err := parseType(t[:i])
+		if err != nil {
+			return nil, err
+		}
+		if t[i+1:len(t)-1] == "" {
+			return &abiType{kind: kindSlice, elem: elem}, nil
+		}
+		n, err := strconv.Atoi(t[i+1 : len(t)-1])
+		if err != nil || n <= 0 {
+			return nil, fmt.Errorf("invalid array length in type %q", t)
+		}
+		return &abiType{kind: kindArray, size: n, elem: elem}, nil
+	}
+	if strings.HasPrefix(t, "(") && strings.HasSuffix(t, ")") {
+		var elems []*abiType
+		for _, c := range splitTypes(t[1 : len(t)-1]) {
+			e, err := parseType(c)
+			if err != nil {
+				return nil, err
+			}
+			elems = append(elems, e)
+		}
+		return &abiType{kind: kindTuple, elems: elems}, nil
+	}
+	switch {
+	case t == "address":
+		return &abiType{kind: kindAddress}, nil
+	case t == "bool":
+		return &abiType{kind: kindBool}, nil
+	case t == "string":
+		return &abiType{kind: kindString}, nil
+	case t == "bytes":
+		return &abiType{kind: kindBytes}, nil
+	case strings.HasPrefix(t, "uint"):
+		bits, err := typeSize(t, "uint")
+		return &abiType{kind: kindUint, size: bits}, err
+	case strings.HasPrefix(t, "int"):
+		bits, err := typeSize(t, "int")
+		return &abiType{kind: kindInt, size: bits}, err
+	case strings.HasPrefix(t, "bytes"):
+		n, err := strconv.Atoi(strings.TrimPrefix(t, "bytes"))
+		if err != nil || n < 1 || n > wordSize {
+			return nil, fmt.Errorf("invalid type %q", t)
+		}
+		return &abiType{kind: kindFixedBytes, size: n}, nil
+	}
+	return nil, fmt.Errorf("unsupported type %q", t)
+}
+
+// typeSize returns the bit size of an integer type, uint and int being aliases of their 256 bit versions.
+func typeSize(t, prefix string) (int, error) {
+	s := strings.TrimPrefix(t, prefix)
+	if s == "" {
+		return 256, nil
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil || n <= 0 || n > 256 || n%8 != 0 {
+		return 0, fmt.Errorf("invalid type %q", t)
+	}
+	return n, nil
+}
+
+// splitTypes splits a comma separated type list, ignoring the commas nested in tuples.
+func splitTypes(list string) []string {
+	if list == "" {
+		return nil
+	}
+	var types []string
+	depth, start := 0, 0
+	for i, c := range list {
+		switch c {
+		case '(':
+			depth++
+		case ')':
+			depth--
+		case ',':
+			if depth == 0 {
+				types = append(types, list[start:i])
+				start = i + 1
+			}
+		}
+	}
+	return append(types, list[start:])
+}
+
+func (t *abiType) dynamic() bool {
+	switch t.kind {
+	case kindBytes, kindString, kindSlice:
+		return true
+	case kindArray:
+		return t.elem.dynamic()
+	case kindTuple:
+		for _, e := range t.elems {
+			if e.dynamic() {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// headSize is the number of bytes a type takes in the head of its enclosing tuple.
+func (t *abiType) headSize() int {
+	if t.dynamic() {
+		return wordSize
+	}
+	switch t.kind {
+	case kindArray:
+		return t.size * t.elem.headSize()
+	case kindTuple:
+		size := 0
+		for _, e := range t.elems {
+			size += e.headSize()
+		}
+		return size
+	}
+	return wordSize
+}
+
+// decodeTuple decodes the values of types laid out with the ABI head/tail encoding in data.
+func decodeTuple(types []*abiType, data []byte) ([]interface{}, error) {
+	d := &abiDecoder{words: len(data) / wordSize}
+	return d.tuple(types, data)
+}
+
+// abiDecoder decodes values while keeping their size within the one of the calldata. The offsets
+// of the dynamic values may point to the same data, the elements of an array all decoding the same
+// blob would otherwise take memory quadratic in the size of the calldata.
+type abiDecoder struct {
+	// words is the number of words left to decode, a value taking at least one.
+	words int
+}
+
+// errOverlap is returned when the decoded values do not fit in the calldata.
+var errOverlap = errors.New("decoded values larger than the data, offsets overlap")
+
+// take accounts for n decoded words.
+func (d *abiDecoder) take(n int) error {
+	if n > d.words {
+		return errOverlap
+	}
+	d.words -= n
+	return nil
+}
+
+func (d *abiDecoder) tuple(types []*abiType, data []byte) ([]interface{}, error) {
+	values := make([]interface{}, len(types))
+	pos := 0
+	for i, t := range types {
+		if pos+t.headSize() > len(data) {
+			return nil, fmt.Errorf("data too short for argument %d", i)
+		}
+		start := pos
+		if t.dynamic() {
+			offset, err := readLength(data[pos:], len(data))
+			if err != nil {
+				return nil, fmt.Errorf("argument %d: %w", i, err)
+			}
+			start = offset
+		}
+		v, err := d.value(t, data[start:])
+		if err != nil {
+			return nil, fmt.Errorf("argument %d: %w", i, err)
+		}
+		values[i] = v
+		pos += t.headSize()
+	}
+	return values, nil
+}
+
+// value decodes a single value of type t starting at the beginning of data.
+func (d *abiDecoder) value(t *abiType, data []byte) (interface{}, error) {
+	switch t.kind {
+	case kindSlice:
+		n, err := readLength(data, (len(data)-wordSize)/wordSize)
+		if err != nil {
+			return nil, err
+		}
+		// the length and at least a word per element.
+		if err := d.take(1); err != nil {
+			return nil, err
+		}
+		if n > d.words {
+			return nil, errOverlap
+		}
+		return d.tuple(repeatType(t.elem, n), data[wordSize:])
+	case kindArray:
+		return d.tuple(repeatType(t.elem, t.size), data)
+	case kindTuple:
+		return d.tuple(t.elems, data)
+	case kindBytes, kindString:
+		n, err := readLength(data, len(data)-wordSize)
+		if err != nil {
+			return nil, err
+		}
+		if err := d.take(1 + (n+wordSize-1)/wordSize); err != nil {
+			return nil, err
+		}
+		b := data[wordSize : wordSize+n]
+		if t.kind == kindString {
+			return string(b), nil
+		}
+		return "0x" + hex.EncodeToString(b), nil
+	}
+	if len(data) < wordSize {
+		return nil, fmt.Errorf("data too short")
+	}
+	if err := d.take(1); err != nil {
+		return nil, err
+	}
+	word := data[:wordSize]
+	switch t.kind {
+	case kindAddress:
+		return "0x" + hex.EncodeToString(word[12:]), nil
+	case kindBool:
+		return word[wordSize-1] == 1, nil
+	case kindUint:
+		return new(big.Int).SetBytes(word).String(), nil
+	case kindInt:
+		v := new(big.Int).SetBytes(word)
+		if word[0]&0x80 != 0 {
+			v.Sub(v, new(big.Int).Lsh(big.NewInt(1), 256))
+		}
+		return v.String(), nil
+	case kindFixedBytes:
+		return "0x" + hex.EncodeToString(word[:t.size]), nil
+	}
+	return nil, fmt.Errorf("unsupported type kind %d", t.kind)
+}
+
+// readLength reads a length or offset word and checks it does not exceed max.
+func readLength(data []byte, max int) (int, error) {
+	if len(data) < wordSize {
+		return 0, fmt.Errorf("data too short")
+	}
+	v := new(big.Int).SetBytes(data[:wordSize])
+	if max < 0 || !v.IsInt64() || v.Int64() > int64(max) {
+		return 0, fmt.Errorf("length or offset %s out of range", v)
+	}
+	return int(v.Int64()), nil
+}
+
+func repeatType(t *abiType, n int) []*abiType {
+	types := make([]*abiType, n)
+	for i := range types {
+		types[i] = t
+	}
+	return types
+}
@@ -0,0 +1,31 @@
+package decoder
+
+// builtinSignatures is the table of well known method signatures decoded without any ABI loaded.
+var builtinSignatures = []string{
+	// ERC-20.
+	"transfer(address,uint256)",
+	"transferFrom(address,address,uint256)",
+	"approve(address,uint256)",
+	"increaseAllowance(address,uint256)",
+	"decreaseAllowance(address,uint256)",
+	// ERC-721.
+	"safeTransferFrom(address,address,uint256)",
+	"safeTransferFrom(address,address,uint256,bytes)",
+	"setApprovalForAll(address,bool)",
+	// ERC-1155.
+	"safeTransferFrom(address,address,uint256,uint256,bytes)",
+	"safeBatchTransferFrom(address,address,uint256[],uint256[],bytes)",
+	// WETH.
+	"deposit()",
+	"withdraw(uint256)",
+	// Uniswap V2 router.
+	"swapExactETHForTokens(uint256,address[],address,uint256)",
+	"swapETHForExactTokens(uint256,address[],address,uint256)",
+	"swapExactTokensForETH(uint256,uint256,address[],address,uint256)",
+	"swapTokensForExactETH(uint256,uint256,address[],address,uint256)",
+	"swapExactTokensForTokens(uint256,uint256,address[],address,uint256)",
+	"swapTokensForExactTokens(uint256,uint256,address[],address,uint256)",
+	// Multicall.
+	"multicall(bytes[])",
+	"multicall(uint256,bytes[])",
+}
@@ -0,0 +1,250 @@
+// Package decoder decodes transaction calldata into method calls using contract ABIs.
+package decoder
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/pmes126/tx-parser-service/internal/keccak"
+)
+
+const selectorLength = 4
+
+var (
+	ErrNoCalldata      = errors.New("no calldata")
+	ErrUnknownSelector = errors.New("unknown method selector")
+)
+
+// Argument is a named method argument with its canonical ABI type.
+type Argument struct {
+	Name string
+	Type string
+}
+
+// Method is a contract method identified by its 4-byte selector.
+type Method struct {
+	Name      string
+	Signature string
+	Selector  string
+	Inputs    []Argument
+	types     []*abiType
+}
+
+// DecodedInput is the calldata of a transaction decoded into a method call.
+type DecodedInput struct {
+	Method    string       `json:"method"`
+	Signature string       `json:"signature"`
+	Args      []DecodedArg `json:"args"`
+}
+
+// DecodedArg is a decoded method argument. Integers are rendered as decimal strings and
+// byte values as 0x prefixed hex strings.
+type DecodedArg struct {
+	Name  string      `json:"name,omitempty"`
+	Type  string      `json:"type"`
+	Value interface{} `json:"value"`
+}
+
+// Decoder holds the known methods, globally and per contract address.
+type Decoder struct {
+	methods   map[string]Method
+	contracts map[string]map[string]Method
+	mx        sync.RWMutex
+}
+
+// abiEntry is an entry of a contract ABI JSON file.
+type abiEntry struct {
+	Type   string        `json:"type"`
+	Name   string        `json:"name"`
+	Inputs []abiArgument `json:"inputs"`
+}
+
+type abiArgument struct {
+	Name       string        `json:"name"`
+	Type       string        `json:"type"`
+	Components []abiArgument `json:"components"`
+}
+
+// NewDecoder creates a new Decoder populated with the built-in selector table.
+func NewDecoder() *Decoder {
+	d := &Decoder{
+		methods:   make(map[string]Method),
+		contracts: make(map[string]map[string]Method),
+	}
+	for _, sig := range builtinSignatures {
+		m, err := methodFromSignature(sig)
+		if err != nil {
+			panic(fmt.Sprintf("invalid built-in signature %q: %v", sig, err))
+		}
+		d.methods[m.Selector] = m
+	}
+	return d
+}
+
+// LoadABIDir loads every *.json ABI file of a directory. Files named after a contract address
+// (e.g. 0xa0b8...eb48.json) only apply to that contract, all other files apply to every contract.
+func (d *Decoder) LoadABIDir(dir string) error {
+	files, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return err
+	}
+	for _, file := range files {
+		contract := strings.TrimSuffix(filepath.Base(file), ".json")
+		if !isAddress(contract) {
+			contract = ""
+		}
+		f, err := os.Open(file)
+		if err != nil {
+			return err
+		}
+		err = d.LoadABI(contract, f)
+		f.Close()
+		if err != nil {
+			return fmt.Errorf("loading ABI %s: %w", file, err)
+		}
+	}
+	return nil
+}
+
+// LoadABI loads the functions of an ABI, either a plain JSON array or an object with an "abi"
+// field as produced by most build tools. An empty contract makes the methods global.
+func (d *Decoder) LoadABI(contract string, r io.Reader) error {
+	raw, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	var entries []abiEntry
+	if err := json.Unmarshal(raw, &entries); err != nil {
+		var artifact struct {
+			ABI []abiEntry `json:"abi"`
+		}
+		if err := json.Unmarshal(raw, &artifact); err != nil {
+			return err
+		}
+		entries = artifact.ABI
+	}
+	methods := make(map[string]Method)
+	for _, e := range entries {
+		if e.Type != "function" {
+			continue
+		}
+		m := Method{Name: e.Name}
+		types := make([]string, len(e.Inputs))
+		for i, in := range e.Inputs {
+			types[i] = canonicalType(in)
+			m.Inputs = append(m.Inputs, Argument{Name: in.Name, Type: types[i]})
+		}
+		m.Signature = fmt.Sprintf("%s(%s)", e.Name, strings.Join(types, ","))
+		if err := m.init(); err != nil {
+			return err
+		}
+		methods[m.Selector] = m
+	}
+
+	d.mx.Lock()
+	defer d.mx.Unlock()
+	target := d.methods
+	if contract != "" {
+		contract = strings.ToLower(contract)
+		if d.contracts[contract] == nil {
+			d.contracts[contract] = make(map[string]Method)
+		}
+		target = d.contracts[contract]
+	}
+	for sel, m := range methods {
+		target[sel] = m
+	}
+	return nil
+}
+
+// Decode decodes the calldata of a transaction sent to contract. Methods loaded for the contract
+// take precedence over the global ones.
+func (d *Decoder) Decode(contract, input string) (*DecodedInput, error) {
+	data, err := hex.DecodeString(strings.TrimPrefix(input, "0x"))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < selectorLength {
+		return nil, ErrNoCalldata
+	}
+	selector := "0x" + hex.EncodeToString(data[:selectorLength])
+
+	d.mx.RLock()
+	m, ok := d.contracts[strings.ToLower(contract)][selector]
+	if !ok {
+		m, ok = d.methods[selector]
+	}
+	d.mx.RUnlock()
+	if !ok {
+		return nil, ErrUnknownSelector
+	}
+
+	values, err := decodeTuple(m.types, data[selectorLength:])
+	if err != nil {
+		return nil, fmt.Errorf("decoding %s: %w", m.Signature, err)
+	}
+	decoded := &DecodedInput{
+		Method:    m.Name,
+		Signature: m.Signature,
+		Args:      make([]DecodedArg, len(values)),
+	}
+	for i, v := range values {
+		decoded.Args[i] = DecodedArg{Name: m.Inputs[i].Name, Type: m.Inputs[i].Type, Value: v}
+	}
+	return decoded, nil
+}
+
+// init computes the selector of a method and parses its argument types.
+func (m *Method) init() error {
+	m.types = make([]*abiType, len(m.Inputs))
+	for i, in := range m.Inputs {
+		t, err := parseType(in.Type)
+		if err != nil {
+			return fmt.Errorf("method %s: %w", m.Name, err)
+		}
+		m.types[i] = t
+	}
+	hash := keccak.Sum256([]byte(m.Signature))
+	m.Selector = "0x" + hex.EncodeToString(hash[:selectorLength])
+	return nil
+}
+
+// methodFromSignature builds a method with unnamed arguments from a signature such as transfer(address,uint256).
+func methodFromSignature(sig string) (Method, error) {
+	open := strings.Index(sig, "(")
+	if open <= 0 || !strings.HasSuffix(sig, ")") {
+		return Method{}, fmt.Errorf("invalid signature %q", sig)
+	}
+	m := Method{Name: sig[:open], Signature: sig}
+	for _, t := range splitTypes(sig[open+1 : len(sig)-1]) {
+		m.Inputs = append(m.Inputs, Argument{Type: t})
+	}
+	return m, m.init()
+}
+
+// canonicalType returns the type of an argument as used in signatures, expanding tuples.
+func canonicalType(arg abiArgument) string {
+	if !strings.HasPrefix(arg.Type, "tuple") {
+		return arg.Type
+	}
+	components := make([]string, len(arg.Components))
+	for i, c := range arg.Components {
+		components[i] = canonicalType(c)
+	}
+	return "(" + strings.Join(components, ",") + ")" + strings.TrimPrefix(arg.Type, "tuple")
+}
+
+func isAddress(s string) bool {
+	if len(s) != 42 || !strings.HasPrefix(s, "0x") {
+		return false
+	}
+	_, err := hex.DecodeString(s[2:])
+	return err == nil
+}
@@ -0,0 +1,146 @@
+package decoder
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func word(hex string) string {
+	return strings.Repeat("0", 64-len(hex)) + hex
+}
+
+const testABI = `{"abi": [
+	{"type": "function", "name": "register", "inputs": [
+		{"name": "label", "type": "string"},
+		{"name": "ids", "type": "uint256[]"},
+		{"name": "delta", "type": "int256"}
+	]},
+	{"type": "function", "name": "submit", "inputs": [
+		{"name": "order", "type": "tuple", "components": [
+			{"name": "maker", "type": "address"},
+			{"name": "amount", "type": "uint256"}
+		]}
+	]},
+	{"type": "function", "name": "tag", "inputs": [
+		{"name": "tags", "type": "string[]"}
+	]},
+	{"type": "event", "name": "Registered", "inputs": []}
+]}`
+
+func TestDecoder_Decode(t *testing.T) {
+	dir := t.TempDir()
+	contract := "0x1111111111111111111111111111111111111111"
+	if err := os.WriteFile(filepath.Join(dir, contract+".json"), []byte(testABI), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	d := NewDecoder()
+	if err := d.LoadABIDir(dir); err != nil {
+		t.Fatalf("Decoder.LoadABIDir() error = %v", err)
+	}
+
+	tests := []struct {
+		name       string
+		contract   string
+		input      string
+		wantMethod string
+		wantArgs   []interface{}
+		wantErr    error
+	}{
+		{
+			name:       "Test built-in transfer",
+			contract:   "0x2222222222222222222222222222222222222222",
+			input:      "0xa9059cbb" + word("c0ffee254729296a45a3885639ac7e10f9d54979") + word("f4240"),
+			wantMethod: "transfer",
+			wantArgs:   []interface{}{"0xc0ffee254729296a45a3885639ac7e10f9d54979", "1000000"},
+		},
+		{
+			name:       "Test contract ABI dynamic arguments",
+			contract:   contract,
+			input:      "0x" + selectorOf(t, "register(string,uint256[],int256)") + word("60") + word("a0") + strings.Repeat("f", 64) + word("3") + "616263" + strings.Repeat("0", 58) + word("2") + word("1") + word("2"),
+			wantMethod: "register",
+			wantArgs:   []interface{}{"abc", []interface{}{"1", "2"}, "-1"},
+		},
+		{
+			name:       "Test contract ABI tuple",
+			contract:   contract,
+			input:      "0x" + selectorOf(t, "submit((address,uint256))") + word("c0ffee254729296a45a3885639ac7e10f9d54979") + word("a"),
+			wantMethod: "submit",
+			wantArgs:   []interface{}{[]interface{}{"0xc0ffee254729296a45a3885639ac7e10f9d54979", "10"}},
+		},
+		{
+			name:       "Test contract ABI string array",
+			contract:   contract,
+			input:      "0x" + selectorOf(t, "tag(string[])") + word("20") + word("2") + word("40") + word("80") + word("1") + "61" + strings.Repeat("0", 62) + word("1") + "62" + strings.Repeat("0", 62),
+			wantMethod: "tag",
+			wantArgs:   []interface{}{[]interface{}{"a", "b"}},
+		},
+		{
+			name:     "Test overlapping offsets",
+			contract: contract,
+			// the three strings of the array point to the same 64 bytes.
+			input:   "0x" + selectorOf(t, "tag(string[])") + word("20") + word("3") + word("60") + word("60") + word("60") + word("40") + strings.Repeat("61", 64),
+			wantErr: errOverlap,
+		},
+		{
+			name:     "Test contract ABI not applied to other contracts",
+			contract: "0x2222222222222222222222222222222222222222",
+			input:    "0x" + selectorOf(t, "submit((address,uint256))") + word("c0ffee254729296a45a3885639ac7e10f9d54979") + word("a"),
+			wantErr:  ErrUnknownSelector,
+		},
+		{
+			name:    "Test no calldata",
+			input:   "0x",
+			wantErr: ErrNoCalldata,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := d.Decode(tt.contract, tt.input)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Decoder.Decode() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr != nil {
+				return
+			}
+			if got.Method != tt.wantMethod {
+				t.Errorf("Decoder.Decode() method = %v, want %v", got.Method, tt.wantMethod)
+			}
+			var args []interface{}
+			for _, a := range got.Args {
+				args = append(args, a.Value)
+			}
+			if !reflect.DeepEqual(args, tt.wantArgs) {
+				t.Errorf("Decoder.Decode() args = %v, want %v", args, tt.wantArgs)
+			}
+		})
+	}
+}
+
+func selectorOf(t *testing.T, sig string) string {
+	m, err := methodFromSignature(sig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return strings.TrimPrefix(m.Selector, "0x")
+}
+
+func TestBuiltinSelectors(t *testing.T) {
+	want := map[string]string{
+		"transfer(address,uint256)":             "0xa9059cbb",
+		"approve(address,uint256)":              "0x095ea7b3",
+		"transferFrom(address,address,uint256)": "0x23b872dd",
+	}
+	for sig, sel := range want {
+		m, err := methodFromSignature(sig)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if m.Selector != sel {
+			t.Errorf("methodFromSignature(%s).Selector = %v, want %v", sig, m.Selector, sel)
+		}
+	}
+}
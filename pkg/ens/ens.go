@@ -0,0 +1,340 @@
+// Package ens resolves Ethereum Name Service names, e.g. vitalik.eth, to addresses with eth_call
+// on the ENS registry and resolver contracts.
+package ens
+
+import (
+	"container/list"
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pmes126/tx-parser-service/internal/keccak"
+	"github.com/pmes126/tx-parser-service/internal/rpc"
+)
+
+const (
+	EthCall = "eth_call"
+
+	// RegistryAddress is the address of the ENS registry on mainnet and the main testnets.
+	RegistryAddress = "0x00000000000c2e074ec69a0dfb2997ba6c7d2e1e"
+	// ResolverSelector is the selector of resolver(bytes32) of the registry.
+	ResolverSelector = "0x0178b8bf"
+	// AddrSelector is the selector of addr(bytes32) of a resolver.
+	AddrSelector = "0x3b3b57de"
+
+	DefaultTTL = 5 * time.Minute
+	// DefaultNotFoundTTL is how long a name that was not found is cached, short so that a newly
+	// registered name is picked up soon.
+	DefaultNotFoundTTL = 30 * time.Second
+	// DefaultCacheSize is the number of names cached, the least recently used one being evicted
+	// beyond it.
+	DefaultCacheSize = 10000
+)
+
+var (
+	ErrInvalidName  = errors.New("invalid ENS name")
+	ErrNameNotFound = errors.New("ENS name not found")
+)
+
+// zeroAddress is returned by the registry and the resolvers for the names they do not know.
+const zeroAddress = "0x0000000000000000000000000000000000000000"
+
+// Option configures optional behaviour of a Resolver.
+type Option func(*Resolver)
+
+// WithRegistry sets the address of the ENS registry, RegistryAddress by default.
+func WithRegistry(address string) Option {
+	return func(r *Resolver) {
+		r.registry = strings.ToLower(address)
+	}
+}
+
+// WithTTL sets how long a resolution is cached, DefaultTTL by default.
+func WithTTL(ttl time.Duration) Option {
+	return func(r *Resolver) {
+		if ttl > 0 {
+			r.ttl = ttl
+		}
+	}
+}
+
+// WithNotFoundTTL sets how long a name that was not found is cached, DefaultNotFoundTTL by default.
+func WithNotFoundTTL(ttl time.Duration) Option {
+	return func(r *Resolver) {
+		if ttl > 0 {
+			r.notFoundTTL = ttl
+		}
+	}
+}
+
+// WithCacheSize sets the number of names cached, DefaultCacheSize by default.
+func WithCacheSize(n int) Option {
+	return func(r *Resolver) {
+		if n > 0 {
+			r.cacheSize = n
+		}
+	}
+}
+
+// WithOnChange calls fn when a watched name is re-resolved to another address.
+func WithOnChange(fn func(name, previous, current string)) Option {
+	return func(r *Resolver) {
+		r.onChange = fn
+	}
+}
+
+// Resolver resolves ENS names with a bounded cache of their addresses, and re-resolves the watched
+// names periodically to detect those pointing to another address.
+type Resolver struct {
+	rpc         *rpc.Client
+	logger      *slog.Logger
+	registry    string
+	ttl         time.Duration
+	notFoundTTL time.Duration
+	cacheSize   int
+	onChange    func(name, previous, current string)
+	now         func() time.Time
+	mx          sync.Mutex
+	// cache holds the elements of recent, the resolutions from the most to the least recently used.
+	cache  map[string]*list.Element
+	recent *list.List
+	// watched are the names re-resolved by Refresh, with the address they last resolved to.
+	watched map[string]string
+}
+
+// resolution is a cached address of a name, empty if the name was not found.
+type resolution struct {
+	name    string
+	address string
+	expires time.Time
+}
+
+// NewResolver creates a new Resolver sending its eth_calls with client.
+func NewResolver(client *rpc.Client, log *slog.Logger, opts ...Option) *Resolver {
+	r := &Resolver{
+		rpc:         client,
+		logger:      log,
+		registry:    RegistryAddress,
+		ttl:         DefaultTTL,
+		notFoundTTL: DefaultNotFoundTTL,
+		cacheSize:   DefaultCacheSize,
+		now:         time.Now,
+		cache:       make(map[string]*list.Element),
+		recent:      list.New(),
+		watched:     make(map[string]string),
+	}
+	for _, opt := range opts {
+		opt(r)
+	}
+	return r
+}
+
+// IsName reports whether s is an ENS name rather than an address, i.e. a dotted name.
+func IsName(s string) bool {
+	return strings.Contains(s, ".") && !strings.HasPrefix(s, "0x")
+}
+
+// Normalize returns the lower case form of a name, failing for the names with an empty label. It
+// does not implement the full ENSIP-15 normalisation, the names are expected in their usual form.
+func Normalize(name string) (string, error) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	if name == "" {
+		return "", ErrInvalidName
+	}
+	for _, label := range strings.Split(name, ".") {
+		if label == "" {
+			return "", fmt.Errorf("%w: %q", ErrInvalidName, name)
+		}
+	}
+	return name, nil
+}
+
+// Namehash returns the node of a name as per EIP-137, the hash of the node of its parent with the
+// hash of its first label.
+func Namehash(name string) [32]byte {
+	var node [32]byte
+	if name == "" {
+		return node
+	}
+	labels := strings.Split(name, ".")
+	for i := len(labels) - 1; i >= 0; i-- {
+		label := keccak.Sum256([]byte(labels[i]))
+		node = keccak.Sum256(append(node[:], label[:]...))
+	}
+	return node
+}
+
+// Resolve returns the lower case address a name points to, from the cache while the resolution
+// has not expired. Names that were not found are cached too, for the not found TTL.
+func (r *Resolver) Resolve(ctx context.Context, name string) (string, error) {
+	name, err := Normalize(name)
+	if err != nil {
+		return "", err
+	}
+	if cached, ok := r.cached(name); ok {
+		if cached.address == "" {
+			return "", fmt.Errorf("%w: %s", ErrNameNotFound, name)
+		}
+		return cached.address, nil
+	}
+	address, err := r.lookup(ctx, name)
+	if errors.Is(err, ErrNameNotFound) {
+		r.store(name, "")
+	}
+	if err != nil {
+		return "", err
+	}
+	r.store(name, address)
+	return address, nil
+}
+
+// cached returns the resolution of a name from the cache, removing it once expired.
+func (r *Resolver) cached(name string) (resolution, bool) {
+	r.mx.Lock()
+	defer r.mx.Unlock()
+	e, ok := r.cache[name]
+	if !ok {
+		return resolution{}, false
+	}
+	res := e.Value.(resolution)
+	if !r.now().Before(res.expires) {
+		r.recent.Remove(e)
+		delete(r.cache, name)
+		return resolution{}, false
+	}
+	r.recent.MoveToFront(e)
+	return res, true
+}
+
+// store caches the address of a name, or that it was not found when address is empty, evicting
+// the least recently used name once the cache is full.
+func (r *Resolver) store(name, address string) {
+	ttl := r.ttl
+	if address == "" {
+		ttl = r.notFoundTTL
+	}
+	res := resolution{name: name, address: address, expires: r.now().Add(ttl)}
+	r.mx.Lock()
+	defer r.mx.Unlock()
+	if e, ok := r.cache[name]; ok {
+		e.Value = res
+		r.recent.MoveToFront(e)
+		return
+	}
+	r.cache[name] = r.recent.PushFront(res)
+	if r.recent.Len() > r.cacheSize {
+		oldest := r.recent.Back()
+		r.recent.Remove(oldest)
+		delete(r.cache, oldest.Value.(resolution).name)
+	}
+}
+
+// Watch adds a name resolved to address to the names re-resolved by Refresh.
+func (r *Resolver) Watch(name, address string) {
+	name, err := Normalize(name)
+	if err != nil {
+		return
+	}
+	r.mx.Lock()
+	defer r.mx.Unlock()
+	r.watched[name] = strings.ToLower(address)
+}
+
+// Refresh re-resolves the watched names, bypassing the cache, and reports those now pointing to
+// another address to the WithOnChange function.
+func (r *Resolver) Refresh(ctx context.Context) error {
+	r.mx.Lock()
+	names := make([]string, 0, len(r.watched))
+	for name := range r.watched {
+		names = append(names, name)
+	}
+	r.mx.Unlock()
+	var errs []error
+	for _, name := range names {
+		address, err := r.lookup(ctx, name)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("resolving %s: %w", name, err))
+			continue
+		}
+		r.store(name, address)
+		r.mx.Lock()
+		previous := r.watched[name]
+		r.watched[name] = address
+		r.mx.Unlock()
+		if previous != address {
+			r.logger.Info("ENS name points to another address", slog.String("name", name),
+				slog.String("previous", previous), slog.String("current", address))
+			if r.onChange != nil {
+				r.onChange(name, previous, address)
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Start re-resolves the watched names every interval until ctx is done.
+func (r *Resolver) Start(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := r.Refresh(ctx); err != nil {
+				r.logger.Error("Failed to re-resolve ENS names", slog.String("error", err.Error()))
+			}
+		}
+	}
+}
+
+// lookup resolves a name on chain: the registry returns the resolver of the name, which returns
+// its address.
+func (r *Resolver) lookup(ctx context.Context, name string) (string, error) {
+	node := Namehash(name)
+	resolver, err := r.call(ctx, r.registry, ResolverSelector, node)
+	if err != nil {
+		return "", err
+	}
+	if resolver == zeroAddress {
+		return "", fmt.Errorf("%w: %s", ErrNameNotFound, name)
+	}
+	address, err := r.call(ctx, resolver, AddrSelector, node)
+	if err != nil {
+		return "", err
+	}
+	if address == zeroAddress {
+		return "", fmt.Errorf("%w: %s", ErrNameNotFound, name)
+	}
+	return address, nil
+}
+
+// call calls a function of a contract taking a node and returning an address.
+func (r *Resolver) call(ctx context.Context, contract, selector string, node [32]byte) (string, error) {
+	msg := map[string]string{
+		"to":   contract,
+		"data": selector + hex.EncodeToString(node[:]),
+	}
+	var result string
+	if err := r.rpc.Call(ctx, &result, EthCall, msg, "latest"); err != nil {
+		return "", err
+	}
+	// the address is right aligned in the 32 bytes word of the result.
+	word := strings.TrimPrefix(result, "0x")
+	if len(word) != 64 {
+		if word == "" {
+			// calls to an account without code return no data.
+			return zeroAddress, nil
+		}
+		return "", fmt.Errorf("invalid %s result %q", EthCall, result)
+	}
+	if _, err := hex.DecodeString(word); err != nil {
+		return "", fmt.Errorf("invalid %s result %q: %w", EthCall, result, err)
+	}
+	return "0x" + strings.ToLower(word[24:]), nil
+}
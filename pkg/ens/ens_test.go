@@ -0,0 +1,192 @@
+package ens
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/pmes126/tx-parser-service/internal/rpc"
+)
+
+func TestNamehash(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{name: "", want: "0000000000000000000000000000000000000000000000000000000000000000"},
+		{name: "eth", want: "93cdeb708b7545dc668eb9280176169d1c33cfd8ed6f04690a0bcc88a93fc4ae"},
+		{name: "foo.eth", want: "de9b09fd7c5f901e23a3f19fecc54828e9c848539801e86591bd9801b019f84f"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Namehash(tt.name)
+			if hex.EncodeToString(got[:]) != tt.want {
+				t.Errorf("Namehash() = %x, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// fakeENS is a node with the ENS registry and a resolver holding the addresses of names.
+type fakeENS struct {
+	mx        sync.Mutex
+	resolver  string
+	addresses map[string]string
+	calls     atomic.Int32
+}
+
+func (f *fakeENS) set(name, address string) {
+	f.mx.Lock()
+	defer f.mx.Unlock()
+	f.addresses[name] = address
+}
+
+func (f *fakeENS) serve(t *testing.T) *httptest.Server {
+	word := func(address string) string {
+		return "0x" + strings.Repeat("0", 24) + strings.TrimPrefix(address, "0x")
+	}
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req rpc.Request
+		json.NewDecoder(r.Body).Decode(&req)
+		f.calls.Add(1)
+		msg := req.Params[0].(map[string]interface{})
+		to, data := msg["to"].(string), msg["data"].(string)
+		f.mx.Lock()
+		defer f.mx.Unlock()
+		var address string
+		for name, a := range f.addresses {
+			node := Namehash(name)
+			if strings.HasSuffix(data, hex.EncodeToString(node[:])) {
+				address = a
+			}
+		}
+		result := word(zeroAddress)
+		switch {
+		case to == RegistryAddress && strings.HasPrefix(data, ResolverSelector) && address != "":
+			result = word(f.resolver)
+		case to == f.resolver && strings.HasPrefix(data, AddrSelector) && address != "":
+			result = word(address)
+		case to != RegistryAddress && to != f.resolver:
+			t.Errorf("eth_call to %v, want the registry or the resolver", to)
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"jsonrpc": rpc.Version, "id": req.Id, "result": result})
+	}))
+}
+
+func TestResolver_Resolve(t *testing.T) {
+	alice := "0xc0ffee254729296a45a3885639ac7e10f9d54979"
+	bob := "0x999999cf1046e68e36e1aa2e0e07105eddd1f08e"
+	node := &fakeENS{resolver: "0x4976fb03c32e5b8cfe2b6ccb31c09ba78ebaba41", addresses: map[string]string{"alice.eth": alice}}
+	srv := node.serve(t)
+	defer srv.Close()
+	now := time.Now()
+	var changes []string
+	r := NewResolver(rpc.NewClient(&http.Client{}, srv.URL), slog.New(slog.NewTextHandler(os.Stdout, nil)),
+		WithTTL(time.Minute),
+		WithOnChange(func(name, previous, current string) {
+			changes = append(changes, name+":"+previous+"->"+current)
+		}),
+	)
+	r.now = func() time.Time { return now }
+
+	if _, err := r.Resolve(context.Background(), "bob.eth"); !errors.Is(err, ErrNameNotFound) {
+		t.Errorf("Resolver.Resolve() error = %v, want %v", err, ErrNameNotFound)
+	}
+	if _, err := r.Resolve(context.Background(), "alice..eth"); !errors.Is(err, ErrInvalidName) {
+		t.Errorf("Resolver.Resolve() error = %v, want %v", err, ErrInvalidName)
+	}
+	got, err := r.Resolve(context.Background(), "Alice.eth")
+	if err != nil || got != alice {
+		t.Fatalf("Resolver.Resolve() = %v, %v, want %v", got, err, alice)
+	}
+	r.Watch("alice.eth", got)
+
+	// the name points to another address, the cached one is returned until it expires.
+	node.set("alice.eth", bob)
+	calls := node.calls.Load()
+	if got, _ := r.Resolve(context.Background(), "alice.eth"); got != alice || node.calls.Load() != calls {
+		t.Errorf("Resolver.Resolve() = %v after %d calls, want cached %v", got, node.calls.Load()-calls, alice)
+	}
+	now = now.Add(2 * time.Minute)
+	if got, _ := r.Resolve(context.Background(), "alice.eth"); got != bob {
+		t.Errorf("Resolver.Resolve() = %v, want %v once expired", got, bob)
+	}
+
+	if err := r.Refresh(context.Background()); err != nil {
+		t.Fatalf("Resolver.Refresh() error = %v", err)
+	}
+	want := "alice.eth:" + alice + "->" + bob
+	if len(changes) != 1 || changes[0] != want {
+		t.Errorf("Resolver.Refresh() changes = %v, want [%v]", changes, want)
+	}
+	if err := r.Refresh(context.Background()); err != nil || len(changes) != 1 {
+		t.Errorf("Resolver.Refresh() = %v, %v changes, want no new change", err, len(changes))
+	}
+}
+
+func TestResolver_cache(t *testing.T) {
+	alice := "0xc0ffee254729296a45a3885639ac7e10f9d54979"
+	bob := "0x999999cf1046e68e36e1aa2e0e07105eddd1f08e"
+	node := &fakeENS{resolver: "0x4976fb03c32e5b8cfe2b6ccb31c09ba78ebaba41", addresses: map[string]string{"alice.eth": alice}}
+	srv := node.serve(t)
+	defer srv.Close()
+	now := time.Now()
+	r := NewResolver(rpc.NewClient(&http.Client{}, srv.URL), slog.New(slog.NewTextHandler(os.Stdout, nil)),
+		WithTTL(time.Minute),
+		WithNotFoundTTL(time.Second),
+		WithCacheSize(2),
+	)
+	r.now = func() time.Time { return now }
+
+	// a name that was not found is not looked up again until the not found TTL expires.
+	if _, err := r.Resolve(context.Background(), "bob.eth"); !errors.Is(err, ErrNameNotFound) {
+		t.Fatalf("Resolver.Resolve() error = %v, want %v", err, ErrNameNotFound)
+	}
+	node.set("bob.eth", bob)
+	calls := node.calls.Load()
+	if _, err := r.Resolve(context.Background(), "bob.eth"); !errors.Is(err, ErrNameNotFound) || node.calls.Load() != calls {
+		t.Errorf("Resolver.Resolve() error = %v after %d calls, want cached %v", err, node.calls.Load()-calls, ErrNameNotFound)
+	}
+	now = now.Add(2 * time.Second)
+	if got, err := r.Resolve(context.Background(), "bob.eth"); err != nil || got != bob {
+		t.Errorf("Resolver.Resolve() = %v, %v, want %v once expired", got, err, bob)
+	}
+
+	// alice.eth is the least recently used name once carol.eth is resolved, it is evicted.
+	r.Resolve(context.Background(), "alice.eth")
+	r.Resolve(context.Background(), "bob.eth")
+	r.Resolve(context.Background(), "carol.eth")
+	calls = node.calls.Load()
+	r.Resolve(context.Background(), "bob.eth")
+	if node.calls.Load() != calls {
+		t.Errorf("Resolver.Resolve() = %d calls, want bob.eth cached", node.calls.Load()-calls)
+	}
+	r.Resolve(context.Background(), "alice.eth")
+	if node.calls.Load() == calls {
+		t.Errorf("Resolver.Resolve() = no call, want alice.eth evicted")
+	}
+	if len(r.cache) != 2 || r.recent.Len() != 2 {
+		t.Errorf("Resolver.Resolve() cached %d names, want %d", len(r.cache), 2)
+	}
+
+	// expired names are removed when read.
+	now = now.Add(2 * time.Minute)
+	node.set("alice.eth", "")
+	r.Resolve(context.Background(), "alice.eth")
+	if _, ok := r.cache["alice.eth"]; !ok {
+		t.Errorf("Resolver.Resolve() alice.eth not cached as not found")
+	}
+	if _, ok := r.cached("bob.eth"); ok || len(r.cache) != 1 {
+		t.Errorf("Resolver.cached() = %d names, want the expired bob.eth removed", len(r.cache))
+	}
+}
@@ -0,0 +1,155 @@
+// Package export writes transactions in the chain agnostic model as CSV, NDJSON or Parquet, one
+// transaction at a time so that an export is streamed rather than built in memory.
+package export
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+
+	"github.com/pmes126/tx-parser-service/pkg/parser"
+)
+
+const (
+	FormatCSV     = "csv"
+	FormatNDJSON  = "ndjson"
+	FormatParquet = "parquet"
+
+	// SideInput is a row of a value sent by an address, SideOutput of a value received.
+	SideInput  = "input"
+	SideOutput = "output"
+)
+
+// ErrUnknownFormat is returned for a format that is not one of the export formats.
+var ErrUnknownFormat = errors.New("unknown export format")
+
+// Columns are the columns of the CSV and Parquet exports, a row per input and output of a
+// transaction so that the many inputs and outputs of a Bitcoin transaction fit the same columns as
+// the sender and recipient of an Ethereum one.
+var Columns = []string{"hash", "kind", "block_number", "block_hash", "timestamp", "fee", "side", "address", "value"}
+
+// Writer writes the transactions of an export.
+type Writer interface {
+	// Write writes a transaction.
+	Write(tx parser.Transaction) error
+	// Close writes what is left of the export, e.g. the footer of a Parquet file, without closing
+	// the underlying writer.
+	Close() error
+}
+
+// NewWriter returns a Writer of the export format writing to w.
+func NewWriter(w io.Writer, format string) (Writer, error) {
+	switch format {
+	case FormatCSV:
+		return &csvWriter{w: csv.NewWriter(w)}, nil
+	case FormatNDJSON:
+		return &ndjsonWriter{enc: json.NewEncoder(w)}, nil
+	case FormatParquet:
+		return newParquetWriter(w), nil
+	}
+	return nil, fmt.Errorf("%w: %q", ErrUnknownFormat, format)
+}
+
+// ContentType returns the media type of an export format.
+func ContentType(format string) string {
+	switch format {
+	case FormatCSV:
+		return "text/csv"
+	case FormatNDJSON:
+		return "application/x-ndjson"
+	case FormatParquet:
+		return "application/vnd.apache.parquet"
+	}
+	return "application/octet-stream"
+}
+
+// row is a row of the CSV and Parquet exports, in the order of Columns.
+type row struct {
+	hash        string
+	kind        string
+	blockNumber int64
+	blockHash   string
+	timestamp   int64
+	fee         string
+	side        string
+	address     string
+	value       string
+}
+
+// rows returns the rows of a transaction, its inputs then its outputs.
+func rows(tx parser.Transaction) []row {
+	res := make([]row, 0, len(tx.Inputs)+len(tx.Outputs))
+	for _, side := range []struct {
+		name      string
+		transfers []parser.Transfer
+	}{{SideInput, tx.Inputs}, {SideOutput, tx.Outputs}} {
+		for _, t := range side.transfers {
+			res = append(res, row{
+				hash:        tx.Hash,
+				kind:        tx.Kind,
+				blockNumber: tx.BlockNumber,
+				blockHash:   tx.BlockHash,
+				timestamp:   tx.Timestamp,
+				fee:         tx.Fee,
+				side:        side.name,
+				address:     t.Address,
+				value:       t.Value,
+			})
+		}
+	}
+	return res
+}
+
+// csvWriter writes a header line then a line per row.
+type csvWriter struct {
+	w      *csv.Writer
+	header bool
+}
+
+func (cw *csvWriter) writeHeader() error {
+	if cw.header {
+		return nil
+	}
+	cw.header = true
+	return cw.w.Write(Columns)
+}
+
+func (cw *csvWriter) Write(tx parser.Transaction) error {
+	if err := cw.writeHeader(); err != nil {
+		return err
+	}
+	for _, r := range rows(tx) {
+		record := []string{
+			r.hash, r.kind, strconv.FormatInt(r.blockNumber, 10), r.blockHash, strconv.FormatInt(r.timestamp, 10),
+			r.fee, r.side, r.address, r.value,
+		}
+		if err := cw.w.Write(record); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (cw *csvWriter) Close() error {
+	if err := cw.writeHeader(); err != nil {
+		return err
+	}
+	cw.w.Flush()
+	return cw.w.Error()
+}
+
+// ndjsonWriter writes a transaction per line, in the JSON of the chain agnostic model.
+type ndjsonWriter struct {
+	enc *json.Encoder
+}
+
+func (nw *ndjsonWriter) Write(tx parser.Transaction) error {
+	return nw.enc.Encode(tx)
+}
+
+func (nw *ndjsonWriter) Close() error {
+	return nil
+}
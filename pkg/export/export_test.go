@@ -0,0 +1,131 @@
+package export
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/parquet-go/parquet-go"
+	"github.com/pmes126/tx-parser-service/pkg/parser"
+)
+
+func TestNewWriter(t *testing.T) {
+	txs := []parser.Transaction{
+		{
+			Kind: parser.TxKindExternal, Hash: "0x1", BlockHash: "0xb1", BlockNumber: 1, Timestamp: 1700000000, Fee: "10",
+			Inputs:  []parser.Transfer{{Address: "0xa", Value: "100"}},
+			Outputs: []parser.Transfer{{Address: "0xb", Value: "100"}},
+		},
+		{
+			Kind: parser.TxKindExternal, Hash: "tx2", BlockHash: "b2", BlockNumber: 2,
+			Inputs:  []parser.Transfer{{Address: "bc1a", Value: "300"}},
+			Outputs: []parser.Transfer{{Address: "bc1b", Value: "200"}, {Address: "bc1c", Value: "90"}},
+		},
+	}
+	tests := []struct {
+		name   string
+		format string
+		want   string
+	}{
+		{
+			name: "Test CSV", format: FormatCSV,
+			want: "hash,kind,block_number,block_hash,timestamp,fee,side,address,value\n" +
+				"0x1,external,1,0xb1,1700000000,10,input,0xa,100\n" +
+				"0x1,external,1,0xb1,1700000000,10,output,0xb,100\n" +
+				"tx2,external,2,b2,0,,input,bc1a,300\n" +
+				"tx2,external,2,b2,0,,output,bc1b,200\n" +
+				"tx2,external,2,b2,0,,output,bc1c,90\n",
+		},
+		{
+			name: "Test NDJSON", format: FormatNDJSON,
+			want: `{"kind":"external","hash":"0x1","blockHash":"0xb1","blockNumber":1,"inputs":[{"address":"0xa","value":"100"}],"outputs":[{"address":"0xb","value":"100"}],"fee":"10","timestamp":1700000000}` + "\n" +
+				`{"kind":"external","hash":"tx2","blockHash":"b2","blockNumber":2,"inputs":[{"address":"bc1a","value":"300"}],"outputs":[{"address":"bc1b","value":"200"},{"address":"bc1c","value":"90"}]}` + "\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			w, err := NewWriter(&buf, tt.format)
+			if err != nil {
+				t.Fatalf("NewWriter() error = %v", err)
+			}
+			for _, tx := range txs {
+				if err := w.Write(tx); err != nil {
+					t.Fatalf("Writer.Write() error = %v", err)
+				}
+			}
+			if err := w.Close(); err != nil {
+				t.Fatalf("Writer.Close() error = %v", err)
+			}
+			if got := buf.String(); got != tt.want {
+				t.Errorf("Writer.Write() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+	if _, err := NewWriter(&bytes.Buffer{}, "xlsx"); !errors.Is(err, ErrUnknownFormat) {
+		t.Errorf("NewWriter() error = %v, want %v", err, ErrUnknownFormat)
+	}
+}
+
+func TestParquetWriter(t *testing.T) {
+	tx := parser.Transaction{
+		Kind: parser.TxKindExternal, Hash: "0x1", BlockHash: "0xb1", BlockNumber: 1, Timestamp: 1700000000, Fee: "10",
+		Inputs:  []parser.Transfer{{Address: "0xa", Value: "100"}},
+		Outputs: []parser.Transfer{{Address: "0xb", Value: "100"}},
+	}
+	tests := []struct {
+		name       string
+		txs        int
+		wantGroups int
+	}{
+		{name: "Test empty", txs: 0, wantGroups: 0},
+		{name: "Test single row group", txs: 10, wantGroups: 1},
+		// two rows per transaction.
+		{name: "Test row groups", txs: ParquetRowGroupSize + 1, wantGroups: 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			pw := newParquetWriter(&buf)
+			for i := 0; i < tt.txs; i++ {
+				if err := pw.Write(tx); err != nil {
+					t.Fatalf("parquetWriter.Write() error = %v", err)
+				}
+			}
+			if err := pw.Close(); err != nil {
+				t.Fatalf("parquetWriter.Close() error = %v", err)
+			}
+			f, err := parquet.OpenFile(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+			if err != nil {
+				t.Fatalf("parquet.OpenFile() error = %v", err)
+			}
+			if got := len(f.RowGroups()); got != tt.wantGroups {
+				t.Errorf("parquetWriter.Close() = %d row groups, want %d", got, tt.wantGroups)
+			}
+			var columns []string
+			for _, field := range f.Schema().Fields() {
+				columns = append(columns, field.Name())
+			}
+			if !reflect.DeepEqual(columns, Columns) {
+				t.Errorf("parquetWriter.Close() columns = %v, want %v", columns, Columns)
+			}
+			got, err := parquet.Read[parquetRow](bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+			if err != nil {
+				t.Fatalf("parquet.Read() error = %v", err)
+			}
+			if len(got) != 2*tt.txs {
+				t.Fatalf("parquet.Read() = %d rows, want %d", len(got), 2*tt.txs)
+			}
+			want := []parquetRow{
+				{Hash: "0x1", Kind: parser.TxKindExternal, BlockNumber: 1, BlockHash: "0xb1", Timestamp: 1700000000, Fee: "10", Side: SideInput, Address: "0xa", Value: "100"},
+				{Hash: "0x1", Kind: parser.TxKindExternal, BlockNumber: 1, BlockHash: "0xb1", Timestamp: 1700000000, Fee: "10", Side: SideOutput, Address: "0xb", Value: "100"},
+			}
+			for i, r := range got {
+				if r != want[i%2] {
+					t.Fatalf("parquet.Read()[%d] = %+v, want %+v", i, r, want[i%2])
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,63 @@
+package export
+
+import (
+	"io"
+
+	"github.com/parquet-go/parquet-go"
+	"github.com/pmes126/tx-parser-service/pkg/parser"
+)
+
+// ParquetRowGroupSize is the number of rows of a Parquet row group, the rows of a group being
+// buffered before it is written.
+const ParquetRowGroupSize = 10000
+
+// parquetRow is a row of the Parquet export, its columns being the ones of Columns.
+type parquetRow struct {
+	Hash        string `parquet:"hash"`
+	Kind        string `parquet:"kind"`
+	BlockNumber int64  `parquet:"block_number"`
+	BlockHash   string `parquet:"block_hash"`
+	Timestamp   int64  `parquet:"timestamp"`
+	Fee         string `parquet:"fee"`
+	Side        string `parquet:"side"`
+	Address     string `parquet:"address"`
+	Value       string `parquet:"value"`
+}
+
+// parquetWriter writes the rows of the transactions as a Parquet file, compressed with snappy, a
+// row group at a time.
+type parquetWriter struct {
+	w *parquet.GenericWriter[parquetRow]
+}
+
+func newParquetWriter(w io.Writer) *parquetWriter {
+	return &parquetWriter{w: parquet.NewGenericWriter[parquetRow](w,
+		parquet.MaxRowsPerRowGroup(ParquetRowGroupSize),
+		parquet.Compression(&parquet.Snappy),
+		parquet.CreatedBy("tx-parser-service", "", ""),
+	)}
+}
+
+func (pw *parquetWriter) Write(tx parser.Transaction) error {
+	rs := rows(tx)
+	res := make([]parquetRow, len(rs))
+	for i, r := range rs {
+		res[i] = parquetRow{
+			Hash:        r.hash,
+			Kind:        r.kind,
+			BlockNumber: r.blockNumber,
+			BlockHash:   r.blockHash,
+			Timestamp:   r.timestamp,
+			Fee:         r.fee,
+			Side:        r.side,
+			Address:     r.address,
+			Value:       r.value,
+		}
+	}
+	_, err := pw.w.Write(res)
+	return err
+}
+
+func (pw *parquetWriter) Close() error {
+	return pw.w.Close()
+}
@@ -0,0 +1,114 @@
+// Package labels keeps the labels, tags and groups of addresses, e.g. the customer owning an
+// address or the hot and cold wallets of an exchange.
+package labels
+
+import (
+	"slices"
+	"sort"
+	"sync"
+)
+
+// Entry is what is known about an address. Group gathers the addresses queried together, Tags are
+// free form.
+type Entry struct {
+	Address string   `json:"address" mapstructure:"address"`
+	Label   string   `json:"label,omitempty" mapstructure:"label"`
+	Tags    []string `json:"tags,omitempty" mapstructure:"tags"`
+	Group   string   `json:"group,omitempty" mapstructure:"group"`
+}
+
+// Registry is an in-memory registry of the entries of addresses, keyed by their canonical form.
+type Registry struct {
+	entries map[string]Entry
+	mx      sync.RWMutex
+}
+
+// NewRegistry creates a new Registry with the given entries.
+func NewRegistry(entries ...Entry) *Registry {
+	r := &Registry{
+		entries: make(map[string]Entry),
+	}
+	for _, e := range entries {
+		r.Set(e)
+	}
+	return r
+}
+
+// Set merges an entry into the one of its address: the label and group are replaced when set and
+// the tags are added.
+func (r *Registry) Set(e Entry) {
+	r.mx.Lock()
+	defer r.mx.Unlock()
+	cur := r.entries[e.Address]
+	cur.Address = e.Address
+	if e.Label != "" {
+		cur.Label = e.Label
+	}
+	if e.Group != "" {
+		cur.Group = e.Group
+	}
+	tags := slices.Clone(cur.Tags)
+	for _, tag := range e.Tags {
+		if tag != "" && !slices.Contains(tags, tag) {
+			tags = append(tags, tag)
+		}
+	}
+	cur.Tags = tags
+	r.entries[e.Address] = cur
+}
+
+// Move merges the entry of an address into the one of another address, e.g. the address a name
+// now points to, and removes it.
+func (r *Registry) Move(from, to string) {
+	e, ok := r.Get(from)
+	if !ok || from == to {
+		return
+	}
+	r.mx.Lock()
+	delete(r.entries, from)
+	r.mx.Unlock()
+	e.Address = to
+	r.Set(e)
+}
+
+// Get returns the entry of an address.
+func (r *Registry) Get(address string) (Entry, bool) {
+	r.mx.RLock()
+	defer r.mx.RUnlock()
+	e, ok := r.entries[address]
+	return e, ok
+}
+
+// Label returns the label of an address, empty when it has none.
+func (r *Registry) Label(address string) string {
+	e, _ := r.Get(address)
+	return e.Label
+}
+
+// Group returns the addresses of a group, sorted.
+func (r *Registry) Group(group string) []string {
+	var res []string
+	for _, e := range r.List(group, "") {
+		res = append(res, e.Address)
+	}
+	return res
+}
+
+// List returns the entries of a group with a tag sorted by address, all the groups or tags when
+// empty.
+func (r *Registry) List(group, tag string) []Entry {
+	r.mx.RLock()
+	defer r.mx.RUnlock()
+	res := []Entry{}
+	for _, e := range r.entries {
+		if group != "" && e.Group != group {
+			continue
+		}
+		if tag != "" && !slices.Contains(e.Tags, tag) {
+			continue
+		}
+		res = append(res, e)
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].Address < res[j].Address })
+	return res
+}
@@ -0,0 +1,90 @@
+package labels
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestRegistry_Set(t *testing.T) {
+	r := NewRegistry(
+		Entry{Address: "0x1", Label: "Customer 1", Tags: []string{"kyc"}, Group: "customers"},
+		Entry{Address: "0x2", Label: "Hot wallet 1", Group: "hot-wallets"},
+		Entry{Address: "0x3", Group: "hot-wallets"},
+	)
+	// the label and group are kept when not set, the tags are added.
+	r.Set(Entry{Address: "0x1", Tags: []string{"kyc", "vip"}})
+	tests := []struct {
+		name  string
+		group string
+		tag   string
+		want  []Entry
+	}{
+		{
+			name: "Test merged entry", tag: "vip",
+			want: []Entry{{Address: "0x1", Label: "Customer 1", Tags: []string{"kyc", "vip"}, Group: "customers"}},
+		},
+		{
+			name: "Test group", group: "hot-wallets",
+			want: []Entry{{Address: "0x2", Label: "Hot wallet 1", Group: "hot-wallets"}, {Address: "0x3", Group: "hot-wallets"}},
+		},
+		{name: "Test unknown group", group: "cold-wallets", want: []Entry{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := r.List(tt.group, tt.tag)
+			if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Errorf("Registry.List() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+	if got := r.Group("hot-wallets"); fmt.Sprint(got) != "[0x2 0x3]" {
+		t.Errorf("Registry.Group() = %v, want [0x2 0x3]", got)
+	}
+	if got := r.Label("0x4"); got != "" {
+		t.Errorf("Registry.Label() = %v, want none", got)
+	}
+}
+
+func TestRegistry_Move(t *testing.T) {
+	tests := []struct {
+		name    string
+		entries []Entry
+		from    string
+		to      string
+		want    []Entry
+	}{
+		{
+			name:    "Test moved entry",
+			entries: []Entry{{Address: "0x1", Label: "alice.eth", Tags: []string{"kyc"}}},
+			from:    "0x1", to: "0x2",
+			want: []Entry{{Address: "0x2", Label: "alice.eth", Tags: []string{"kyc"}}},
+		},
+		{
+			name:    "Test merged entry",
+			entries: []Entry{{Address: "0x1", Label: "alice.eth"}, {Address: "0x2", Tags: []string{"vip"}, Group: "customers"}},
+			from:    "0x1", to: "0x2",
+			want: []Entry{{Address: "0x2", Label: "alice.eth", Tags: []string{"vip"}, Group: "customers"}},
+		},
+		{
+			name:    "Test unknown address",
+			entries: []Entry{{Address: "0x2", Label: "bob.eth"}},
+			from:    "0x1", to: "0x2",
+			want: []Entry{{Address: "0x2", Label: "bob.eth"}},
+		},
+		{
+			name:    "Test same address",
+			entries: []Entry{{Address: "0x1", Label: "alice.eth"}},
+			from:    "0x1", to: "0x1",
+			want: []Entry{{Address: "0x1", Label: "alice.eth"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := NewRegistry(tt.entries...)
+			r.Move(tt.from, tt.to)
+			if got := r.List("", ""); fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Errorf("Registry.Move() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
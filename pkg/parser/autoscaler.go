@@ -0,0 +1,55 @@
+package parser
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	DefaultAutoscaleInterval = 5 * time.Second
+
+	// blockDurationWeight is the weight of the latest block in the moving average of block durations.
+	blockDurationWeight = 0.2
+)
+
+// autoscaler sizes the worker pool from the lag between the chain head and the cursor. It grows
+// the pool up to one worker per lagging block when catching up and shrinks it one worker at a
+// time when idle, within [min, max] and within the number of workers the RPC budget can sustain.
+type autoscaler struct {
+	min       int
+	max       int
+	rpcBudget float64
+	avgBlock  time.Duration
+	mx        sync.Mutex
+}
+
+// observe records the time it took to process a block.
+func (a *autoscaler) observe(d time.Duration) {
+	a.mx.Lock()
+	defer a.mx.Unlock()
+	if a.avgBlock == 0 {
+		a.avgBlock = d
+		return
+	}
+	a.avgBlock = time.Duration(blockDurationWeight*float64(d) + (1-blockDurationWeight)*float64(a.avgBlock))
+}
+
+// desired returns the number of workers for the current number of workers, the lag in blocks,
+// the number of queued blocks and the RPC requests made per block.
+func (a *autoscaler) desired(current int, lag int64, queued int, callsPerBlock int) int {
+	n := current
+	if lag > int64(current) {
+		n = int(min(lag, int64(a.max)))
+	} else if queued == 0 && lag < int64(current) {
+		n = current - 1
+	}
+
+	// Each worker makes callsPerBlock requests every avgBlock, the budget caps the request rate.
+	a.mx.Lock()
+	avgBlock := a.avgBlock
+	a.mx.Unlock()
+	if a.rpcBudget > 0 && avgBlock > 0 && callsPerBlock > 0 {
+		n = min(n, int(a.rpcBudget*avgBlock.Seconds()/float64(callsPerBlock)))
+	}
+	return max(a.min, min(n, a.max))
+}
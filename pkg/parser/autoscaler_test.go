@@ -0,0 +1,46 @@
+package parser
+
+import (
+	"testing"
+	"time"
+)
+
+func TestAutoscaler_Desired(t *testing.T) {
+	tests := []struct {
+		name          string
+		minWorkers    int
+		rpcBudget     float64
+		avgBlock      time.Duration
+		current       int
+		lag           int64
+		queued        int
+		callsPerBlock int
+		want          int
+	}{
+		{name: "Test steady state", minWorkers: 1, current: 1, lag: 1, want: 1},
+		{name: "Test catch up grows to lag", minWorkers: 1, current: 1, lag: 5, queued: 4, want: 5},
+		{name: "Test catch up bounded by max", minWorkers: 2, current: 2, lag: 500, queued: 10, want: 8},
+		{name: "Test busy keeps workers", minWorkers: 2, current: 4, lag: 3, queued: 1, want: 4},
+		{name: "Test idle shrinks by one", minWorkers: 2, current: 4, lag: 1, want: 3},
+		{name: "Test idle bounded by min", minWorkers: 2, current: 2, lag: 0, want: 2},
+		{name: "Test rpc budget caps workers", minWorkers: 2, rpcBudget: 10, avgBlock: time.Second, current: 2, lag: 500, queued: 10, callsPerBlock: 2, want: 5},
+		{name: "Test rpc budget below min", minWorkers: 2, rpcBudget: 1, avgBlock: time.Second, current: 2, lag: 500, queued: 10, callsPerBlock: 2, want: 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := &autoscaler{min: tt.minWorkers, max: 8, rpcBudget: tt.rpcBudget, avgBlock: tt.avgBlock}
+			if got := a.desired(tt.current, tt.lag, tt.queued, tt.callsPerBlock); got != tt.want {
+				t.Errorf("autoscaler.desired() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAutoscaler_Observe(t *testing.T) {
+	a := &autoscaler{}
+	a.observe(time.Second)
+	a.observe(2 * time.Second)
+	if want := 1200 * time.Millisecond; a.avgBlock != want {
+		t.Errorf("autoscaler.observe() avgBlock = %v, want %v", a.avgBlock, want)
+	}
+}
@@ -0,0 +1,360 @@
+package parser
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/big"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/pmes126/tx-parser-service/internal/rpc"
+	"github.com/pmes126/tx-parser-service/internal/store"
+)
+
+const (
+	GetBalance            = "eth_getBalance"
+	GetTransactionReceipt = "eth_getTransactionReceipt"
+
+	// BalanceSourceInitial is the balance of an address read from the node when it is subscribed.
+	BalanceSourceInitial = "initial"
+	// BalanceSourceBlock is a balance change computed from the transactions of a block.
+	BalanceSourceBlock = "block"
+	// BalanceSourceVerification is a correction of the computed balance to the one of the node,
+	// e.g. for the block rewards and withdrawals which are not transactions.
+	BalanceSourceVerification = "verification"
+
+	DefaultBalanceVerifyInterval = 10 * time.Minute
+	// balanceSyncAttempts is the number of times the balances are read again from the node when a
+	// block is committed while they are read.
+	balanceSyncAttempts = 3
+)
+
+// ErrBalanceNotTracked is returned for the balance of an address before it is read from the node,
+// or when balances are not tracked.
+var ErrBalanceNotTracked = errors.New("balance not tracked")
+
+// Receipt is the part of a transaction receipt used to compute the fees of a transaction.
+type Receipt struct {
+	TransactionHash   string `json:"transactionHash"`
+	Status            string `json:"status"`
+	GasUsed           string `json:"gasUsed"`
+	EffectiveGasPrice string `json:"effectiveGasPrice"`
+	// BlobGasUsed and BlobGasPrice are set for the EIP-4844 transactions carrying blobs.
+	BlobGasUsed  string `json:"blobGasUsed,omitempty"`
+	BlobGasPrice string `json:"blobGasPrice,omitempty"`
+	// L1Fee is the fee of the OP stack rollups for posting the transaction to L1. The Arbitrum L1
+	// fee is already part of GasUsed.
+	L1Fee string `json:"l1Fee,omitempty"`
+}
+
+// Fee returns the fees paid by the sender of a transaction: its execution gas, at gasPrice when
+// the receipt has no effective gas price, its blob gas and its L1 fee.
+func (r Receipt) Fee(gasPrice string) *big.Int {
+	if r.EffectiveGasPrice != "" {
+		gasPrice = r.EffectiveGasPrice
+	}
+	gasUsed, _ := parseBig(r.GasUsed)
+	price, _ := parseBig(gasPrice)
+	fee := new(big.Int).Mul(gasUsed, price)
+	blobGasUsed, _ := parseBig(r.BlobGasUsed)
+	blobGasPrice, _ := parseBig(r.BlobGasPrice)
+	fee.Add(fee, blobGasUsed.Mul(blobGasUsed, blobGasPrice))
+	l1Fee, _ := parseBig(r.L1Fee)
+	return fee.Add(fee, l1Fee)
+}
+
+// BalanceChange is the native balance of an address after a block, in wei.
+type BalanceChange struct {
+	BlockNumber int64  `json:"blockNumber"`
+	Balance     string `json:"balance"`
+	Delta       string `json:"delta"`
+	Source      string `json:"source"`
+}
+
+// Balance is the native balance of an address, in wei, as of BlockNumber, with its history.
+type Balance struct {
+	Address     string          `json:"address"`
+	Balance     string          `json:"balance"`
+	BlockNumber int64           `json:"blockNumber"`
+	History     []BalanceChange `json:"history"`
+}
+
+// WithBalances tracks the native balance of the subscribed addresses, keeping their history in
+// history. The balances are read from the node on subscription, updated with the values and fees
+// of the transactions of every block, and verified against the node every verifyInterval. A nil
+// history does not track balances.
+func WithBalances(history store.TxStore[BalanceChange], verifyInterval time.Duration) Option {
+	return func(ep *EthTxParser) {
+		ep.balanceStore = history
+		ep.balanceVerifyInterval = verifyInterval
+	}
+}
+
+// initBalances reads the balances of newly subscribed addresses from the node.
+func (ep *EthTxParser) initBalances(addrs []string) {
+	ctx, cancel := context.WithTimeout(context.Background(), balanceSyncAttempts*ep.rpcTimeout)
+	defer cancel()
+	if err := ep.syncBalances(ctx, addrs, BalanceSourceInitial); err != nil {
+		ep.logger.Error("Error getting balances", slog.Any("addresses", addrs), slog.String("error", err.Error()))
+	}
+}
+
+// setBalanceBlock sets the block the balances are read from the node at, the one before the first
+// block processed. The balances of the addresses subscribed before it is known are read then.
+func (ep *EthTxParser) setBalanceBlock(block int64) {
+	ep.balanceMx.Lock()
+	start := ep.balanceBlock == 0
+	ep.balanceBlock = block
+	ep.balanceMx.Unlock()
+	if !start || ep.balanceStore == nil {
+		return
+	}
+	ep.mx.RLock()
+	addrs := make([]string, 0, len(ep.addresses))
+	for addr := range ep.addresses {
+		addrs = append(addrs, addr)
+	}
+	ep.mx.RUnlock()
+	if len(addrs) > 0 {
+		go ep.initBalances(addrs)
+	}
+}
+
+// verifyBalances sets the balances to the ones of the node every verification interval until ctx
+// is done.
+func (ep *EthTxParser) verifyBalances(ctx context.Context) {
+	ticker := time.NewTicker(ep.balanceVerifyInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			ep.balanceMx.Lock()
+			addrs := make([]string, 0, len(ep.balances))
+			for addr := range ep.balances {
+				addrs = append(addrs, addr)
+			}
+			ep.balanceMx.Unlock()
+			if len(addrs) == 0 {
+				continue
+			}
+			if err := ep.syncBalances(ctx, addrs, BalanceSourceVerification); err != nil {
+				ep.logger.Error("Error verifying balances", slog.String("error", err.Error()))
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// syncBalances sets the balances of addresses to the ones of the node as of the last block applied
+// to the balances, reading them again if a block is applied meanwhile. The initial balances are
+// only set for the addresses without one, the verified ones only for the addresses with one.
+func (ep *EthTxParser) syncBalances(ctx context.Context, addrs []string, source string) error {
+	for attempt := 0; attempt < balanceSyncAttempts; attempt++ {
+		ep.balanceMx.Lock()
+		block := ep.balanceBlock
+		ep.balanceMx.Unlock()
+		// the balances are read by setBalanceBlock once the block the parser starts from is known,
+		// the latest block could be one still to be applied.
+		if block == 0 {
+			return nil
+		}
+		tag := fmt.Sprintf("0x%x", block)
+		results := make([]string, len(addrs))
+		calls := make([]rpc.BatchElem, len(addrs))
+		for i, addr := range addrs {
+			calls[i] = rpc.BatchElem{Method: GetBalance, Params: []interface{}{addr, tag}, Result: &results[i]}
+		}
+		if err := ep.rpc.BatchCall(ctx, calls); err != nil {
+			return err
+		}
+		ep.balanceMx.Lock()
+		if ep.balanceBlock != block {
+			ep.balanceMx.Unlock()
+			continue
+		}
+		var errs []error
+		for i, addr := range addrs {
+			if calls[i].Err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", addr, calls[i].Err))
+				continue
+			}
+			balance, ok := parseBig(results[i])
+			if !ok {
+				errs = append(errs, fmt.Errorf("%s: invalid balance %q", addr, results[i]))
+				continue
+			}
+			prev, tracked := ep.balances[addr]
+			if tracked == (source == BalanceSourceInitial) {
+				continue
+			}
+			delta := new(big.Int).Set(balance)
+			if tracked {
+				if prev.Cmp(balance) == 0 {
+					continue
+				}
+				delta.Sub(balance, prev)
+				ep.logger.Warn("Computed balance differs from the node", slog.String("address", addr), slog.Int64("block id", block),
+					slog.String("computed", prev.String()), slog.String("node", balance.String()))
+			}
+			ep.setBalance(addr, balance, delta, block, source)
+		}
+		ep.balanceMx.Unlock()
+		return errors.Join(errs...)
+	}
+	return fmt.Errorf("balances not synced after %d attempts, blocks committed meanwhile", balanceSyncAttempts)
+}
+
+// fetchReceipts queries the receipts of the transactions of the tracked addresses in a block not
+// fetched yet, their fees and status being needed to update the balances and statistics.
+func (ep *EthTxParser) fetchReceipts(ctx context.Context, data *blockData) error {
+	var calls []rpc.BatchElem
+	ep.mx.RLock()
+	for _, tx := range data.transactions {
+		if _, ok := data.receipts[tx.Hash]; ok {
+			continue
+		}
+		if ep.addresses[strings.ToLower(tx.From)] || ep.addresses[strings.ToLower(tx.To)] {
+			calls = append(calls, rpc.BatchElem{Method: GetTransactionReceipt, Params: []interface{}{tx.Hash}, Result: &Receipt{}})
+		}
+	}
+	ep.mx.RUnlock()
+	if len(calls) == 0 {
+		return nil
+	}
+	if err := ep.rpc.BatchCall(ctx, calls); err != nil {
+		return err
+	}
+	if data.receipts == nil {
+		data.receipts = make(map[string]Receipt, len(calls))
+	}
+	for _, call := range calls {
+		if call.Err != nil {
+			return fmt.Errorf("receipt of %v: %w", call.Params[0], call.Err)
+		}
+		data.receipts[call.Params[0].(string)] = *call.Result.(*Receipt)
+	}
+	return nil
+}
+
+// updateBalances applies the transactions of a committed block to the balances: the value of the
+// successful transactions moves from their sender to their recipient and the sender pays the fees.
+// A block already applied is ignored. The receipts of the addresses subscribed while the block
+// waited to be committed were not fetched, their balances are read from the node instead.
+func (ep *EthTxParser) updateBalances(data *blockData) {
+	ep.balanceMx.Lock()
+	defer ep.balanceMx.Unlock()
+	if data.number <= ep.balanceBlock {
+		return
+	}
+	ep.balanceBlock = data.number
+	if len(ep.balances) == 0 {
+		return
+	}
+	deltas := make(map[string]*big.Int)
+	add := func(addr string, v *big.Int, sign int) {
+		addr = strings.ToLower(addr)
+		if _, ok := ep.balances[addr]; !ok || v.Sign() == 0 {
+			return
+		}
+		if deltas[addr] == nil {
+			deltas[addr] = new(big.Int)
+		}
+		if sign < 0 {
+			deltas[addr].Sub(deltas[addr], v)
+		} else {
+			deltas[addr].Add(deltas[addr], v)
+		}
+	}
+	var unknown []string
+	for _, tx := range data.transactions {
+		receipt, ok := data.receipts[tx.Hash]
+		if !ok {
+			for _, addr := range []string{strings.ToLower(tx.From), strings.ToLower(tx.To)} {
+				if _, tracked := ep.balances[addr]; tracked && !slices.Contains(unknown, addr) {
+					unknown = append(unknown, addr)
+				}
+			}
+			continue
+		}
+		add(tx.From, receipt.Fee(tx.GasPrice), -1)
+		if receipt.Status == "0x0" {
+			continue
+		}
+		value, _ := parseBig(tx.Value)
+		add(tx.From, value, -1)
+		add(tx.To, value, 1)
+	}
+	for _, tx := range data.internal {
+		value, _ := parseBig(tx.Value)
+		add(tx.From, value, -1)
+		add(tx.To, value, 1)
+	}
+	for addr, delta := range deltas {
+		if delta.Sign() == 0 {
+			continue
+		}
+		ep.setBalance(addr, new(big.Int).Add(ep.balances[addr], delta), delta, data.number, BalanceSourceBlock)
+	}
+	if len(unknown) > 0 {
+		go func() {
+			ctx, cancel := context.WithTimeout(context.Background(), ep.rpcTimeout)
+			defer cancel()
+			if err := ep.syncBalances(ctx, unknown, BalanceSourceVerification); err != nil {
+				ep.logger.Error("Error verifying balances", slog.Any("addresses", unknown), slog.String("error", err.Error()))
+			}
+		}()
+	}
+}
+
+// setBalance sets the balance of an address and records the change in its history, balanceMx
+// being held.
+func (ep *EthTxParser) setBalance(addr string, balance, delta *big.Int, block int64, source string) {
+	ep.balances[addr] = balance
+	change := BalanceChange{BlockNumber: block, Balance: balance.String(), Delta: delta.String(), Source: source}
+	if err := ep.balanceStore.AddTransaction(addr, change); err != nil {
+		ep.logger.Error("Error storing balance change", slog.String("address", addr), slog.String("error", err.Error()))
+	}
+}
+
+// GetBalance returns the native balance of an address with its history.
+func (ep *EthTxParser) GetBalance(address string) (Balance, error) {
+	addr, ok := ep.tracked(address)
+	if !ok {
+		return Balance{}, ErrAddressNotTracked
+	}
+	if ep.balanceStore == nil {
+		return Balance{}, ErrBalanceNotTracked
+	}
+	ep.balanceMx.Lock()
+	balance, ok := ep.balances[addr]
+	block := ep.balanceBlock
+	ep.balanceMx.Unlock()
+	if !ok {
+		return Balance{}, ErrBalanceNotTracked
+	}
+	history, err := ep.balanceStore.GetTransactions(addr)
+	if err != nil && !errors.Is(err, store.ErrNoTransactions) {
+		return Balance{}, err
+	}
+	if history == nil {
+		history = []BalanceChange{}
+	}
+	return Balance{Address: addr, Balance: balance.String(), BlockNumber: block, History: history}, nil
+}
+
+// parseBig parses a 0x prefixed hex quantity, 0 when empty.
+func parseBig(hex string) (*big.Int, bool) {
+	h := strings.TrimPrefix(hex, "0x")
+	if h == "" {
+		return new(big.Int), true
+	}
+	v, ok := new(big.Int).SetString(h, 16)
+	if !ok {
+		return new(big.Int), false
+	}
+	return v, true
+}
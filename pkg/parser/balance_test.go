@@ -0,0 +1,227 @@
+package parser
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pmes126/tx-parser-service/internal/rpc"
+	"github.com/pmes126/tx-parser-service/internal/store"
+)
+
+// fakeBalanceNode answers eth_getBalance with the balances of a map and
+// eth_getTransactionReceipt with the receipts of another.
+func fakeBalanceNode(t *testing.T, mx *sync.Mutex, balances map[string]string, receipts map[string]Receipt) *httptest.Server {
+	handle := func(req rpc.Request) map[string]interface{} {
+		mx.Lock()
+		defer mx.Unlock()
+		resp := map[string]interface{}{"jsonrpc": rpc.Version, "id": req.Id}
+		switch req.Method {
+		case GetBalance:
+			if req.Params[1] == CurrentBlockParam {
+				t.Errorf("%v called at the %v block", req.Method, CurrentBlockParam)
+			}
+			resp["result"] = balances[req.Params[0].(string)]
+		case GetTransactionReceipt:
+			resp["result"] = receipts[req.Params[0].(string)]
+		default:
+			t.Errorf("%v called", req.Method)
+		}
+		return resp
+	}
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if bytes.HasPrefix(body, []byte("[")) {
+			var reqs []rpc.Request
+			json.Unmarshal(body, &reqs)
+			var resps []map[string]interface{}
+			for _, req := range reqs {
+				resps = append(resps, handle(req))
+			}
+			json.NewEncoder(w).Encode(resps)
+			return
+		}
+		var req rpc.Request
+		json.Unmarshal(body, &req)
+		json.NewEncoder(w).Encode(handle(req))
+	}))
+}
+
+func TestEthTxParser_updateBalances(t *testing.T) {
+	alice := "0xc0ffee254729296a45a3885639ac7e10f9d54979"
+	bob := "0x999999cf1046e68e36e1aa2e0e07105eddd1f08e"
+	var mx sync.Mutex
+	balances := map[string]string{alice: "0x3e8"} // 1000
+	receipts := map[string]Receipt{
+		"0x1": {Status: "0x1", GasUsed: "0x2", EffectiveGasPrice: "0x5"},
+		"0x2": {Status: "0x0", GasUsed: "0x3", EffectiveGasPrice: "0x5"},
+		"0x3": {Status: "0x1", GasUsed: "0x1", EffectiveGasPrice: "0x1"},
+	}
+	srv := fakeBalanceNode(t, &mx, balances, receipts)
+	defer srv.Close()
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+	etp := NewEthTxParser(store.NewMemTxStore[EthTransaction](), &http.Client{}, logger, 0,
+		WithEndpoints(srv.URL),
+		WithBalances(store.NewMemTxStore[BalanceChange](), 0),
+	)
+	etp.setBalanceBlock(9)
+	if _, err := etp.GetBalance(alice); !errors.Is(err, ErrAddressNotTracked) {
+		t.Errorf("EthTxParser.GetBalance() error = %v, want %v", err, ErrAddressNotTracked)
+	}
+	etp.Subscribe(alice)
+	waitBalance(t, etp, alice)
+
+	data := &blockData{number: 10, transactions: []EthTransaction{
+		// alice sends 100 and pays 10 of fees.
+		{Hash: "0x1", From: alice, To: bob, Value: "0x64"},
+		// a failed transaction only costs its fees of 15.
+		{Hash: "0x2", From: alice, To: bob, Value: "0x64"},
+		// bob sends 50, his fees are not alice's.
+		{Hash: "0x3", From: bob, To: alice, Value: "0x32"},
+		{Hash: "0x4", From: bob, To: "0x456", Value: "0x32"},
+	}, internal: []EthTransaction{
+		{Kind: TxKindInternal, Hash: "0x4", From: "0x456", To: alice, Value: "0x5"},
+	}}
+	if err := etp.fetchReceipts(context.Background(), data); err != nil {
+		t.Fatalf("EthTxParser.fetchReceipts() error = %v", err)
+	}
+	if len(data.receipts) != 3 {
+		t.Errorf("EthTxParser.fetchReceipts() = %d receipts, want %d", len(data.receipts), 3)
+	}
+	etp.commitBlock(data)
+	// 1000 - 100 - 10 - 15 + 50 + 5
+	balance, err := etp.GetBalance(alice)
+	if err != nil || balance.Balance != "930" || balance.BlockNumber != 10 {
+		t.Fatalf("EthTxParser.GetBalance() = %+v, %v, want 930 at block 10", balance, err)
+	}
+
+	// the node has a reward the transactions do not account for.
+	mx.Lock()
+	balances[alice] = "0x3e9" // 1001
+	mx.Unlock()
+	if err := etp.syncBalances(context.Background(), []string{alice}, BalanceSourceVerification); err != nil {
+		t.Fatalf("EthTxParser.syncBalances() error = %v", err)
+	}
+	balance, _ = etp.GetBalance(alice)
+	want := []BalanceChange{
+		{BlockNumber: 9, Balance: "1000", Delta: "1000", Source: BalanceSourceInitial},
+		{BlockNumber: 10, Balance: "930", Delta: "-70", Source: BalanceSourceBlock},
+		{BlockNumber: 10, Balance: "1001", Delta: "71", Source: BalanceSourceVerification},
+	}
+	if balance.Balance != "1001" || len(balance.History) != len(want) {
+		t.Fatalf("EthTxParser.GetBalance() = %+v, want 1001 with %d changes", balance, len(want))
+	}
+	for i, change := range balance.History {
+		if change != want[i] {
+			t.Errorf("EthTxParser.GetBalance() history[%d] = %+v, want %+v", i, change, want[i])
+		}
+	}
+}
+
+// waitBalance waits for the initial balance of an address to be read from the node.
+func waitBalance(t *testing.T, etp *EthTxParser, addr string) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for _, err := etp.GetBalance(addr); err != nil; _, err = etp.GetBalance(addr) {
+		if time.Now().After(deadline) {
+			t.Fatalf("EthTxParser.GetBalance() error = %v, want the initial balance", err)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestEthTxParser_setBalanceBlock(t *testing.T) {
+	alice := "0xc0ffee254729296a45a3885639ac7e10f9d54979"
+	var mx sync.Mutex
+	srv := fakeBalanceNode(t, &mx, map[string]string{alice: "0x3e8"}, nil)
+	defer srv.Close()
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+	etp := NewEthTxParser(store.NewMemTxStore[EthTransaction](), &http.Client{}, logger, 0,
+		WithEndpoints(srv.URL),
+		WithBalances(store.NewMemTxStore[BalanceChange](), 0),
+	)
+	// subscribed before the block the parser starts from is known, the balance is not read from
+	// the latest block.
+	etp.Subscribe(alice)
+	if err := etp.syncBalances(context.Background(), []string{alice}, BalanceSourceInitial); err != nil {
+		t.Fatalf("EthTxParser.syncBalances() error = %v", err)
+	}
+	if _, err := etp.GetBalance(alice); !errors.Is(err, ErrBalanceNotTracked) {
+		t.Errorf("EthTxParser.GetBalance() error = %v, want %v", err, ErrBalanceNotTracked)
+	}
+	etp.setBalanceBlock(9)
+	waitBalance(t, etp, alice)
+	balance, _ := etp.GetBalance(alice)
+	if balance.Balance != "1000" || balance.BlockNumber != 9 {
+		t.Errorf("EthTxParser.GetBalance() = %+v, want 1000 at block 9", balance)
+	}
+}
+
+func TestEthTxParser_commitBlock_receipts(t *testing.T) {
+	alice := "0xc0ffee254729296a45a3885639ac7e10f9d54979"
+	var mx sync.Mutex
+	balances := map[string]string{alice: "0x3e8"} // 1000
+	srv := fakeBalanceNode(t, &mx, balances, nil)
+	defer srv.Close()
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+	etp := NewEthTxParser(store.NewMemTxStore[EthTransaction](), &http.Client{}, logger, 0,
+		WithEndpoints(srv.URL),
+		WithBalances(store.NewMemTxStore[BalanceChange](), 0),
+	)
+	etp.setBalanceBlock(9)
+	data := &blockData{number: 10, transactions: []EthTransaction{{Hash: "0x1", From: alice, To: "0x456", Value: "0x64"}}}
+	if err := etp.fetchReceipts(context.Background(), data); err != nil {
+		t.Fatalf("EthTxParser.fetchReceipts() error = %v", err)
+	}
+	// alice is subscribed after the block is fetched, without the receipt of her transaction her
+	// balance is read from the node once the block is committed.
+	etp.Subscribe(alice)
+	waitBalance(t, etp, alice)
+	mx.Lock()
+	balances[alice] = "0x37a" // 890
+	mx.Unlock()
+	if err := etp.commitBlock(data); err != nil {
+		t.Fatalf("EthTxParser.commitBlock() error = %v", err)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for balance, _ := etp.GetBalance(alice); balance.Balance != "890"; balance, _ = etp.GetBalance(alice) {
+		if time.Now().After(deadline) {
+			t.Fatalf("EthTxParser.GetBalance() = %v, want %v", balance.Balance, "890")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	balance, _ := etp.GetBalance(alice)
+	if last := balance.History[len(balance.History)-1]; last.BlockNumber != 10 || last.Source != BalanceSourceVerification {
+		t.Errorf("EthTxParser.GetBalance() last change = %+v, want verified at block 10", last)
+	}
+}
+
+func TestReceipt_Fee(t *testing.T) {
+	tests := []struct {
+		name     string
+		receipt  Receipt
+		gasPrice string
+		want     string
+	}{
+		{name: "Test effective gas price", receipt: Receipt{GasUsed: "0x2", EffectiveGasPrice: "0x5"}, gasPrice: "0x7", want: "10"},
+		{name: "Test gas price", receipt: Receipt{GasUsed: "0x2"}, gasPrice: "0x7", want: "14"},
+		{name: "Test blob gas", receipt: Receipt{GasUsed: "0x2", EffectiveGasPrice: "0x5", BlobGasUsed: "0x20000", BlobGasPrice: "0x1"}, want: "131082"},
+		{name: "Test L1 fee", receipt: Receipt{GasUsed: "0x2", EffectiveGasPrice: "0x5", L1Fee: "0x64"}, want: "110"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.receipt.Fee(tt.gasPrice).String(); got != tt.want {
+				t.Errorf("Receipt.Fee() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,359 @@
+package parser
+
+import (
+	"context"
+	"log/slog"
+	"runtime"
+	"sync"
+	"time"
+
+	"github.com/pmes126/tx-parser-service/internal/conc"
+	"github.com/pmes126/tx-parser-service/internal/store"
+)
+
+// Transaction is the chain agnostic model of a transaction. Value moves from the addresses of the
+// inputs to the ones of the outputs, e.g. the many inputs and outputs of a Bitcoin transaction or
+// the sender and recipient of an Ethereum one.
+type Transaction struct {
+	Kind        string     `json:"kind"`
+	Hash        string     `json:"hash"`
+	BlockHash   string     `json:"blockHash"`
+	BlockNumber int64      `json:"blockNumber"`
+	Inputs      []Transfer `json:"inputs"`
+	Outputs     []Transfer `json:"outputs"`
+	// Fee paid by the transaction, if known, in the smallest unit of the chain.
+	Fee string `json:"fee,omitempty"`
+	// Timestamp is the unix time of the block of the transaction, if known.
+	Timestamp int64 `json:"timestamp,omitempty"`
+}
+
+// Key identifies a transaction in the stores.
+func (tx Transaction) Key() string {
+	return tx.Kind + "/" + tx.Hash
+}
+
+// Transfer is a value sent by or to an address, a decimal amount in the smallest unit of the
+// chain (wei, satoshi). The address is empty when the chain does not attribute the value to one.
+type Transfer struct {
+	Address string `json:"address"`
+	Value   string `json:"value"`
+}
+
+// Addresses returns the distinct addresses of the inputs and outputs of the transaction.
+func (tx Transaction) Addresses() []string {
+	seen := make(map[string]bool, len(tx.Inputs)+len(tx.Outputs))
+	var addresses []string
+	for _, transfers := range [][]Transfer{tx.Inputs, tx.Outputs} {
+		for _, t := range transfers {
+			if t.Address == "" || seen[t.Address] {
+				continue
+			}
+			seen[t.Address] = true
+			addresses = append(addresses, t.Address)
+		}
+	}
+	return addresses
+}
+
+// ChainAdapter connects a ChainParser to the node of a chain. B is a block as fetched from the
+// node.
+type ChainAdapter[B any] interface {
+	// FetchHead returns the number of the latest block of the chain.
+	FetchHead(ctx context.Context) (int64, error)
+	// FetchBlock returns a block by number, ErrBlockNotFound if the node does not know it yet.
+	FetchBlock(ctx context.Context, number int64) (B, error)
+	// NormaliseTransactions returns the transactions of a block in the chain agnostic model, with
+	// the addresses in their canonical form.
+	NormaliseTransactions(block B) ([]Transaction, error)
+	// ValidateAddress returns the canonical form of an address of the chain, ErrInvalidAddress if
+	// it is not one.
+	ValidateAddress(address string) (string, error)
+}
+
+// chainOptions are the settings of a ChainParser.
+type chainOptions struct {
+	cursorStore   store.CursorStore
+	workerCount   int
+	confirmations int64
+	drainTimeout  time.Duration
+	retryPolicy   conc.RetryPolicy
+	queueSize     int
+	queuePolicy   QueuePolicy
+	stats         *statsCollector
+}
+
+// ChainOption configures optional components of a ChainParser.
+type ChainOption func(*chainOptions)
+
+// WithChainCursorStore sets the store of the last committed block, processing resumes after it on
+// Start.
+func WithChainCursorStore(s store.CursorStore) ChainOption {
+	return func(o *chainOptions) {
+		o.cursorStore = s
+	}
+}
+
+// WithChainWorkers sets the number of blocks processed in parallel, runtime.NumCPU() by default.
+func WithChainWorkers(n int) ChainOption {
+	return func(o *chainOptions) {
+		if n > 0 {
+			o.workerCount = n
+		}
+	}
+}
+
+// WithChainConfirmations only processes the blocks with at least n blocks on top of them.
+func WithChainConfirmations(n int64) ChainOption {
+	return func(o *chainOptions) {
+		if n > 0 {
+			o.confirmations = n
+		}
+	}
+}
+
+// WithChainDrainTimeout sets how long Start keeps processing the queued blocks once its context is
+// done.
+func WithChainDrainTimeout(d time.Duration) ChainOption {
+	return func(o *chainOptions) {
+		if d > 0 {
+			o.drainTimeout = d
+		}
+	}
+}
+
+// WithChainRetry retries a failed block up to maxAttempts times, with an exponential backoff
+// between baseDelay and maxDelay, before requeuing it, or moving it to the dead-letter queue when
+// the error is permanent. Non-positive values keep the defaults, as with WithRetry.
+func WithChainRetry(maxAttempts int, baseDelay, maxDelay time.Duration) ChainOption {
+	return func(o *chainOptions) {
+		if maxAttempts > 0 {
+			o.retryPolicy.MaxAttempts = maxAttempts
+		}
+		if baseDelay > 0 {
+			o.retryPolicy.BaseDelay = baseDelay
+		}
+		if maxDelay > 0 {
+			o.retryPolicy.MaxDelay = maxDelay
+		}
+	}
+}
+
+// WithChainQueue sets the size of the queue of blocks waiting for a worker and the policy applied
+// when it is full.
+func WithChainQueue(size int, policy QueuePolicy) ChainOption {
+	return func(o *chainOptions) {
+		if size > 0 {
+			o.queueSize = size
+		}
+		o.queuePolicy = policy
+	}
+}
+
+// WithChainStats maintains the statistics of the tracked addresses, with volume buckets of the
+// given duration. A bucket of 0 does not maintain statistics.
+func WithChainStats(bucket time.Duration) ChainOption {
+	return func(o *chainOptions) {
+		o.stats = newStatsCollector(bucket)
+	}
+}
+
+// ChainParser is a Parser of any chain with a ChainAdapter. Like the EthTxParser it fetches the
+// blocks with a worker pool, commits them in order with a sequencer and keeps the failed ones in a
+// dead-letter queue.
+type ChainParser[B any] struct {
+	chainOptions
+	adapter              ChainAdapter[B]
+	txStore              store.TxStore[Transaction]
+	addresses            map[string]bool
+	blockPollingInterval time.Duration
+	blocks               *pipeline[[]Transaction]
+	mx                   sync.RWMutex
+	logger               *slog.Logger
+}
+
+// NewChainParser creates a new ChainParser of the chain of adapter
+func NewChainParser[B any](adapter ChainAdapter[B], txStore store.TxStore[Transaction], log *slog.Logger, pollingInterval int, opts ...ChainOption) *ChainParser[B] {
+	cp := &ChainParser[B]{
+		chainOptions: chainOptions{
+			cursorStore:  store.NewMemCursorStore(),
+			workerCount:  runtime.NumCPU(),
+			drainTimeout: DefaultDrainTimeout,
+			retryPolicy: conc.RetryPolicy{
+				MaxAttempts: DefaultRetryMaxAttempts,
+				BaseDelay:   DefaultRetryBaseDelay,
+				MaxDelay:    DefaultRetryMaxDelay,
+				Retryable:   isRetryable,
+			},
+			queueSize: DefaultQueueSize,
+		},
+		adapter:              adapter,
+		txStore:              txStore,
+		addresses:            make(map[string]bool),
+		blockPollingInterval: time.Duration(pollingInterval) * time.Second,
+		logger:               log,
+	}
+	for _, opt := range opts {
+		opt(&cp.chainOptions)
+	}
+	cp.blocks = newPipeline(pipelineConfig{
+		cursorStore:  cp.cursorStore,
+		workers:      cp.workerCount,
+		queueSize:    cp.queueSize,
+		queuePolicy:  cp.queuePolicy,
+		retryPolicy:  cp.retryPolicy,
+		drainTimeout: cp.drainTimeout,
+	}, cp.logger, cp.fetchBlock, cp.commitBlock)
+	cp.blocks.onProcessed = func(res conc.Result[int64, []Transaction]) {
+		cp.logger.Debug("Processed block", slog.Int64("block id", res.Task), slog.Duration("duration", res.Duration), slog.Int("transactions", len(res.Value)))
+	}
+	return cp
+}
+
+// GetCurrentBlock returns the current block number in the blockchain.
+func (cp *ChainParser[B]) GetCurrentBlock(ctx context.Context) (int64, error) {
+	return cp.adapter.FetchHead(ctx)
+}
+
+// Start starts the ChainParser, polling the blockchain for new blocks and updating transactions.
+func (cp *ChainParser[B]) Start(ctx context.Context) {
+	ticker := time.NewTicker(cp.blockPollingInterval)
+	defer ticker.Stop()
+
+	drain := cp.blocks.start(ctx)
+	defer drain()
+
+	for {
+		select {
+		case <-ticker.C:
+			head, err := cp.adapter.FetchHead(ctx)
+			if err != nil {
+				cp.logger.Error("Error getting latest block", slog.String("error", err.Error()))
+				continue
+			}
+			cp.blocks.schedule(ctx, head-cp.confirmations)
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// fetchBlock fetches a block and normalises its transactions.
+func (cp *ChainParser[B]) fetchBlock(ctx context.Context, blockNum int64) ([]Transaction, error) {
+	block, err := cp.adapter.FetchBlock(ctx, blockNum)
+	if err != nil {
+		cp.logger.Error("Error Querying block", slog.Int64("block id", blockNum), slog.String("error", err.Error()))
+		return nil, err
+	}
+	return cp.adapter.NormaliseTransactions(block)
+}
+
+// commitBlock updates the transaction store with the transactions of a block, then the statistics
+// once they are all stored. The store ignores the transactions it already has, so that a block
+// failing to commit is not stored twice when it is retried.
+func (cp *ChainParser[B]) commitBlock(transactions []Transaction) error {
+	cp.mx.RLock()
+	defer cp.mx.RUnlock()
+	for _, tx := range transactions {
+		for _, address := range tx.Addresses() {
+			if cp.addresses[address] {
+				if err := cp.txStore.AddTransaction(address, tx); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	if cp.stats != nil {
+		for _, tx := range transactions {
+			cp.stats.add(tx, cp.addresses)
+		}
+	}
+	return nil
+}
+
+// Cursor returns the last block whose transactions have been committed to the store.
+func (cp *ChainParser[B]) Cursor() (int64, error) {
+	return cp.cursorStore.GetCursor()
+}
+
+// ValidateAddress returns the canonical form of an address of the chain.
+func (cp *ChainParser[B]) ValidateAddress(address string) (string, error) {
+	return cp.adapter.ValidateAddress(address)
+}
+
+// Subscribe adds an address to the list of addresses to track, it fails if the address is not an
+// address of the chain.
+func (cp *ChainParser[B]) Subscribe(address string) bool {
+	addr, err := cp.adapter.ValidateAddress(address)
+	if err != nil {
+		return false
+	}
+	cp.logger.Debug("Subscribing address", slog.String("address", addr))
+	cp.mx.Lock()
+	defer cp.mx.Unlock()
+	cp.addresses[addr] = true
+	return true
+}
+
+// ListTransactions returns the transactions of an address from the transaction store.
+func (cp *ChainParser[B]) ListTransactions(address string) ([]Transaction, error) {
+	addr, err := cp.adapter.ValidateAddress(address)
+	if err != nil {
+		return nil, err
+	}
+	cp.mx.RLock()
+	tracked := cp.addresses[addr]
+	cp.mx.RUnlock()
+	if !tracked {
+		return nil, ErrAddressNotTracked
+	}
+	return cp.txStore.GetTransactions(addr)
+}
+
+// ForEachTransaction calls fn with the transactions of an address, streaming them from the store.
+func (cp *ChainParser[B]) ForEachTransaction(address string, fn func(tx Transaction) error) error {
+	addr, err := cp.adapter.ValidateAddress(address)
+	if err != nil {
+		return err
+	}
+	cp.mx.RLock()
+	tracked := cp.addresses[addr]
+	cp.mx.RUnlock()
+	if !tracked {
+		return ErrAddressNotTracked
+	}
+	return cp.txStore.ForEachTransaction(addr, fn)
+}
+
+// GetStats returns the statistics of an address.
+func (cp *ChainParser[B]) GetStats(address string) (AddressStats, error) {
+	addr, err := cp.adapter.ValidateAddress(address)
+	if err != nil {
+		return AddressStats{}, err
+	}
+	cp.mx.RLock()
+	tracked := cp.addresses[addr]
+	cp.mx.RUnlock()
+	if !tracked {
+		return AddressStats{}, ErrAddressNotTracked
+	}
+	if cp.stats == nil {
+		return AddressStats{}, ErrStatsNotTracked
+	}
+	return cp.stats.get(addr), nil
+}
+
+// FailedBlocks returns the blocks of the dead-letter queue.
+func (cp *ChainParser[B]) FailedBlocks() []FailedBlock {
+	return failedBlocks(cp.blocks.wp.DeadLetters())
+}
+
+// ReplayFailedBlocks pushes the blocks of the dead-letter queue back for processing and returns
+// their number.
+func (cp *ChainParser[B]) ReplayFailedBlocks(ctx context.Context) (int, error) {
+	return cp.blocks.wp.Replay(ctx)
+}
+
+// QueueStats returns the state of the queue of blocks waiting for a worker.
+func (cp *ChainParser[B]) QueueStats() QueueStats {
+	return queueStats(cp.blocks.wp.QueueStats())
+}
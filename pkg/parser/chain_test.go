@@ -0,0 +1,118 @@
+package parser
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/pmes126/tx-parser-service/internal/store"
+)
+
+// fakeAdapter is a chain whose blocks are lists of transactions and whose addresses are upper case
+// words, canonically lower case.
+type fakeAdapter struct {
+	head   int64
+	blocks map[int64][]Transaction
+}
+
+func (a *fakeAdapter) FetchHead(ctx context.Context) (int64, error) {
+	return a.head, nil
+}
+
+func (a *fakeAdapter) FetchBlock(ctx context.Context, number int64) ([]Transaction, error) {
+	block, ok := a.blocks[number]
+	if !ok {
+		return nil, fmt.Errorf("%w: %d", ErrBlockNotFound, number)
+	}
+	return block, nil
+}
+
+func (a *fakeAdapter) NormaliseTransactions(block []Transaction) ([]Transaction, error) {
+	return block, nil
+}
+
+func (a *fakeAdapter) ValidateAddress(address string) (string, error) {
+	if address == "" || strings.ContainsAny(address, "0123456789") {
+		return "", ErrInvalidAddress
+	}
+	return strings.ToLower(address), nil
+}
+
+func TestChainParser_process(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+	adapter := &fakeAdapter{blocks: map[int64][]Transaction{
+		10: {
+			{Hash: "a", Inputs: []Transfer{{Address: "alice", Value: "5"}}, Outputs: []Transfer{{Address: "bob", Value: "3"}, {Address: "alice", Value: "2"}}},
+			{Hash: "b", Inputs: []Transfer{{Address: "carol", Value: "1"}}, Outputs: []Transfer{{Address: "dave", Value: "1"}}},
+		},
+		11: {
+			{Hash: "c", Inputs: []Transfer{{Address: "bob", Value: "3"}}, Outputs: []Transfer{{Address: "carol", Value: "3"}}},
+		},
+	}}
+	cp := NewChainParser(adapter, store.NewMemTxStore[Transaction](), logger, 0)
+	if cp.Subscribe("b0b") {
+		t.Errorf("ChainParser.Subscribe() = true, want false for an invalid address")
+	}
+	for _, address := range []string{"ALICE", "Bob"} {
+		if !cp.Subscribe(address) {
+			t.Fatalf("ChainParser.Subscribe(%q) = false, want true", address)
+		}
+	}
+	if err := cp.blocks.seq.reset(9); err != nil {
+		t.Fatal(err)
+	}
+	// the blocks are committed in order whatever the order they are fetched in.
+	for _, block := range []int64{11, 10} {
+		if _, err := cp.blocks.process(context.Background(), block); err != nil {
+			t.Fatalf("pipeline.process(%d) error = %v", block, err)
+		}
+	}
+	if cursor, _ := cp.Cursor(); cursor != 11 {
+		t.Errorf("ChainParser.Cursor() = %v, want %v", cursor, 11)
+	}
+	tests := []struct {
+		name    string
+		address string
+		want    []string
+		wantErr error
+	}{
+		{name: "Test input and output address stored once", address: "alice", want: []string{"a"}},
+		{name: "Test canonical address", address: "BOB", want: []string{"a", "c"}},
+		{name: "Test untracked address", address: "carol", wantErr: ErrAddressNotTracked},
+		{name: "Test invalid address", address: "b0b", wantErr: ErrInvalidAddress},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			txs, err := cp.ListTransactions(tt.address)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("ChainParser.ListTransactions() error = %v, want %v", err, tt.wantErr)
+			}
+			var hashes []string
+			for _, tx := range txs {
+				hashes = append(hashes, tx.Hash)
+			}
+			if fmt.Sprint(hashes) != fmt.Sprint(tt.want) {
+				t.Errorf("ChainParser.ListTransactions() = %v, want %v", hashes, tt.want)
+			}
+		})
+	}
+}
+
+func TestEthTransaction_Normalise(t *testing.T) {
+	tx := EthTransaction{Kind: TxKindExternal, Hash: "0x1", BlockNumber: "0x10", From: "0xAB", To: "0xCD", Value: "0xde0b6b3a7640000"}
+	got := tx.Normalise()
+	want := Transaction{
+		Kind:        TxKindExternal,
+		Hash:        "0x1",
+		BlockNumber: 16,
+		Inputs:      []Transfer{{Address: "0xab", Value: "1000000000000000000"}},
+		Outputs:     []Transfer{{Address: "0xcd", Value: "1000000000000000000"}},
+	}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("EthTransaction.Normalise() = %+v, want %+v", got, want)
+	}
+}
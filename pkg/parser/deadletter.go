@@ -0,0 +1,59 @@
+package parser
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/pmes126/tx-parser-service/internal/conc"
+	"github.com/pmes126/tx-parser-service/internal/rpc"
+)
+
+// FailedBlock is a block that could not be processed after all of its retries.
+type FailedBlock struct {
+	Block    int64     `json:"block"`
+	Error    string    `json:"error"`
+	Attempts int       `json:"attempts"`
+	FailedAt time.Time `json:"failedAt"`
+}
+
+// FailedBlocks returns the blocks of the dead-letter queue.
+func (ep *EthTxParser) FailedBlocks() []FailedBlock {
+	return failedBlocks(ep.blocks.wp.DeadLetters())
+}
+
+// failedBlocks converts the dead letters of a worker pool of blocks.
+func failedBlocks(deadLetters []conc.DeadLetter[int64]) []FailedBlock {
+	res := make([]FailedBlock, len(deadLetters))
+	for i, dl := range deadLetters {
+		res[i] = FailedBlock{
+			Block:    dl.Task,
+			Error:    dl.Err.Error(),
+			Attempts: dl.Attempts,
+			FailedAt: dl.FailedAt,
+		}
+	}
+	return res
+}
+
+// ReplayFailedBlocks pushes the blocks of the dead-letter queue back for processing and returns
+// their number.
+func (ep *EthTxParser) ReplayFailedBlocks(ctx context.Context) (int, error) {
+	return ep.blocks.wp.Replay(ctx)
+}
+
+// QueueStats returns the state of the queue of blocks waiting for a worker.
+func (ep *EthTxParser) QueueStats() QueueStats {
+	return queueStats(ep.blocks.wp.QueueStats())
+}
+
+// isRetryable classifies the errors of a block worth retrying. Everything but a cancelled
+// context and a request rejected by the node is assumed to be transient, including rate limits,
+// timeouts and blocks not found yet.
+func isRetryable(err error) bool {
+	var rpcErr *rpc.RPCError
+	if errors.As(err, &rpcErr) && rpcErr.Permanent() {
+		return false
+	}
+	return !errors.Is(err, context.Canceled)
+}
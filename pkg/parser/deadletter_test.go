@@ -0,0 +1,31 @@
+package parser
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/pmes126/tx-parser-service/internal/rpc"
+)
+
+func TestIsRetryable(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{name: "Test block not found", err: blockError(1, rpc.ErrNullResult), want: true},
+		{name: "Test rate limited", err: &rpc.RPCError{Code: rpc.CodeLimitExceeded, Message: "limit exceeded"}, want: true},
+		{name: "Test timeout", err: fmt.Errorf("%w: i/o timeout", rpc.ErrTimeout), want: true},
+		{name: "Test method not found", err: &rpc.RPCError{Code: rpc.CodeMethodNotFound, Message: "the method does not exist"}, want: false},
+		{name: "Test invalid params", err: fmt.Errorf("wrapped: %w", &rpc.RPCError{Code: rpc.CodeInvalidParams}), want: false},
+		{name: "Test canceled", err: context.Canceled, want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isRetryable(tt.err); got != tt.want {
+				t.Errorf("isRetryable() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,92 @@
+package parser
+
+import (
+	"log/slog"
+	"strings"
+)
+
+const (
+	// TransferEventTopic is the keccak256 hash of Transfer(address,address,uint256).
+	TransferEventTopic = "0xddf252ad1be2c89b69c2b068fc378daa952ba7f163c4a11628f55a4df523b3ef"
+)
+
+// TokenTransfer represents an ERC-20 token transfer decoded from a Transfer event log.
+type TokenTransfer struct {
+	Token       string `json:"token"`
+	From        string `json:"from"`
+	To          string `json:"to"`
+	Value       string `json:"value"`
+	Hash        string `json:"hash"`
+	BlockNumber string `json:"blockNumber"`
+	LogIndex    string `json:"logIndex"`
+}
+
+// Key identifies a token transfer in the stores by its log.
+func (t TokenTransfer) Key() string {
+	return t.Hash + "/" + t.LogIndex
+}
+
+// transferTopics is the topics filter matching any of the transfer events.
+func transferTopics() []interface{} {
+	topic0 := []string{TransferEventTopic, TransferSingleEventTopic, TransferBatchEventTopic}
+	return []interface{}{topic0}
+}
+
+// UpdateTokenTransfersInStore updates the token transfer store with the ERC-20 transfers
+// involving tracked addresses.
+func (ep *EthTxParser) UpdateTokenTransfersInStore(logs []EthLog) error {
+	ep.mx.RLock()
+	defer ep.mx.RUnlock()
+	for _, l := range logs {
+		tt, ok := decodeTokenTransfer(l)
+		if !ok {
+			continue
+		}
+		if ep.addresses[tt.From] {
+			if err := ep.tokenStore.AddTransaction(tt.From, tt); err != nil {
+				return err
+			}
+		}
+		if ep.addresses[tt.To] && tt.To != tt.From {
+			if err := ep.tokenStore.AddTransaction(tt.To, tt); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// GetTokenTransfers returns a list of ERC-20 token transfers for an address from the token store.
+func (ep *EthTxParser) GetTokenTransfers(address string) ([]TokenTransfer, error) {
+	addr, ok := ep.tracked(address)
+	ep.logger.Debug("Getting token transfers for address", slog.String("address", addr))
+	if !ok {
+		return nil, ErrAddressNotTracked
+	}
+	return ep.tokenStore.GetTransactions(addr)
+}
+
+// decodeTokenTransfer decodes an ERC-20 Transfer log. ERC-721 transfers share the event
+// signature but index the token id as a fourth topic, so they are rejected here.
+func decodeTokenTransfer(l EthLog) (TokenTransfer, bool) {
+	if l.Removed || len(l.Topics) != 3 || !strings.EqualFold(l.Topics[0], TransferEventTopic) {
+		return TokenTransfer{}, false
+	}
+	from, err := topicToAddress(l.Topics[1])
+	if err != nil {
+		return TokenTransfer{}, false
+	}
+	to, err := topicToAddress(l.Topics[2])
+	if err != nil {
+		return TokenTransfer{}, false
+	}
+	return TokenTransfer{
+		Token:       strings.ToLower(l.Address),
+		From:        from,
+		To:          to,
+		Value:       compactHex(l.Data),
+		Hash:        l.TransactionHash,
+		BlockNumber: l.BlockNumber,
+		LogIndex:    l.LogIndex,
+	}, true
+}
@@ -0,0 +1,80 @@
+package parser
+
+import (
+	"log/slog"
+	"net/http"
+	"os"
+	"testing"
+
+	"github.com/pmes126/tx-parser-service/internal/store"
+)
+
+func addressTopic(address string) string {
+	return "0x000000000000000000000000" + address[2:]
+}
+
+func TestEthTxParser_UpdateAndGetTokenTransfers(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+	Addresses := []string{"0x1111111111111111111111111111111111111111", "0x2222222222222222222222222222222222222222", "0x3333333333333333333333333333333333333333"}
+	token := "0xa0b86991c6218b36c1d19d4a2e9eb0ce3606eb48"
+	logs := []EthLog{
+		{
+			Address:         token,
+			Topics:          []string{TransferEventTopic, addressTopic(Addresses[0]), addressTopic(Addresses[1])},
+			Data:            "0x00000000000000000000000000000000000000000000000000000000000f4240",
+			TransactionHash: "0x1",
+		},
+		{
+			Address:         token,
+			Topics:          []string{TransferEventTopic, addressTopic(Addresses[1]), addressTopic(Addresses[2])},
+			Data:            "0x0000000000000000000000000000000000000000000000000000000000000001",
+			TransactionHash: "0x2",
+		},
+		{
+			// ERC-721 transfer, token id is indexed.
+			Address:         token,
+			Topics:          []string{TransferEventTopic, addressTopic(Addresses[0]), addressTopic(Addresses[2]), "0x01"},
+			TransactionHash: "0x3",
+		},
+	}
+	tests := []struct {
+		name    string
+		address string
+		want    int
+		wantErr bool
+	}{
+		{name: "Test sender", address: Addresses[0], want: 1},
+		{name: "Test sender and receiver", address: Addresses[1], want: 2},
+		{name: "Test receiver", address: Addresses[2], want: 1},
+	}
+	etp := NewEthTxParser(store.NewMemTxStore[EthTransaction](), &http.Client{}, logger, 0)
+	for _, address := range Addresses {
+		etp.Subscribe(address)
+	}
+	if err := etp.UpdateTokenTransfersInStore(logs); err != nil {
+		t.Fatalf("EthTxParser.UpdateTokenTransfersInStore() error = %v", err)
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			transfers, err := etp.GetTokenTransfers(tt.address)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("EthTxParser.GetTokenTransfers() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if len(transfers) != tt.want {
+				t.Errorf("EthTxParser.GetTokenTransfers() = %v, want %v", len(transfers), tt.want)
+			}
+			for _, tr := range transfers {
+				if tr.From != tt.address && tr.To != tt.address {
+					t.Errorf("EthTxParser.GetTokenTransfers() transfer %s does not involve %s", tr.Hash, tt.address)
+				}
+				if tr.Token != token {
+					t.Errorf("EthTxParser.GetTokenTransfers() token = %v, want %v", tr.Token, token)
+				}
+			}
+		})
+	}
+	if transfers, _ := etp.GetTokenTransfers(Addresses[0]); len(transfers) == 1 && transfers[0].Value != "0xf4240" {
+		t.Errorf("EthTxParser.GetTokenTransfers() value = %v, want %v", transfers[0].Value, "0xf4240")
+	}
+}
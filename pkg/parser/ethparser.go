@@ -1,75 +1,123 @@
 package parser
 
 import (
-	"bytes"
 	"context"
-	"encoding/json"
+	"errors"
 	"fmt"
-	"io"
 	"log/slog"
+	"math/big"
 	"net/http"
 	"runtime"
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/pmes126/tx-parser-service/internal/conc"
+	"github.com/pmes126/tx-parser-service/internal/rpc"
 	"github.com/pmes126/tx-parser-service/internal/store"
+	"github.com/pmes126/tx-parser-service/pkg/address"
+	"github.com/pmes126/tx-parser-service/pkg/decoder"
 )
 
 const (
 	RpcUrl                  = "https://ethereum-rpc.publicnode.com"
 	GetCurrentBlock         = "eth_blockNumber"
 	GetCurrentBlockByNumber = "eth_getBlockByNumber"
+	GetChainID              = "eth_chainId"
 	CurrentBlockParam       = "latest"
-)
 
-// EthTxParser is a parser for Ethereum transactions.
-type EthTxParser struct {
-	txStore              store.TxStore[EthTransaction]
-	addresses            map[string]bool
-	lastBlock            int64
-	blockPollingInterval time.Duration
-	client               *http.Client
-	mx                   sync.RWMutex
-	logger               *slog.Logger
-}
+	// TxKindExternal is a transaction signed by an externally owned account.
+	TxKindExternal = "external"
+	// TxKindInternal is a value transfer made by a contract call inside a transaction.
+	TxKindInternal = "internal"
 
-// CurrentBlockRequest represents a request to get the current block number.
-type CurrentBlockRequest struct {
-	Jsonrpc string        `json:"jsonrpc"`
-	Method  string        `json:"method"`
-	Params  []interface{} `json:"params"`
-	Id      int           `json:"id"`
-}
+	DefaultRetryMaxAttempts = 3
+	DefaultRetryBaseDelay   = 500 * time.Millisecond
+	DefaultRetryMaxDelay    = 10 * time.Second
+	DefaultQueueSize        = 10
+	DefaultDrainTimeout     = 5 * time.Second
+	DefaultBatchSize        = 20
+	DefaultRPCTimeout       = 10 * time.Second
+	DefaultTraceTimeout     = 60 * time.Second
+
+	// LaneLive is the worker pool lane of the blocks at the head of the chain.
+	LaneLive = "live"
+	// LaneBackfill is the worker pool lane of the blocks behind the head, e.g. after downtime.
+	LaneBackfill = "backfill"
+	// LaneReplay is the worker pool lane of the failed blocks, requeued after their retries or
+	// replayed from the dead-letter queue.
+	LaneReplay = "replay"
+	// LiveWindow is the number of blocks up to the head pushed into the live lane, the older ones
+	// are backfilled.
+	LiveWindow = 4
+	// MaxBlocksAhead is the number of blocks scheduled past the last committed block, bounding the
+	// fetched blocks waiting for an earlier block to be committed.
+	MaxBlocksAhead = 1024
+)
 
-// BlockByNumberRequest represents a request to get a block by number.
-type BlockByNumberRequest struct {
-	Jsonrpc string        `json:"jsonrpc"`
-	Method  string        `json:"method"`
-	Params  []interface{} `json:"params"`
-	Id      int           `json:"id"`
+// lanes are the worker pool lanes, the live blocks are served ahead of the historical ones.
+var lanes = []conc.Lane{
+	{Name: LaneLive, Weight: 8},
+	{Name: LaneBackfill, Weight: 2},
+	{Name: LaneReplay, Weight: 1},
 }
 
-// EthBlockNumberResponse represents the response from an Ethereum block number request.
-type EthBlockNumberResponse struct {
-	Id      int    `json:"id"`
-	Jsonrpc string `json:"jsonrpc"`
-	Result  string `json:"result"`
+// EthTxParser is a parser for Ethereum transactions.
+type EthTxParser struct {
+	txStore               store.TxStore[EthTransaction]
+	tokenStore            store.TxStore[TokenTransfer]
+	nftStore              store.TxStore[NFTTransfer]
+	logStore              store.TxStore[EthLog]
+	logSubscriptions      map[string][]LogSubscription
+	traceMode             string
+	decoder               *decoder.Decoder
+	addresses             map[string]bool
+	strictAddresses       bool
+	blockPollingInterval  time.Duration
+	endpoints             []string
+	wsURL                 string
+	confirmations         int64
+	pendingMode           string
+	pendingInterval       time.Duration
+	pendingTimeout        time.Duration
+	pending               map[string]*PendingTransaction
+	pendingMx             sync.Mutex
+	balanceStore          store.TxStore[BalanceChange]
+	balanceVerifyInterval time.Duration
+	balances              map[string]*big.Int
+	balanceBlock          int64
+	balanceMx             sync.Mutex
+	stats                 *statsCollector
+	lastHeadAt            atomic.Int64
+	rpc                   *rpc.Client
+	batchSize             int
+	batchWait             time.Duration
+	rpcTimeout            time.Duration
+	rpcTimeouts           map[string]time.Duration
+	blocks                *pipeline[*blockData]
+	workerCount           int
+	scaler                *autoscaler
+	head                  atomic.Int64
+	drainTimeout          time.Duration
+	cursorStore           store.CursorStore
+	retryPolicy           conc.RetryPolicy
+	queueSize             int
+	queuePolicy           QueuePolicy
+	mx                    sync.RWMutex
+	logger                *slog.Logger
 }
 
-// EthBlockByNumberResponse represents the response from an Ethereum block request.
-type EthBlockByNumberResponse struct {
-	Id      int    `json:"id"`
-	Jsonrpc string `json:"jsonrpc"`
-	Result  struct {
-		Transactions []EthTransaction `json:"transactions"` // Hashes or objects?
-	} `json:"result"`
+// EthBlock represents the result of an Ethereum block request.
+type EthBlock struct {
+	Timestamp    string           `json:"timestamp"`
+	Transactions []EthTransaction `json:"transactions"`
 }
 
 // EthTransaction represents an Ethereum transaction.
 type EthTransaction struct {
+	Kind        string `json:"kind"`
 	Address     string `json:"address"`
 	Hash        string `json:"hash"`
 	Nonce       string `json:"nonce"`
@@ -81,136 +129,487 @@ type EthTransaction struct {
 	Input       string `json:"input"`
 	Gas         string `json:"gas"`
 	GasPrice    string `json:"gasPrice"`
+	// Timestamp is the timestamp of the block of the transaction, set when the block is fetched.
+	Timestamp string `json:"timestamp,omitempty"`
+	// TraceAddress is the position of an internal transaction in the call tree.
+	TraceAddress []int `json:"traceAddress,omitempty"`
+	// DecodedInput is the Input decoded with the known contract ABIs, if any.
+	DecodedInput *decoder.DecodedInput `json:"decodedInput,omitempty"`
+}
+
+// Key identifies a transaction in the stores, the internal transactions of a transaction by their
+// position in its call tree.
+func (tx EthTransaction) Key() string {
+	return tx.Kind + "/" + tx.Hash + "/" + traceAddressKey(tx.TraceAddress)
+}
+
+// Option configures optional components of an EthTxParser.
+type Option func(*EthTxParser)
+
+// WithTokenStore sets the store used for ERC-20 token transfers.
+func WithTokenStore(s store.TxStore[TokenTransfer]) Option {
+	return func(ep *EthTxParser) {
+		ep.tokenStore = s
+	}
+}
+
+// WithNFTStore sets the store used for ERC-721 and ERC-1155 transfers.
+func WithNFTStore(s store.TxStore[NFTTransfer]) Option {
+	return func(ep *EthTxParser) {
+		ep.nftStore = s
+	}
+}
+
+// WithLogStore sets the store used for the event logs of subscribed contracts.
+func WithLogStore(s store.TxStore[EthLog]) Option {
+	return func(ep *EthTxParser) {
+		ep.logStore = s
+	}
+}
+
+// WithTraceMode enables tracking of internal transactions by tracing every block with
+// either TraceModeDebug or TraceModeTrace. An empty mode disables tracing.
+func WithTraceMode(mode string) Option {
+	return func(ep *EthTxParser) {
+		ep.traceMode = mode
+	}
+}
+
+// WithDecoder annotates the stored transactions with their calldata decoded by d.
+func WithDecoder(d *decoder.Decoder) Option {
+	return func(ep *EthTxParser) {
+		ep.decoder = d
+	}
+}
+
+// WithCursorStore sets the store of the last committed block, processing resumes after it on Start.
+func WithCursorStore(s store.CursorStore) Option {
+	return func(ep *EthTxParser) {
+		ep.cursorStore = s
+	}
+}
+
+// WithRetry retries a failed block up to maxAttempts times, with an exponential backoff between
+// baseDelay and maxDelay, before requeuing it, or moving it to the dead-letter queue when the
+// error is permanent. Non-positive values keep the
+// defaults, a maxAttempts of 1 disables the retries.
+func WithRetry(maxAttempts int, baseDelay, maxDelay time.Duration) Option {
+	return func(ep *EthTxParser) {
+		if maxAttempts > 0 {
+			ep.retryPolicy.MaxAttempts = maxAttempts
+		}
+		if baseDelay > 0 {
+			ep.retryPolicy.BaseDelay = baseDelay
+		}
+		if maxDelay > 0 {
+			ep.retryPolicy.MaxDelay = maxDelay
+		}
+	}
+}
+
+// WithWorkers sets the number of blocks processed in parallel, runtime.NumCPU() by default.
+func WithWorkers(n int) Option {
+	return func(ep *EthTxParser) {
+		if n > 0 {
+			ep.workerCount = n
+		}
+	}
+}
+
+// WithAutoscale resizes the worker pool at runtime between minWorkers and maxWorkers depending on
+// the lag behind the chain head, without exceeding rpcBudget requests per second (0 for unlimited).
+func WithAutoscale(minWorkers, maxWorkers int, rpcBudget float64) Option {
+	return func(ep *EthTxParser) {
+		if minWorkers < 1 || maxWorkers < minWorkers {
+			return
+		}
+		ep.scaler = &autoscaler{min: minWorkers, max: maxWorkers, rpcBudget: rpcBudget}
+	}
+}
+
+// WithDrainTimeout sets how long Start keeps processing the queued blocks once its context is done.
+func WithDrainTimeout(d time.Duration) Option {
+	return func(ep *EthTxParser) {
+		if d > 0 {
+			ep.drainTimeout = d
+		}
+	}
+}
+
+// WithQueue sets the size of the queue of blocks waiting for a worker and the policy applied when
+// it is full.
+func WithQueue(size int, policy QueuePolicy) Option {
+	return func(ep *EthTxParser) {
+		if size > 0 {
+			ep.queueSize = size
+		}
+		ep.queuePolicy = policy
+	}
+}
+
+// WithBatch sets the maximum number of JSON-RPC calls sent in a single batch request and how long
+// to wait for the calls of concurrent workers to coalesce them into the same batch.
+func WithBatch(size int, wait time.Duration) Option {
+	return func(ep *EthTxParser) {
+		if size > 0 {
+			ep.batchSize = size
+		}
+		ep.batchWait = wait
+	}
+}
+
+// WithRPCTimeouts sets the timeout of the JSON-RPC requests and overrides it for the given
+// methods, e.g. for the slower trace calls. The method names are case insensitive.
+func WithRPCTimeouts(timeout time.Duration, methods map[string]time.Duration) Option {
+	return func(ep *EthTxParser) {
+		if timeout > 0 {
+			ep.rpcTimeout = timeout
+		}
+		for method, d := range methods {
+			if d > 0 {
+				ep.rpcTimeouts[strings.ToLower(method)] = d
+			}
+		}
+	}
+}
+
+// WithEndpoints sets the JSON-RPC endpoints of the node, the first one being used until it fails
+// and the others in turn after it.
+func WithEndpoints(urls ...string) Option {
+	return func(ep *EthTxParser) {
+		if len(urls) > 0 {
+			ep.endpoints = urls
+		}
+	}
+}
+
+// WithConfirmations only processes the blocks with at least n blocks on top of them, so that
+// shallow reorgs do not leave the transactions of orphaned blocks in the stores.
+func WithConfirmations(n int64) Option {
+	return func(ep *EthTxParser) {
+		if n > 0 {
+			ep.confirmations = n
+		}
+	}
+}
+
+// WithStrictAddresses only accepts the addresses with an EIP-55 checksum.
+func WithStrictAddresses(strict bool) Option {
+	return func(ep *EthTxParser) {
+		ep.strictAddresses = strict
+	}
+}
+
+// WithWebSocket subscribes to the new heads of the chain on the WebSocket RPC endpoint url to
+// process blocks as soon as they are produced, polling only while the subscription is down.
+func WithWebSocket(url string) Option {
+	return func(ep *EthTxParser) {
+		ep.wsURL = url
+	}
 }
 
 // NewEthTxParser creates a new EthTxParser
-func NewEthTxParser(store store.TxStore[EthTransaction], client *http.Client, log *slog.Logger, pollingInterval int) *EthTxParser {
-	return &EthTxParser{
+func NewEthTxParser(txStore store.TxStore[EthTransaction], client *http.Client, log *slog.Logger, pollingInterval int, opts ...Option) *EthTxParser {
+	ep := &EthTxParser{
 		addresses:            make(map[string]bool),
-		client:               client,
-		txStore:              store,
+		txStore:              txStore,
+		tokenStore:           store.NewMemTxStore[TokenTransfer](),
+		nftStore:             store.NewMemTxStore[NFTTransfer](),
+		logStore:             store.NewMemTxStore[EthLog](),
+		logSubscriptions:     make(map[string][]LogSubscription),
+		cursorStore:          store.NewMemCursorStore(),
 		logger:               log,
-		lastBlock:            0,
 		blockPollingInterval: time.Duration(pollingInterval) * time.Second,
+		endpoints:            []string{RpcUrl},
+		pending:              make(map[string]*PendingTransaction),
+		pendingInterval:      DefaultPendingInterval,
+		pendingTimeout:       DefaultPendingTimeout,
+		balances:             make(map[string]*big.Int),
+		queueSize:            DefaultQueueSize,
+		batchSize:            DefaultBatchSize,
+		rpcTimeout:           DefaultRPCTimeout,
+		rpcTimeouts: map[string]time.Duration{
+			strings.ToLower(DebugTraceBlockByNumber): DefaultTraceTimeout,
+			strings.ToLower(TraceBlock):              DefaultTraceTimeout,
+		},
+		workerCount:  runtime.NumCPU(),
+		drainTimeout: DefaultDrainTimeout,
+		retryPolicy: conc.RetryPolicy{
+			MaxAttempts: DefaultRetryMaxAttempts,
+			BaseDelay:   DefaultRetryBaseDelay,
+			MaxDelay:    DefaultRetryMaxDelay,
+			Retryable:   isRetryable,
+		},
+	}
+	for _, opt := range opts {
+		opt(ep)
+	}
+	if ep.scaler != nil {
+		ep.workerCount = max(ep.scaler.min, min(ep.workerCount, ep.scaler.max))
+	}
+	ep.rpc = rpc.NewClient(client, ep.endpoints[0],
+		rpc.WithFallbacks(ep.endpoints[1:]...),
+		rpc.WithBatchSize(ep.batchSize),
+		rpc.WithBatchWait(ep.batchWait),
+		rpc.WithTimeouts(ep.rpcTimeout, ep.rpcTimeouts),
+	)
+	ep.blocks = newPipeline(pipelineConfig{
+		cursorStore:  ep.cursorStore,
+		workers:      ep.workerCount,
+		queueSize:    ep.queueSize,
+		queuePolicy:  ep.queuePolicy,
+		retryPolicy:  ep.retryPolicy,
+		drainTimeout: ep.drainTimeout,
+	}, ep.logger, ep.fetchBlock, ep.commitBlock)
+	ep.blocks.onStart = ep.setBalanceBlock
+	ep.blocks.onProcessed = func(res conc.Result[int64, *blockData]) {
+		if ep.scaler != nil {
+			ep.scaler.observe(res.Duration)
+		}
+		ep.logger.Debug("Processed block", slog.Int64("block id", res.Task), slog.Duration("duration", res.Duration), slog.Int("transactions", len(res.Value.transactions)))
 	}
+	return ep
 }
 
 // GetCurrentBlock returns the current block number in the blockchain.
-func (ep *EthTxParser) GetCurrentBlock() (int64, error) {
-	req := CurrentBlockRequest{
-		Jsonrpc: "2.0",
-		Method:  GetCurrentBlock,
-		Params:  []interface{}{},
-		Id:      1,
-	}
-	reqBody, err := json.Marshal(req)
-	if err != nil {
-		return 0, err
-	}
-	resp, err := ep.client.Post(RpcUrl, "application/json", bytes.NewBuffer(reqBody))
-	if err != nil {
+func (ep *EthTxParser) GetCurrentBlock(ctx context.Context) (int64, error) {
+	var result string
+	if err := ep.rpc.Call(ctx, &result, GetCurrentBlock); err != nil {
 		return 0, err
 	}
-	defer resp.Body.Close()
 
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return 0, err
-	}
-	var ethResp EthBlockNumberResponse
-	err = json.Unmarshal(body, &ethResp)
+	blockNumber, err := ParseHex(result)
 	if err != nil {
 		return 0, err
 	}
 
-	blockNumber, err := ParseHex(ethResp.Result)
-	if err != nil {
+	return blockNumber, nil
+}
+
+// ChainID returns the chain id of the node, to check it is the one of the configured chain.
+func (ep *EthTxParser) ChainID(ctx context.Context) (int64, error) {
+	var result string
+	if err := ep.rpc.Call(ctx, &result, GetChainID); err != nil {
 		return 0, err
 	}
-
-	return blockNumber, nil
+	return ParseHex(result)
 }
 
-// Start starts the EthTxParser, polling the blockchain for new blocks and updating transactions.
+// Start starts the EthTxParser, polling the blockchain or watching its new heads for new blocks
+// and updating transactions.
 func (ep *EthTxParser) Start(ctx context.Context) {
 	ticker := time.NewTicker(ep.blockPollingInterval)
 	defer ticker.Stop()
 
-	// job to query transactions in a block then update the store with the tranasctions for the tracked addresses.
-	job := func(ctx context.Context, blockNum int64) error {
-		transactions, err := ep.QueryTransactionsFromBlock(blockNum)
-		if err != nil {
-			ep.logger.Error("Error Querying Transactions for block", slog.Int64("block id", blockNum), slog.String("error", err.Error()))
-			return err
-		}
-		if err = ep.UpdateTransactionsInStore(transactions); err != nil {
-			ep.logger.Error("Error Updating Transactions from block", slog.Int64("block id", blockNum), slog.String("error", err.Error()))
-			return err
-		}
-		return nil
+	drain := ep.blocks.start(ctx)
+	defer drain()
+
+	var autoscale <-chan time.Time
+	if ep.scaler != nil {
+		autoscaleTicker := time.NewTicker(DefaultAutoscaleInterval)
+		defer autoscaleTicker.Stop()
+		autoscale = autoscaleTicker.C
 	}
 
-	wp := conc.NewWorkerPool(runtime.NumCPU(), job, 10)
-	defer wp.CloseInputChannel()
-	resChan := wp.Start(ctx)
+	heads := make(chan int64, 1)
+	if ep.wsURL != "" {
+		go ep.watchHeads(ctx, heads)
+	}
+	if ep.pendingMode != "" {
+		go ep.watchPending(ctx)
+	}
+	if ep.balanceStore != nil && ep.balanceVerifyInterval > 0 {
+		go ep.verifyBalances(ctx)
+	}
 
 	for {
 		select {
+		case head := <-heads:
+			ep.onHead(ctx, head)
 		case <-ticker.C:
-			latestBlock, err := ep.GetCurrentBlock()
+			if ep.subscribed() {
+				continue
+			}
+			latestBlock, err := ep.GetCurrentBlock(ctx)
 			if err != nil {
 				ep.logger.Error("Error getting latest block", slog.String("error", err.Error()))
 				continue
 			}
-			if latestBlock > ep.lastBlock {
-				// process the latest block on startup.
-				if ep.lastBlock == 0 {
-					ep.lastBlock = latestBlock - 1
-				}
-				for i := latestBlock; i > ep.lastBlock; i-- {
-					wp.PushTask(i)
-				}
-				ep.lastBlock = latestBlock
-			}
+			ep.onHead(ctx, latestBlock)
+		case <-autoscale:
+			ep.autoscale()
 		case <-ctx.Done():
 			return
-		case err := <-resChan:
-			if err != nil {
-				ep.logger.Error("Error processing block transactions", slog.String("error", err.Error()))
-			}
 		}
 	}
 }
 
-// QueryTransactionsFromBlock queries the blockchain for transactions in a given block.
-func (ep *EthTxParser) QueryTransactionsFromBlock(blockNum int64) ([]EthTransaction, error) {
-	req := BlockByNumberRequest{
-		Jsonrpc: "2.0",
-		Method:  GetCurrentBlockByNumber,
-		Params:  []interface{}{fmt.Sprintf("0x%x", blockNum), true}, // `true` includes transactions
-		Id:      1,
-	}
-	reqBody, err := json.Marshal(req)
+// onHead schedules the blocks up to a new head of the chain, less the confirmations.
+func (ep *EthTxParser) onHead(ctx context.Context, head int64) {
+	ready := head - ep.confirmations
+	ep.head.Store(ready)
+	ep.blocks.schedule(ctx, ready)
+}
+
+// autoscale resizes the worker pool from the lag between the chain head and the cursor.
+func (ep *EthTxParser) autoscale() {
+	cursor, err := ep.cursorStore.GetCursor()
 	if err != nil {
-		return nil, err
+		ep.logger.Error("Error getting cursor", slog.String("error", err.Error()))
+		return
 	}
-	resp, err := ep.client.Post(RpcUrl, "application/json", bytes.NewBuffer(reqBody))
-	if err != nil {
-		return nil, err
+	lag := max(ep.head.Load()-cursor, 0)
+	current := ep.blocks.wp.Workers()
+	desired := ep.scaler.desired(current, lag, ep.blocks.wp.QueueStats().Depth, ep.callsPerBlock())
+	if desired != current {
+		ep.logger.Info("Resizing worker pool", slog.Int("workers", desired), slog.Int("previous", current), slog.Int64("lag", lag))
+		ep.blocks.wp.Resize(desired)
 	}
-	defer resp.Body.Close()
+}
 
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
+// callsPerBlock returns the number of RPC requests made to process a block.
+func (ep *EthTxParser) callsPerBlock() int {
+	calls := 2 // block and transfer logs
+	if ep.traceMode != "" {
+		calls++
+	}
+	if len(ep.subscribedContracts()) > 0 {
+		calls++
+	}
+	return calls
+}
+
+// blockData holds everything fetched from the node for a block before it is written to the stores.
+type blockData struct {
+	number       int64
+	timestamp    int64
+	transactions []EthTransaction
+	internal     []EthTransaction
+	logs         []EthLog
+	contractLogs []EthLog
+	// receipts are the receipts of the transactions of the tracked addresses, by hash, fetched
+	// when balances or statistics are tracked.
+	receipts map[string]Receipt
+}
+
+// Cursor returns the last block whose transactions have been committed to the stores, all the
+// blocks before it being committed too.
+func (ep *EthTxParser) Cursor() (int64, error) {
+	return ep.cursorStore.GetCursor()
+}
+
+// fetchBlock queries the blockchain for the transactions and logs of a block, sending all the
+// calls of the block in a single batch request.
+func (ep *EthTxParser) fetchBlock(ctx context.Context, blockNum int64) (*blockData, error) {
+	data := &blockData{number: blockNum}
+	var block EthBlock
+	calls := []rpc.BatchElem{
+		blockCall(blockNum, &block),
+		logsCall(blockNum, LogsFilter{Topics: transferTopics()}, &data.logs),
+	}
+	if contracts := ep.subscribedContracts(); len(contracts) > 0 {
+		calls = append(calls, logsCall(blockNum, LogsFilter{Address: contracts}, &data.contractLogs))
+	}
+	var internal func() []EthTransaction
+	if ep.traceMode != "" {
+		call, result, err := ep.traceCall(blockNum)
+		if err != nil {
+			return nil, err
+		}
+		calls, internal = append(calls, call), result
+	}
+	if err := ep.rpc.BatchCall(ctx, calls); err != nil {
+		ep.logger.Error("Error Querying block", slog.Int64("block id", blockNum), slog.String("error", err.Error()))
 		return nil, err
 	}
-	var ethResp EthBlockByNumberResponse
-	err = json.Unmarshal(body, &ethResp)
+	for _, call := range calls {
+		if call.Err != nil {
+			ep.logger.Error("Error Querying block", slog.Int64("block id", blockNum), slog.String("method", call.Method), slog.String("error", call.Err.Error()))
+			if call.Method == GetCurrentBlockByNumber {
+				return nil, blockError(blockNum, call.Err)
+			}
+			return nil, call.Err
+		}
+	}
+	data.transactions = block.Transactions
+	data.timestamp, _ = ParseHex(block.Timestamp)
+	if internal != nil {
+		data.internal = internal()
+	}
+	for _, txs := range [][]EthTransaction{data.transactions, data.internal} {
+		for i := range txs {
+			txs[i].Timestamp = block.Timestamp
+		}
+	}
+	if ep.balanceStore != nil || ep.stats != nil {
+		if err := ep.fetchReceipts(ctx, data); err != nil {
+			ep.logger.Error("Error Querying receipts", slog.Int64("block id", blockNum), slog.String("error", err.Error()))
+			return nil, err
+		}
+	}
+	return data, nil
+}
+
+// commitBlock updates the stores with the fetched data of a block. The stores ignore the records
+// they already have, and the pending transactions, balances and statistics are only updated once
+// the stores are, so that a block failing to commit is not applied twice when it is retried. It is
+// called with the lock of the sequencer held, so it makes no calls to the node.
+func (ep *EthTxParser) commitBlock(data *blockData) error {
+	if err := ep.UpdateTransactionsInStore(data.transactions); err != nil {
+		ep.logger.Error("Error Updating Transactions from block", slog.Int64("block id", data.number), slog.String("error", err.Error()))
+		return err
+	}
+	if err := ep.UpdateTransactionsInStore(data.internal); err != nil {
+		ep.logger.Error("Error Updating Internal Transactions from block", slog.Int64("block id", data.number), slog.String("error", err.Error()))
+		return err
+	}
+	if err := ep.UpdateTokenTransfersInStore(data.logs); err != nil {
+		ep.logger.Error("Error Updating Token Transfers from block", slog.Int64("block id", data.number), slog.String("error", err.Error()))
+		return err
+	}
+	if err := ep.UpdateNFTTransfersInStore(data.logs); err != nil {
+		ep.logger.Error("Error Updating NFT Transfers from block", slog.Int64("block id", data.number), slog.String("error", err.Error()))
+		return err
+	}
+	if err := ep.UpdateLogsInStore(data.contractLogs); err != nil {
+		ep.logger.Error("Error Updating Contract logs from block", slog.Int64("block id", data.number), slog.String("error", err.Error()))
+		return err
+	}
+	ep.promotePending(data.transactions)
+	if ep.balanceStore != nil {
+		ep.updateBalances(data)
+	}
+	if ep.stats != nil {
+		ep.updateStats(data)
+	}
+	return nil
+}
+
+// QueryTransactionsFromBlock queries the blockchain for transactions in a given block.
+func (ep *EthTxParser) QueryTransactionsFromBlock(ctx context.Context, blockNum int64) ([]EthTransaction, error) {
+	data, err := ep.fetchBlock(ctx, blockNum)
 	if err != nil {
 		return nil, err
 	}
+	return data.transactions, nil
+}
+
+// blockError reports the null result of a block call as ErrBlockNotFound, so that the block is
+// retried rather than processed as empty.
+func blockError(blockNum int64, err error) error {
+	if errors.Is(err, rpc.ErrNullResult) {
+		return fmt.Errorf("%w: %d", ErrBlockNotFound, blockNum)
+	}
+	return err
+}
 
-	return ethResp.Result.Transactions, nil
+// blockCall is the call of a block by number along with its transactions.
+func blockCall(blockNum int64, result *EthBlock) rpc.BatchElem {
+	return rpc.BatchElem{
+		Method: GetCurrentBlockByNumber,
+		Params: []interface{}{fmt.Sprintf("0x%x", blockNum), true}, // `true` includes transactions
+		Result: result,
+	}
 }
 
 // UpdateTransactionsInStore updates the transaction store with transactions from the given block.
@@ -219,33 +618,80 @@ func (ep *EthTxParser) UpdateTransactionsInStore(transactions []EthTransaction)
 	ep.mx.RLock()
 	defer ep.mx.RUnlock()
 	for _, tx := range transactions {
+		if tx.Kind == "" {
+			tx.Kind = TxKindExternal
+		}
 		from := strings.ToLower(tx.From)
 		to := strings.ToLower(tx.To)
+		if !ep.addresses[from] && !ep.addresses[to] {
+			continue
+		}
+		ep.decodeInput(&tx)
 		if ep.addresses[from] {
-			ep.txStore.AddTransaction(from, tx)
+			if err := ep.txStore.AddTransaction(from, tx); err != nil {
+				return err
+			}
 		}
 		if ep.addresses[to] {
-			ep.txStore.AddTransaction(to, tx)
+			if err := ep.txStore.AddTransaction(to, tx); err != nil {
+				return err
+			}
 		}
 	}
 	return nil
 }
 
-// Subscribe adds an address to the list of addresses to track.
+// decodeInput sets the DecodedInput of a transaction when its calldata matches a known method.
+func (ep *EthTxParser) decodeInput(tx *EthTransaction) {
+	if ep.decoder == nil || tx.Input == "" || tx.Input == "0x" {
+		return
+	}
+	decoded, err := ep.decoder.Decode(tx.To, tx.Input)
+	if err != nil {
+		if !errors.Is(err, decoder.ErrUnknownSelector) {
+			ep.logger.Debug("Failed to decode transaction input", slog.String("hash", tx.Hash), slog.String("error", err.Error()))
+		}
+		return
+	}
+	tx.DecodedInput = decoded
+}
+
+// Subscribe adds an address to the list of addresses to track, it fails if the address is not a
+// valid Ethereum address.
 func (ep *EthTxParser) Subscribe(address string) bool {
-	addr := strings.ToLower(address)
+	addr, err := ep.ValidateAddress(address)
+	if err != nil {
+		return false
+	}
 	ep.logger.Debug("Subscribing address", slog.String("address", addr))
 	ep.mx.Lock()
 	defer ep.mx.Unlock()
+	if !ep.addresses[addr] && ep.balanceStore != nil {
+		go ep.initBalances([]string{addr})
+	}
 	ep.addresses[addr] = true
 	return true
 }
 
+// Unsubscribe stops tracking an address, its stored transactions being kept. It returns false when
+// the address was not tracked.
+func (ep *EthTxParser) Unsubscribe(address string) bool {
+	addr, ok := ep.tracked(address)
+	if !ok {
+		return false
+	}
+	ep.logger.Debug("Unsubscribing address", slog.String("address", addr))
+	ep.mx.Lock()
+	defer ep.mx.Unlock()
+	delete(ep.addresses, addr)
+	return true
+}
+
 // GetTransactions returns a list of transactions for an address from the Transaction store.
 func (ep *EthTxParser) GetTransactions(address string) ([]EthTransaction, error) {
-	addr := strings.ToLower(address)
+	addr, ok := ep.tracked(address)
 	ep.logger.Debug("Getting transactions for address", slog.String("address", addr))
-	if _, ok := ep.addresses[addr]; !ok {
+	if !ok {
 		ep.logger.Debug("Address not found", slog.String("address", addr))
 		return nil, ErrAddressNotTracked
 	}
@@ -256,6 +702,74 @@ func (ep *EthTxParser) GetTransactions(address string) ([]EthTransaction, error)
 	return txs, nil
 }
 
+// ValidateAddress checks an Ethereum address, and its EIP-55 checksum, and returns its lower case
+// form.
+func (ep *EthTxParser) ValidateAddress(addr string) (string, error) {
+	validate := address.Validate
+	if ep.strictAddresses {
+		validate = address.ValidateStrict
+	}
+	if err := validate(addr); err != nil {
+		return "", fmt.Errorf("%w: %w", ErrInvalidAddress, err)
+	}
+	return address.Normalize(addr)
+}
+
+// tracked returns the canonical form of an address and whether it is tracked.
+func (ep *EthTxParser) tracked(addr string) (string, bool) {
+	canonical, err := address.Normalize(addr)
+	if err != nil {
+		return addr, false
+	}
+	ep.mx.RLock()
+	defer ep.mx.RUnlock()
+	return canonical, ep.addresses[canonical]
+}
+
+// ListTransactions returns the transactions of an address in the chain agnostic model.
+func (ep *EthTxParser) ListTransactions(address string) ([]Transaction, error) {
+	txs, err := ep.GetTransactions(address)
+	if err != nil {
+		return nil, err
+	}
+	res := make([]Transaction, len(txs))
+	for i, tx := range txs {
+		res[i] = tx.Normalise()
+	}
+	return res, nil
+}
+
+// ForEachTransaction calls fn with the transactions of an address in the chain agnostic model,
+// streaming them from the store.
+func (ep *EthTxParser) ForEachTransaction(address string, fn func(tx Transaction) error) error {
+	addr, ok := ep.tracked(address)
+	if !ok {
+		return ErrAddressNotTracked
+	}
+	return ep.txStore.ForEachTransaction(addr, func(tx EthTransaction) error {
+		return fn(tx.Normalise())
+	})
+}
+
+// Normalise returns the transaction in the chain agnostic model, its value sent from From to To.
+func (tx EthTransaction) Normalise() Transaction {
+	value := "0"
+	if v, ok := new(big.Int).SetString(strings.TrimPrefix(tx.Value, "0x"), 16); ok {
+		value = v.String()
+	}
+	blockNumber, _ := ParseHex(tx.BlockNumber)
+	timestamp, _ := ParseHex(tx.Timestamp)
+	return Transaction{
+		Kind:        tx.Kind,
+		Hash:        tx.Hash,
+		BlockHash:   tx.BlockHash,
+		BlockNumber: blockNumber,
+		Inputs:      []Transfer{{Address: strings.ToLower(tx.From), Value: value}},
+		Outputs:     []Transfer{{Address: strings.ToLower(tx.To), Value: value}},
+		Timestamp:   timestamp,
+	}
+}
+
 // ParseHex parses a hex string into an int64.
 func ParseHex(hex string) (int64, error) {
 	h := strings.TrimPrefix(hex, "0x")
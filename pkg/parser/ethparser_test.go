@@ -1,13 +1,19 @@
 package parser
 
 import (
+	"context"
+	"encoding/json"
 	"fmt"
 	"log/slog"
 	"net/http"
+	"net/http/httptest"
 	"os"
 	"testing"
+	"time"
 
+	"github.com/pmes126/tx-parser-service/internal/rpc"
 	"github.com/pmes126/tx-parser-service/internal/store"
+	"github.com/pmes126/tx-parser-service/pkg/decoder"
 )
 
 func TestEthTxParser_GetCurrentBlock(t *testing.T) {
@@ -27,7 +33,7 @@ func TestEthTxParser_GetCurrentBlock(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			etp := NewEthTxParser(store.NewMemTxStore[EthTransaction](), &http.Client{}, logger, 0)
-			got, err := etp.GetCurrentBlock()
+			got, err := etp.GetCurrentBlock(context.Background())
 			if (err != nil) != tt.wantErr {
 				t.Errorf("EthTxParser.GetCurrentBlock() error = %v, wantErr %v", err, tt.wantErr)
 				return
@@ -66,7 +72,7 @@ func TestEthTxParser_QueryTransactionsByBlock(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			etp := NewEthTxParser(store.NewMemTxStore[EthTransaction](), &http.Client{}, logger, 0)
-			tx, err := etp.QueryTransactionsFromBlock(tt.args.block)
+			tx, err := etp.QueryTransactionsFromBlock(context.Background(), tt.args.block)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("EthTxParser.QueryTransactionsByBlock() error = %v, wantErr %v", err, tt.wantErr)
 			}
@@ -143,3 +149,81 @@ func TestEthTxParser_UpdateAndGetTransactions(t *testing.T) {
 		})
 	}
 }
+
+func TestEthTxParser_UpdateTransactionsDecodesInput(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+	address := "0x1111111111111111111111111111111111111111"
+	etp := NewEthTxParser(store.NewMemTxStore[EthTransaction](), &http.Client{}, logger, 0, WithDecoder(decoder.NewDecoder()))
+	etp.Subscribe(address)
+	transactions := []EthTransaction{
+		{
+			From:  address,
+			To:    "0xa0b86991c6218b36c1d19d4a2e9eb0ce3606eb48",
+			Hash:  "0x1",
+			Input: "0xa9059cbb" + abiWord("2222222222222222222222222222222222222222") + abiWord("f4240"),
+		},
+		{
+			From:  address,
+			To:    "0x2222222222222222222222222222222222222222",
+			Hash:  "0x2",
+			Input: "0x",
+		},
+	}
+	if err := etp.UpdateTransactionsInStore(transactions); err != nil {
+		t.Fatalf("EthTxParser.UpdateTransactionsInStore() error = %v", err)
+	}
+	txs, err := etp.GetTransactions(address)
+	if err != nil {
+		t.Fatalf("EthTxParser.GetTransactions() error = %v", err)
+	}
+	if len(txs) != 2 {
+		t.Fatalf("EthTxParser.GetTransactions() = %v, want %v", len(txs), 2)
+	}
+	if txs[0].DecodedInput == nil || txs[0].DecodedInput.Method != "transfer" {
+		t.Errorf("EthTxParser.UpdateTransactionsInStore() decodedInput = %+v, want transfer", txs[0].DecodedInput)
+	}
+	if txs[1].DecodedInput != nil {
+		t.Errorf("EthTxParser.UpdateTransactionsInStore() decodedInput = %+v, want nil", txs[1].DecodedInput)
+	}
+}
+
+func TestEthTxParser_ChainID(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req rpc.Request
+		json.NewDecoder(r.Body).Decode(&req)
+		if req.Method != GetChainID {
+			t.Errorf("%v called", req.Method)
+		}
+		fmt.Fprintf(w, `{"jsonrpc":"2.0","id":%d,"result":"0x89"}`, req.Id)
+	}))
+	defer srv.Close()
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+	etp := NewEthTxParser(store.NewMemTxStore[EthTransaction](), &http.Client{}, logger, 0, WithEndpoints(srv.URL))
+	if got, err := etp.ChainID(context.Background()); err != nil || got != 137 {
+		t.Errorf("EthTxParser.ChainID() = %v, %v, want %v", got, err, 137)
+	}
+}
+
+func TestWithRetry(t *testing.T) {
+	tests := []struct {
+		name        string
+		maxAttempts int
+		baseDelay   time.Duration
+		maxDelay    time.Duration
+		want        [3]interface{}
+	}{
+		{name: "Test values", maxAttempts: 1, baseDelay: time.Millisecond, maxDelay: time.Second, want: [3]interface{}{1, time.Millisecond, time.Second}},
+		{name: "Test zero values keep the defaults", want: [3]interface{}{DefaultRetryMaxAttempts, DefaultRetryBaseDelay, DefaultRetryMaxDelay}},
+		{name: "Test negative values keep the defaults", maxAttempts: -1, baseDelay: -1, maxDelay: -1, want: [3]interface{}{DefaultRetryMaxAttempts, DefaultRetryBaseDelay, DefaultRetryMaxDelay}},
+	}
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			etp := NewEthTxParser(store.NewMemTxStore[EthTransaction](), &http.Client{}, logger, 0, WithRetry(tt.maxAttempts, tt.baseDelay, tt.maxDelay))
+			got := [3]interface{}{etp.retryPolicy.MaxAttempts, etp.retryPolicy.BaseDelay, etp.retryPolicy.MaxDelay}
+			if got != tt.want {
+				t.Errorf("WithRetry() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,67 @@
+package parser
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"time"
+
+	"github.com/pmes126/tx-parser-service/internal/conc"
+	"github.com/pmes126/tx-parser-service/internal/rpc"
+)
+
+const (
+	NewHeads = "newHeads"
+
+	// MinReconnectDelay and MaxReconnectDelay bound the backoff between two WebSocket connections.
+	MinReconnectDelay = time.Second
+	MaxReconnectDelay = 30 * time.Second
+)
+
+// EthHeader is the part of a newHeads notification used by the parser.
+type EthHeader struct {
+	Number string `json:"number"`
+}
+
+// watchHeads subscribes to the new heads of the chain over WebSocket and sends their numbers to
+// heads, the latest one replacing a head not received yet. It reconnects with backoff until ctx is
+// done. While no head is received the polling of Start takes over.
+func (ep *EthTxParser) watchHeads(ctx context.Context, heads chan int64) {
+	delay := MinReconnectDelay
+	for {
+		err := rpc.Subscribe(ctx, ep.wsURL, func(result json.RawMessage) {
+			var header EthHeader
+			if err := json.Unmarshal(result, &header); err != nil {
+				ep.logger.Error("Error decoding new head", slog.String("error", err.Error()))
+				return
+			}
+			number, err := ParseHex(header.Number)
+			if err != nil {
+				ep.logger.Error("Error decoding new head", slog.String("error", err.Error()))
+				return
+			}
+			ep.lastHeadAt.Store(time.Now().UnixNano())
+			delay = MinReconnectDelay
+			select {
+			case <-heads:
+			default:
+			}
+			heads <- number
+		}, NewHeads)
+		if ctx.Err() != nil {
+			return
+		}
+		ep.logger.Warn("New heads subscription failed, polling until reconnected", slog.Duration("retry in", delay), slog.String("error", err.Error()))
+		if !conc.Sleep(ctx, delay) {
+			return
+		}
+		delay = min(delay*2, MaxReconnectDelay)
+	}
+}
+
+// subscribed reports whether the new heads subscription delivered a head within the last polling
+// interval, in which case polling is not needed.
+func (ep *EthTxParser) subscribed() bool {
+	last := ep.lastHeadAt.Load()
+	return last != 0 && time.Since(time.Unix(0, last)) < ep.blockPollingInterval
+}
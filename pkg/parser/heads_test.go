@@ -0,0 +1,64 @@
+package parser
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/coder/websocket"
+	"github.com/pmes126/tx-parser-service/internal/rpc"
+	"github.com/pmes126/tx-parser-service/internal/store"
+)
+
+func TestEthTxParser_watchHeads(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := websocket.Accept(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.CloseNow()
+		_, msg, err := conn.Read(r.Context())
+		if err != nil {
+			return
+		}
+		var req rpc.Request
+		json.Unmarshal(msg, &req)
+		conn.Write(r.Context(), websocket.MessageText, []byte(`{"jsonrpc":"2.0","id":1,"result":"0x1"}`))
+		for _, number := range []string{"0xf", "0x10"} {
+			conn.Write(r.Context(), websocket.MessageText, []byte(`{"jsonrpc":"2.0","method":"eth_subscription","params":{"subscription":"0x1","result":{"number":"`+number+`"}}}`))
+		}
+		// keep the connection open until the client is done.
+		conn.Read(r.Context())
+	}))
+	defer srv.Close()
+
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+	etp := NewEthTxParser(store.NewMemTxStore[EthTransaction](), &http.Client{}, logger, 12, WithWebSocket("ws"+strings.TrimPrefix(srv.URL, "http")))
+	if etp.subscribed() {
+		t.Errorf("EthTxParser.subscribed() = true before any head")
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	heads := make(chan int64, 1)
+	go etp.watchHeads(ctx, heads)
+	deadline := time.After(time.Second)
+	for {
+		select {
+		case head := <-heads:
+			if head == 16 {
+				if !etp.subscribed() {
+					t.Errorf("EthTxParser.subscribed() = false after a head")
+				}
+				return
+			}
+		case <-deadline:
+			t.Fatalf("EthTxParser.watchHeads() did not deliver head 16")
+		}
+	}
+}
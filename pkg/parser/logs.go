@@ -0,0 +1,237 @@
+package parser
+
+import (
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"math/big"
+	"slices"
+	"strings"
+
+	"github.com/pmes126/tx-parser-service/internal/rpc"
+)
+
+const (
+	GetLogs = "eth_getLogs"
+
+	// MaxLogTopics is the maximum number of indexed topics of an event log.
+	MaxLogTopics = 4
+)
+
+// LogSubscription tracks the event logs emitted by a contract. Topics filters on topic0..3,
+// an empty topic or a missing trailing topic matches any value.
+type LogSubscription struct {
+	Contract string   `json:"contract"`
+	Topics   []string `json:"topics,omitempty"`
+}
+
+// LogsFilter is the filter object of an eth_getLogs request.
+type LogsFilter struct {
+	FromBlock string        `json:"fromBlock"`
+	ToBlock   string        `json:"toBlock"`
+	Address   []string      `json:"address,omitempty"`
+	Topics    []interface{} `json:"topics,omitempty"`
+}
+
+// EthLog represents an event log emitted by a contract.
+type EthLog struct {
+	Address         string   `json:"address"`
+	Topics          []string `json:"topics"`
+	Data            string   `json:"data"`
+	BlockNumber     string   `json:"blockNumber"`
+	BlockHash       string   `json:"blockHash"`
+	TransactionHash string   `json:"transactionHash"`
+	LogIndex        string   `json:"logIndex"`
+	Removed         bool     `json:"removed"`
+}
+
+// Key identifies a log in the stores.
+func (l EthLog) Key() string {
+	return l.TransactionHash + "/" + l.LogIndex
+}
+
+// logsCall is the call of the logs of a block matching the address and topics of filter.
+func logsCall(blockNum int64, filter LogsFilter, result *[]EthLog) rpc.BatchElem {
+	filter.FromBlock = fmt.Sprintf("0x%x", blockNum)
+	filter.ToBlock = filter.FromBlock
+	return rpc.BatchElem{Method: GetLogs, Params: []interface{}{filter}, Result: result}
+}
+
+// SubscribeLogs adds a contract event log subscription.
+func (ep *EthTxParser) SubscribeLogs(sub LogSubscription) error {
+	sub, err := normalizeLogSubscription(sub)
+	if err != nil {
+		return err
+	}
+	ep.logger.Debug("Subscribing contract logs", slog.String("contract", sub.Contract), slog.Any("topics", sub.Topics))
+	ep.mx.Lock()
+	defer ep.mx.Unlock()
+	for _, s := range ep.logSubscriptions[sub.Contract] {
+		if slices.Equal(s.Topics, sub.Topics) {
+			return nil
+		}
+	}
+	ep.logSubscriptions[sub.Contract] = append(ep.logSubscriptions[sub.Contract], sub)
+	return nil
+}
+
+// subscribedContracts returns the contracts with at least one log subscription.
+func (ep *EthTxParser) subscribedContracts() []string {
+	ep.mx.RLock()
+	defer ep.mx.RUnlock()
+	contracts := make([]string, 0, len(ep.logSubscriptions))
+	for c := range ep.logSubscriptions {
+		contracts = append(contracts, c)
+	}
+	return contracts
+}
+
+// UpdateLogsInStore updates the log store with the logs matching a log subscription.
+func (ep *EthTxParser) UpdateLogsInStore(logs []EthLog) error {
+	ep.mx.RLock()
+	defer ep.mx.RUnlock()
+	for _, l := range logs {
+		if l.Removed {
+			continue
+		}
+		contract := strings.ToLower(l.Address)
+		for _, sub := range ep.logSubscriptions[contract] {
+			if sub.matchesTopics(l.Topics) {
+				if err := ep.logStore.AddTransaction(contract, l); err != nil {
+					return err
+				}
+				break
+			}
+		}
+	}
+	return nil
+}
+
+// GetLogs returns the stored logs of a contract matching the topics filter.
+func (ep *EthTxParser) GetLogs(contract string, topics []string) ([]EthLog, error) {
+	filter, err := normalizeLogSubscription(LogSubscription{Contract: contract, Topics: topics})
+	if err != nil {
+		return nil, err
+	}
+	ep.logger.Debug("Getting logs for contract", slog.String("contract", filter.Contract))
+	ep.mx.RLock()
+	_, ok := ep.logSubscriptions[filter.Contract]
+	ep.mx.RUnlock()
+	if !ok {
+		return nil, ErrContractNotTracked
+	}
+	logs, err := ep.logStore.GetTransactions(filter.Contract)
+	if err != nil {
+		return nil, err
+	}
+	res := logs[:0]
+	for _, l := range logs {
+		if filter.matchesTopics(l.Topics) {
+			res = append(res, l)
+		}
+	}
+	return res, nil
+}
+
+// matchesTopics reports whether the topics of a log match the subscription topic filters.
+func (s LogSubscription) matchesTopics(topics []string) bool {
+	for i, t := range s.Topics {
+		if t == "" {
+			continue
+		}
+		if i >= len(topics) || !strings.EqualFold(topics[i], t) {
+			return false
+		}
+	}
+	return true
+}
+
+// normalizeLogSubscription validates a log subscription and lowercases its contract and topics.
+func normalizeLogSubscription(sub LogSubscription) (LogSubscription, error) {
+	if len(sub.Topics) > MaxLogTopics {
+		return sub, fmt.Errorf("%w: at most %d topics", ErrInvalidTopic, MaxLogTopics)
+	}
+	res := LogSubscription{Contract: strings.ToLower(sub.Contract), Topics: make([]string, len(sub.Topics))}
+	for i, t := range sub.Topics {
+		if t == "" {
+			continue
+		}
+		if len(t) != 66 || !strings.HasPrefix(t, "0x") {
+			return sub, fmt.Errorf("%w: %q", ErrInvalidTopic, t)
+		}
+		if _, err := hex.DecodeString(t[2:]); err != nil {
+			return sub, fmt.Errorf("%w: %q", ErrInvalidTopic, t)
+		}
+		res.Topics[i] = strings.ToLower(t)
+	}
+	// Trailing wildcards are the same as no filter.
+	for len(res.Topics) > 0 && res.Topics[len(res.Topics)-1] == "" {
+		res.Topics = res.Topics[:len(res.Topics)-1]
+	}
+	return res, nil
+}
+
+// topicToAddress extracts the address stored in the low 20 bytes of an indexed topic.
+func topicToAddress(topic string) (string, error) {
+	t := strings.TrimPrefix(topic, "0x")
+	if len(t) != 64 {
+		return "", fmt.Errorf("invalid address topic %q", topic)
+	}
+	return "0x" + strings.ToLower(t[24:]), nil
+}
+
+// compactHex strips the leading zeros of an ABI encoded word, e.g. 0x000...0a becomes 0xa.
+func compactHex(word string) string {
+	h := strings.TrimLeft(strings.TrimPrefix(word, "0x"), "0")
+	if h == "" {
+		return "0x0"
+	}
+	return "0x" + h
+}
+
+// abiWords splits ABI encoded data into its 32 byte words.
+func abiWords(data string) ([]string, error) {
+	d := strings.TrimPrefix(data, "0x")
+	if len(d)%64 != 0 {
+		return nil, fmt.Errorf("invalid ABI data length %d", len(d))
+	}
+	words := make([]string, len(d)/64)
+	for i := range words {
+		words[i] = d[i*64 : (i+1)*64]
+	}
+	return words, nil
+}
+
+// abiUintArray decodes the dynamic uint256[] argument at position arg of the ABI encoded words.
+// The offset and length come from whoever emitted the log, they are checked against the words
+// before they are used.
+func abiUintArray(words []string, arg int) ([]string, error) {
+	if arg >= len(words) {
+		return nil, fmt.Errorf("missing ABI argument %d", arg)
+	}
+	offset, ok := parseQuantity("0x" + words[arg])
+	if !ok || !offset.IsUint64() || offset.Uint64()%32 != 0 {
+		return nil, fmt.Errorf("invalid ABI offset for argument %d", arg)
+	}
+	if offset.Uint64()/32 >= uint64(len(words)) {
+		return nil, fmt.Errorf("ABI offset for argument %d out of range", arg)
+	}
+	start := int(offset.Uint64() / 32)
+	length, ok := parseQuantity("0x" + words[start])
+	if !ok || !length.IsUint64() || length.Uint64() > uint64(len(words)-start-1) {
+		return nil, fmt.Errorf("invalid ABI array length for argument %d", arg)
+	}
+	res := make([]string, length.Uint64())
+	for i := range res {
+		res[i] = compactHex(words[start+1+i])
+	}
+	return res, nil
+}
+
+// parseQuantity parses a 0x prefixed hex or a decimal quantity.
+func parseQuantity(q string) (*big.Int, bool) {
+	if h, ok := strings.CutPrefix(q, "0x"); ok {
+		return new(big.Int).SetString(h, 16)
+	}
+	return new(big.Int).SetString(q, 10)
+}
@@ -0,0 +1,65 @@
+package parser
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"os"
+	"testing"
+
+	"github.com/pmes126/tx-parser-service/internal/store"
+)
+
+func TestEthTxParser_SubscribeAndGetLogs(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+	contract := "0xA0b86991c6218b36c1d19d4a2e9eb0ce3606eB48"
+	other := "0x2222222222222222222222222222222222222222"
+	approvalTopic := "0x8c5be1e5ebec7d5bd14f71427d1e84f3dd0314c0f7b2291e5b200ac8c7c3b925"
+	holder := addressTopic("0x1111111111111111111111111111111111111111")
+	logs := []EthLog{
+		{Address: contract, Topics: []string{TransferEventTopic, holder, addressTopic(other)}, TransactionHash: "0x1"},
+		{Address: contract, Topics: []string{TransferEventTopic, addressTopic(other), holder}, TransactionHash: "0x2"},
+		{Address: contract, Topics: []string{approvalTopic, holder, addressTopic(other)}, TransactionHash: "0x3"},
+		{Address: other, Topics: []string{TransferEventTopic, holder, addressTopic(other)}, TransactionHash: "0x4"},
+		{Address: contract, Topics: []string{TransferEventTopic, holder, addressTopic(other)}, TransactionHash: "0x5", Removed: true},
+	}
+	etp := NewEthTxParser(store.NewMemTxStore[EthTransaction](), &http.Client{}, logger, 0)
+	if err := etp.SubscribeLogs(LogSubscription{Contract: contract, Topics: []string{TransferEventTopic}}); err != nil {
+		t.Fatalf("EthTxParser.SubscribeLogs() error = %v", err)
+	}
+	if err := etp.SubscribeLogs(LogSubscription{Contract: contract, Topics: []string{"", holder}}); err != nil {
+		t.Fatalf("EthTxParser.SubscribeLogs() error = %v", err)
+	}
+	if err := etp.SubscribeLogs(LogSubscription{Contract: contract, Topics: []string{"0x1234"}}); !errors.Is(err, ErrInvalidTopic) {
+		t.Fatalf("EthTxParser.SubscribeLogs() error = %v, want %v", err, ErrInvalidTopic)
+	}
+	if got := etp.subscribedContracts(); len(got) != 1 {
+		t.Fatalf("EthTxParser.subscribedContracts() = %v, want 1 contract", got)
+	}
+	if err := etp.UpdateLogsInStore(logs); err != nil {
+		t.Fatalf("EthTxParser.UpdateLogsInStore() error = %v", err)
+	}
+	tests := []struct {
+		name     string
+		contract string
+		topics   []string
+		want     int
+		wantErr  error
+	}{
+		{name: "Test all logs", contract: contract, want: 3},
+		{name: "Test topic0 filter", contract: contract, topics: []string{TransferEventTopic}, want: 2},
+		{name: "Test topic2 filter", contract: contract, topics: []string{"", "", holder, ""}, want: 1},
+		{name: "Test untracked contract", contract: other, wantErr: ErrContractNotTracked},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := etp.GetLogs(tt.contract, tt.topics)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("EthTxParser.GetLogs() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if len(got) != tt.want {
+				t.Errorf("EthTxParser.GetLogs() = %v, want %v", len(got), tt.want)
+			}
+		})
+	}
+}
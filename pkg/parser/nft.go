@@ -0,0 +1,190 @@
+package parser
+
+import (
+	"fmt"
+	"log/slog"
+	"math/big"
+	"strings"
+)
+
+const (
+	// TransferSingleEventTopic is the keccak256 hash of TransferSingle(address,address,address,uint256,uint256).
+	TransferSingleEventTopic = "0xc3d58168c5ae7397731d063d5bbf3d657854427343f4c083240f7aacaa2d0f62"
+	// TransferBatchEventTopic is the keccak256 hash of TransferBatch(address,address,address,uint256[],uint256[]).
+	TransferBatchEventTopic = "0x4a39dc06d4c0dbc64b70af90fd698a233a518aa5d07e595d983b8c0526c8f7fb"
+
+	StandardERC721  = "erc721"
+	StandardERC1155 = "erc1155"
+)
+
+// NFTTransfer represents an ERC-721 or ERC-1155 token transfer decoded from an event log.
+type NFTTransfer struct {
+	Standard    string `json:"standard"`
+	Contract    string `json:"contract"`
+	Operator    string `json:"operator,omitempty"`
+	From        string `json:"from"`
+	To          string `json:"to"`
+	TokenID     string `json:"tokenId"`
+	Amount      string `json:"amount"`
+	Hash        string `json:"hash"`
+	BlockNumber string `json:"blockNumber"`
+	LogIndex    string `json:"logIndex"`
+}
+
+// Key identifies an NFT transfer in the stores by its log and token, an ERC-1155 batch transfer
+// moving several tokens in one log.
+func (t NFTTransfer) Key() string {
+	return t.Hash + "/" + t.LogIndex + "/" + t.TokenID
+}
+
+// NFTFilter narrows down the NFT transfers returned for an address. Empty fields match everything.
+type NFTFilter struct {
+	Contract string
+	TokenID  string
+}
+
+// UpdateNFTTransfersInStore updates the NFT transfer store with the ERC-721 and ERC-1155
+// transfers involving tracked addresses.
+func (ep *EthTxParser) UpdateNFTTransfersInStore(logs []EthLog) error {
+	ep.mx.RLock()
+	defer ep.mx.RUnlock()
+	for _, l := range logs {
+		transfers, err := decodeNFTTransfers(l)
+		if err != nil {
+			ep.logger.Warn("Skipping malformed NFT transfer log", slog.String("hash", l.TransactionHash), slog.String("error", err.Error()))
+			continue
+		}
+		for _, nt := range transfers {
+			if ep.addresses[nt.From] {
+				if err := ep.nftStore.AddTransaction(nt.From, nt); err != nil {
+					return err
+				}
+			}
+			if ep.addresses[nt.To] && nt.To != nt.From {
+				if err := ep.nftStore.AddTransaction(nt.To, nt); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// GetNFTTransfers returns a list of NFT transfers for an address from the NFT store, narrowed down by the filter.
+func (ep *EthTxParser) GetNFTTransfers(address string, filter NFTFilter) ([]NFTTransfer, error) {
+	addr, ok := ep.tracked(address)
+	ep.logger.Debug("Getting NFT transfers for address", slog.String("address", addr))
+	if !ok {
+		return nil, ErrAddressNotTracked
+	}
+	var tokenID *big.Int
+	if filter.TokenID != "" {
+		id, ok := parseQuantity(filter.TokenID)
+		if !ok {
+			return nil, fmt.Errorf("%w: %q", ErrInvalidTokenID, filter.TokenID)
+		}
+		tokenID = id
+	}
+	transfers, err := ep.nftStore.GetTransactions(addr)
+	if err != nil {
+		return nil, err
+	}
+	res := transfers[:0]
+	for _, nt := range transfers {
+		if filter.Contract != "" && !strings.EqualFold(nt.Contract, filter.Contract) {
+			continue
+		}
+		if tokenID != nil {
+			if id, ok := parseQuantity(nt.TokenID); !ok || id.Cmp(tokenID) != 0 {
+				continue
+			}
+		}
+		res = append(res, nt)
+	}
+	return res, nil
+}
+
+// decodeNFTTransfers decodes ERC-721 Transfer and ERC-1155 TransferSingle/TransferBatch logs.
+// Logs of any other kind decode to no transfers.
+func decodeNFTTransfers(l EthLog) ([]NFTTransfer, error) {
+	if l.Removed || len(l.Topics) == 0 {
+		return nil, nil
+	}
+	base := NFTTransfer{
+		Contract:    strings.ToLower(l.Address),
+		Hash:        l.TransactionHash,
+		BlockNumber: l.BlockNumber,
+		LogIndex:    l.LogIndex,
+	}
+	switch strings.ToLower(l.Topics[0]) {
+	case TransferEventTopic:
+		// ERC-20 transfers share the signature but carry the value in data.
+		if len(l.Topics) != 4 {
+			return nil, nil
+		}
+		from, to, err := decodeAddressTopics(l.Topics[1], l.Topics[2])
+		if err != nil {
+			return nil, err
+		}
+		base.Standard = StandardERC721
+		base.From, base.To = from, to
+		base.TokenID = compactHex(l.Topics[3])
+		base.Amount = "0x1"
+		return []NFTTransfer{base}, nil
+	case TransferSingleEventTopic, TransferBatchEventTopic:
+		if len(l.Topics) != 4 {
+			return nil, fmt.Errorf("unexpected number of topics %d", len(l.Topics))
+		}
+		operator, err := topicToAddress(l.Topics[1])
+		if err != nil {
+			return nil, err
+		}
+		from, to, err := decodeAddressTopics(l.Topics[2], l.Topics[3])
+		if err != nil {
+			return nil, err
+		}
+		base.Standard = StandardERC1155
+		base.Operator, base.From, base.To = operator, from, to
+		words, err := abiWords(l.Data)
+		if err != nil {
+			return nil, err
+		}
+		if strings.EqualFold(l.Topics[0], TransferSingleEventTopic) {
+			if len(words) != 2 {
+				return nil, fmt.Errorf("unexpected TransferSingle data length %d", len(words))
+			}
+			base.TokenID, base.Amount = compactHex(words[0]), compactHex(words[1])
+			return []NFTTransfer{base}, nil
+		}
+		ids, err := abiUintArray(words, 0)
+		if err != nil {
+			return nil, err
+		}
+		amounts, err := abiUintArray(words, 1)
+		if err != nil {
+			return nil, err
+		}
+		if len(ids) != len(amounts) {
+			return nil, fmt.Errorf("TransferBatch ids and values length mismatch %d != %d", len(ids), len(amounts))
+		}
+		transfers := make([]NFTTransfer, len(ids))
+		for i := range ids {
+			transfers[i] = base
+			transfers[i].TokenID, transfers[i].Amount = ids[i], amounts[i]
+		}
+		return transfers, nil
+	}
+	return nil, nil
+}
+
+func decodeAddressTopics(fromTopic, toTopic string) (string, string, error) {
+	from, err := topicToAddress(fromTopic)
+	if err != nil {
+		return "", "", err
+	}
+	to, err := topicToAddress(toTopic)
+	if err != nil {
+		return "", "", err
+	}
+	return from, to, nil
+}
@@ -0,0 +1,115 @@
+package parser
+
+import (
+	"log/slog"
+	"net/http"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/pmes126/tx-parser-service/internal/store"
+)
+
+func abiWord(hex string) string {
+	return strings.Repeat("0", 64-len(hex)) + hex
+}
+
+func TestEthTxParser_UpdateAndGetNFTTransfers(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+	Addresses := []string{"0x1111111111111111111111111111111111111111", "0x2222222222222222222222222222222222222222", "0x3333333333333333333333333333333333333333"}
+	erc721 := "0xbc4ca0eda7647a8ab7c2061c2e118a18a936f13d"
+	erc1155 := "0x76be3b62873462d2142405439777e971754e8e77"
+	logs := []EthLog{
+		{
+			Address:         erc721,
+			Topics:          []string{TransferEventTopic, addressTopic(Addresses[0]), addressTopic(Addresses[1]), "0x" + abiWord("2a")},
+			TransactionHash: "0x1",
+		},
+		{
+			Address:         erc1155,
+			Topics:          []string{TransferSingleEventTopic, addressTopic(Addresses[2]), addressTopic(Addresses[1]), addressTopic(Addresses[2])},
+			Data:            "0x" + abiWord("7") + abiWord("3"),
+			TransactionHash: "0x2",
+		},
+		{
+			Address:         erc1155,
+			Topics:          []string{TransferBatchEventTopic, addressTopic(Addresses[2]), addressTopic(Addresses[2]), addressTopic(Addresses[0])},
+			Data:            "0x" + abiWord("40") + abiWord("a0") + abiWord("2") + abiWord("7") + abiWord("8") + abiWord("2") + abiWord("5") + abiWord("6"),
+			TransactionHash: "0x3",
+		},
+		{
+			// ERC-20 transfer, ignored.
+			Address:         erc721,
+			Topics:          []string{TransferEventTopic, addressTopic(Addresses[0]), addressTopic(Addresses[1])},
+			Data:            "0x" + abiWord("1"),
+			TransactionHash: "0x4",
+		},
+	}
+	tests := []struct {
+		name    string
+		address string
+		filter  NFTFilter
+		want    int
+		wantErr bool
+	}{
+		{name: "Test ERC-721 and ERC-1155 batch", address: Addresses[0], want: 3},
+		{name: "Test contract filter", address: Addresses[0], filter: NFTFilter{Contract: erc1155}, want: 2},
+		{name: "Test decimal token id filter", address: Addresses[1], filter: NFTFilter{TokenID: "42"}, want: 1},
+		{name: "Test hex token id filter", address: Addresses[2], filter: NFTFilter{TokenID: "0x7"}, want: 2},
+		{name: "Test invalid token id filter", address: Addresses[2], filter: NFTFilter{TokenID: "abc"}, wantErr: true},
+	}
+	etp := NewEthTxParser(store.NewMemTxStore[EthTransaction](), &http.Client{}, logger, 0)
+	for _, address := range Addresses {
+		etp.Subscribe(address)
+	}
+	if err := etp.UpdateNFTTransfersInStore(logs); err != nil {
+		t.Fatalf("EthTxParser.UpdateNFTTransfersInStore() error = %v", err)
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			transfers, err := etp.GetNFTTransfers(tt.address, tt.filter)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("EthTxParser.GetNFTTransfers() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if len(transfers) != tt.want {
+				t.Errorf("EthTxParser.GetNFTTransfers() = %v, want %v", len(transfers), tt.want)
+			}
+			for _, tr := range transfers {
+				if tr.From != tt.address && tr.To != tt.address {
+					t.Errorf("EthTxParser.GetNFTTransfers() transfer %s does not involve %s", tr.Hash, tt.address)
+				}
+			}
+		})
+	}
+}
+
+func TestDecodeNFTTransfers(t *testing.T) {
+	topics := []string{TransferBatchEventTopic, addressTopic("0x1111111111111111111111111111111111111111"),
+		addressTopic("0x2222222222222222222222222222222222222222"), addressTopic("0x3333333333333333333333333333333333333333")}
+	max := strings.Repeat("f", 64)
+	tests := []struct {
+		name    string
+		data    string
+		want    int
+		wantErr bool
+	}{
+		{name: "Test batch", data: abiWord("40") + abiWord("80") + abiWord("1") + abiWord("7") + abiWord("1") + abiWord("2"), want: 1},
+		{name: "Test oversized length", data: abiWord("40") + abiWord("80") + max + abiWord("7") + abiWord("1") + abiWord("2"), wantErr: true},
+		{name: "Test oversized offset", data: strings.Repeat("f", 62) + "e0" + abiWord("80") + abiWord("1") + abiWord("7") + abiWord("1") + abiWord("2"), wantErr: true},
+		{name: "Test offset out of range", data: abiWord("40") + abiWord("1000") + abiWord("1") + abiWord("7"), wantErr: true},
+		{name: "Test unaligned offset", data: abiWord("41") + abiWord("80") + abiWord("1") + abiWord("7"), wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := decodeNFTTransfers(EthLog{Topics: topics, Data: "0x" + tt.data})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("decodeNFTTransfers() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if len(got) != tt.want {
+				t.Errorf("decodeNFTTransfers() = %v, want %v", len(got), tt.want)
+			}
+		})
+	}
+}
@@ -1,6 +1,7 @@
 package parser
 
 import (
+	"context"
 	"errors"
 )
 
@@ -9,14 +10,54 @@ const (
 )
 
 var (
-	ErrAddressNotTracked = errors.New("Address not tracked")
+	ErrAddressNotTracked  = errors.New("Address not tracked")
+	ErrInvalidTokenID     = errors.New("invalid token id")
+	ErrContractNotTracked = errors.New("Contract not tracked")
+	ErrInvalidTopic       = errors.New("invalid topic")
+	// ErrBlockNotFound is a block the node does not know yet, e.g. a lagging node behind a load balancer.
+	ErrBlockNotFound = errors.New("block not found")
+	// ErrInvalidAddress is an address that is not an address of the chain.
+	ErrInvalidAddress = errors.New("invalid address")
 )
 
+// Parser is the part of a parser common to every chain.
 type Parser interface {
 	// GetCurrentBlock last parsed block
-	GetCurrentBlock() (int64, error)
+	GetCurrentBlock(ctx context.Context) (int64, error)
+	// ValidateAddress canonical form of an address of the chain
+	ValidateAddress(address string) (string, error)
 	// Subscribe address to observer
 	Subscribe(address string) bool
+	// ListTransactions list of inbound or outbound transactions for an address in the chain agnostic model
+	ListTransactions(address string) ([]Transaction, error)
+	// ForEachTransaction inbound or outbound transactions of an address in the chain agnostic model, streamed to fn
+	ForEachTransaction(address string, fn func(tx Transaction) error) error
+	// GetStats aggregate statistics of the transactions of an address
+	GetStats(address string) (AddressStats, error)
+	// FailedBlocks blocks that failed all of their retries
+	FailedBlocks() []FailedBlock
+	// ReplayFailedBlocks push the failed blocks back for processing
+	ReplayFailedBlocks(ctx context.Context) (int, error)
+	// QueueStats state of the queue of blocks waiting to be processed
+	QueueStats() QueueStats
+}
+
+// EVMParser is a Parser of an EVM chain, with the Ethereum transactions, token transfers and
+// contract logs.
+type EVMParser interface {
+	Parser
 	// GetTransactions list of inbound or outbound transactions for an address
 	GetTransactions(address string) ([]EthTransaction, error)
+	// GetTokenTransfers list of inbound or outbound ERC-20 token transfers for an address
+	GetTokenTransfers(address string) ([]TokenTransfer, error)
+	// GetNFTTransfers list of inbound or outbound ERC-721/ERC-1155 transfers for an address
+	GetNFTTransfers(address string, filter NFTFilter) ([]NFTTransfer, error)
+	// SubscribeLogs contract event logs to observe
+	SubscribeLogs(sub LogSubscription) error
+	// GetLogs list of observed event logs of a contract matching the topics
+	GetLogs(contract string, topics []string) ([]EthLog, error)
+	// GetPendingTransactions list of pending transactions of an address, with their status
+	GetPendingTransactions(address string) ([]PendingTransaction, error)
+	// GetBalance native balance of an address, with its history
+	GetBalance(address string) (Balance, error)
 }
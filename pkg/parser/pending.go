@@ -0,0 +1,263 @@
+package parser
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/pmes126/tx-parser-service/internal/conc"
+	"github.com/pmes126/tx-parser-service/internal/rpc"
+)
+
+const (
+	NewPendingTransactions = "newPendingTransactions"
+	GetTransactionByHash   = "eth_getTransactionByHash"
+	PendingBlockParam      = "pending"
+
+	// PendingModePoll polls the pending block of the node for the pending transactions.
+	PendingModePoll = "poll"
+	// PendingModeSubscribe subscribes to the pending transactions of the node over WebSocket.
+	PendingModeSubscribe = "subscribe"
+
+	PendingStatusPending = "pending"
+	// PendingStatusMined is a pending transaction included in a committed block.
+	PendingStatusMined = "mined"
+	// PendingStatusReplaced is a pending transaction whose nonce was used by another transaction
+	// of its sender, e.g. a speed up or a cancellation.
+	PendingStatusReplaced = "replaced"
+	// PendingStatusDropped is a transaction pending for longer than the pending timeout.
+	PendingStatusDropped = "dropped"
+
+	DefaultPendingInterval = 2 * time.Second
+	DefaultPendingTimeout  = 30 * time.Minute
+	// pendingFetchQueueSize is the number of announced transaction hashes waiting to be fetched,
+	// the next ones are skipped until the queue drains.
+	pendingFetchQueueSize = 1024
+	// pendingFetchBatchSize is the maximum number of announced transactions fetched at once, the
+	// calls being split into batches of the batch size of the client.
+	pendingFetchBatchSize = 256
+)
+
+// PendingTransaction is a transaction of a tracked address seen before being mined.
+type PendingTransaction struct {
+	EthTransaction
+	Status    string    `json:"status"`
+	FirstSeen time.Time `json:"firstSeen"`
+	UpdatedAt time.Time `json:"updatedAt"`
+	// ReplacedBy is the hash of the transaction mined with the same sender and nonce.
+	ReplacedBy string `json:"replacedBy,omitempty"`
+}
+
+// WithPending tracks the pending transactions of the tracked addresses, polling the pending block
+// every interval or subscribing to the pending transactions of the WebSocket endpoint depending on
+// mode. A pending transaction is dropped when it is not mined or replaced within timeout, and
+// forgotten once it has been resolved for timeout.
+//
+// The tracking is best-effort: a transaction that enters and leaves the mempool between two polls,
+// or announced while the fetch queue is full or the subscription reconnects, is never seen.
+func WithPending(mode string, interval, timeout time.Duration) Option {
+	return func(ep *EthTxParser) {
+		ep.pendingMode = mode
+		if interval > 0 {
+			ep.pendingInterval = interval
+		}
+		if timeout > 0 {
+			ep.pendingTimeout = timeout
+		}
+	}
+}
+
+// watchPending records the pending transactions of the tracked addresses until ctx is done. The
+// subscription falls back to polling when there is no WebSocket endpoint.
+func (ep *EthTxParser) watchPending(ctx context.Context) {
+	if ep.pendingMode == PendingModeSubscribe && ep.wsURL != "" {
+		ep.subscribePending(ctx)
+		return
+	}
+	if ep.pendingMode == PendingModeSubscribe {
+		ep.logger.Warn("No WebSocket endpoint to subscribe to pending transactions, polling instead")
+	}
+	ep.pollPending(ctx)
+}
+
+// pollPending records the transactions of the pending block every pending interval.
+func (ep *EthTxParser) pollPending(ctx context.Context) {
+	ticker := time.NewTicker(ep.pendingInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			var block EthBlock
+			if err := ep.rpc.Call(ctx, &block, GetCurrentBlockByNumber, PendingBlockParam, true); err != nil {
+				ep.logger.Error("Error querying pending block", slog.String("error", err.Error()))
+				continue
+			}
+			ep.recordPending(block.Transactions)
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// subscribePending subscribes to the full pending transactions, reconnecting with backoff. The
+// nodes notifying the hashes only are supported too, the transactions being fetched by hash in
+// batches.
+func (ep *EthTxParser) subscribePending(ctx context.Context) {
+	hashes := make(chan string, pendingFetchQueueSize)
+	go ep.fetchPending(ctx, hashes)
+	delay := MinReconnectDelay
+	for {
+		err := rpc.Subscribe(ctx, ep.wsURL, func(result json.RawMessage) {
+			delay = MinReconnectDelay
+			var hash string
+			if err := json.Unmarshal(result, &hash); err == nil {
+				select {
+				case hashes <- hash:
+				default:
+					ep.logger.Debug("Pending transactions fetch queue full, skipping", slog.String("hash", hash))
+				}
+				return
+			}
+			var tx EthTransaction
+			if err := json.Unmarshal(result, &tx); err != nil {
+				ep.logger.Error("Error decoding pending transaction", slog.String("error", err.Error()))
+				return
+			}
+			ep.recordPending([]EthTransaction{tx})
+		}, NewPendingTransactions, true)
+		if ctx.Err() != nil {
+			return
+		}
+		ep.logger.Warn("Pending transactions subscription failed", slog.Duration("retry in", delay), slog.String("error", err.Error()))
+		if !conc.Sleep(ctx, delay) {
+			return
+		}
+		delay = min(delay*2, MaxReconnectDelay)
+	}
+}
+
+// fetchPending fetches and records the transactions of the announced hashes, the hashes announced
+// while a batch is fetched being fetched together in the next one.
+func (ep *EthTxParser) fetchPending(ctx context.Context, hashes <-chan string) {
+	for {
+		var batch []string
+		select {
+		case hash := <-hashes:
+			batch = append(batch, hash)
+		case <-ctx.Done():
+			return
+		}
+	queued:
+		for len(batch) < pendingFetchBatchSize {
+			select {
+			case hash := <-hashes:
+				batch = append(batch, hash)
+			default:
+				break queued
+			}
+		}
+		txs := make([]EthTransaction, len(batch))
+		calls := make([]rpc.BatchElem, len(batch))
+		for i, hash := range batch {
+			calls[i] = rpc.BatchElem{Method: GetTransactionByHash, Params: []interface{}{hash}, Result: &txs[i]}
+		}
+		if err := ep.rpc.BatchCall(ctx, calls); err != nil {
+			ep.logger.Debug("Error fetching pending transactions", slog.Int("transactions", len(batch)), slog.String("error", err.Error()))
+			continue
+		}
+		fetched := txs[:0]
+		for i, tx := range txs {
+			// the transaction may be mined or dropped already.
+			if calls[i].Err == nil && tx.Hash != "" {
+				fetched = append(fetched, tx)
+			}
+		}
+		ep.recordPending(fetched)
+	}
+}
+
+// recordPending records the pending transactions sent or received by a tracked address, the ones
+// already known being left as they are.
+func (ep *EthTxParser) recordPending(txs []EthTransaction) {
+	now := time.Now()
+	ep.mx.RLock()
+	defer ep.mx.RUnlock()
+	ep.pendingMx.Lock()
+	defer ep.pendingMx.Unlock()
+	for _, tx := range txs {
+		if !ep.addresses[strings.ToLower(tx.From)] && !ep.addresses[strings.ToLower(tx.To)] {
+			continue
+		}
+		if _, ok := ep.pending[tx.Hash]; ok {
+			continue
+		}
+		if tx.Kind == "" {
+			tx.Kind = TxKindExternal
+		}
+		ep.decodeInput(&tx)
+		ep.pending[tx.Hash] = &PendingTransaction{EthTransaction: tx, Status: PendingStatusPending, FirstSeen: now, UpdatedAt: now}
+	}
+}
+
+// promotePending resolves the pending transactions with the transactions of a committed block:
+// the ones in the block are mined and the ones whose sender and nonce match a transaction of the
+// block are replaced. The transactions pending for too long are dropped and the ones resolved for
+// too long are forgotten.
+func (ep *EthTxParser) promotePending(txs []EthTransaction) {
+	now := time.Now()
+	ep.pendingMx.Lock()
+	defer ep.pendingMx.Unlock()
+	if len(ep.pending) == 0 {
+		return
+	}
+	byNonce := make(map[string]*PendingTransaction)
+	for _, p := range ep.pending {
+		if p.Status == PendingStatusPending {
+			byNonce[strings.ToLower(p.From)+"/"+p.Nonce] = p
+		}
+	}
+	for _, tx := range txs {
+		if p, ok := ep.pending[tx.Hash]; ok {
+			if p.Status != PendingStatusMined {
+				p.Status, p.UpdatedAt, p.ReplacedBy = PendingStatusMined, now, ""
+				p.BlockHash, p.BlockNumber = tx.BlockHash, tx.BlockNumber
+			}
+			continue
+		}
+		if p, ok := byNonce[strings.ToLower(tx.From)+"/"+tx.Nonce]; ok {
+			p.Status, p.UpdatedAt, p.ReplacedBy = PendingStatusReplaced, now, tx.Hash
+		}
+	}
+	for hash, p := range ep.pending {
+		switch {
+		case p.Status == PendingStatusPending && now.Sub(p.FirstSeen) > ep.pendingTimeout:
+			p.Status, p.UpdatedAt = PendingStatusDropped, now
+		case p.Status != PendingStatusPending && now.Sub(p.UpdatedAt) > ep.pendingTimeout:
+			delete(ep.pending, hash)
+		}
+	}
+}
+
+// GetPendingTransactions returns the pending transactions sent or received by an address, along
+// with the recently mined, replaced or dropped ones, oldest first.
+func (ep *EthTxParser) GetPendingTransactions(address string) ([]PendingTransaction, error) {
+	addr, ok := ep.tracked(address)
+	if !ok {
+		return nil, ErrAddressNotTracked
+	}
+	ep.pendingMx.Lock()
+	defer ep.pendingMx.Unlock()
+	res := []PendingTransaction{}
+	for _, p := range ep.pending {
+		if strings.ToLower(p.From) == addr || strings.ToLower(p.To) == addr {
+			res = append(res, *p)
+		}
+	}
+	slices.SortFunc(res, func(a, b PendingTransaction) int {
+		return a.FirstSeen.Compare(b.FirstSeen)
+	})
+	return res, nil
+}
@@ -0,0 +1,163 @@
+package parser
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/pmes126/tx-parser-service/internal/rpc"
+	"github.com/pmes126/tx-parser-service/internal/store"
+)
+
+func TestEthTxParser_promotePending(t *testing.T) {
+	alice := "0xc0ffee254729296a45a3885639ac7e10f9d54979"
+	bob := "0x999999cf1046e68e36e1aa2e0e07105eddd1f08e"
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+	etp := NewEthTxParser(store.NewMemTxStore[EthTransaction](), &http.Client{}, logger, 0, WithPending(PendingModePoll, 0, time.Hour))
+	etp.Subscribe(alice)
+	etp.recordPending([]EthTransaction{
+		{Hash: "0x1", From: alice, To: bob, Nonce: "0x1"},
+		{Hash: "0x2", From: alice, To: bob, Nonce: "0x2"},
+		{Hash: "0x3", From: bob, To: alice, Nonce: "0x7"},
+		{Hash: "0x4", From: alice, To: bob, Nonce: "0x3"},
+		{Hash: "0x5", From: bob, To: bob, Nonce: "0x8"},
+	})
+	// 0x4 has been pending for longer than the timeout.
+	etp.pending["0x4"].FirstSeen = time.Now().Add(-2 * time.Hour)
+	etp.commitBlock(&blockData{number: 16, transactions: []EthTransaction{
+		{Hash: "0x1", From: alice, To: bob, Nonce: "0x1", BlockNumber: "0x10"},
+		{Hash: "0x6", From: alice, To: alice, Nonce: "0x2", BlockNumber: "0x10"},
+	}})
+
+	pending, err := etp.GetPendingTransactions(alice)
+	if err != nil {
+		t.Fatalf("EthTxParser.GetPendingTransactions() error = %v", err)
+	}
+	want := map[string]string{"0x1": PendingStatusMined, "0x2": PendingStatusReplaced, "0x3": PendingStatusPending, "0x4": PendingStatusDropped}
+	if len(pending) != len(want) {
+		t.Fatalf("EthTxParser.GetPendingTransactions() = %d transactions, want %d", len(pending), len(want))
+	}
+	for _, p := range pending {
+		if p.Status != want[p.Hash] {
+			t.Errorf("EthTxParser.GetPendingTransactions() %v status = %v, want %v", p.Hash, p.Status, want[p.Hash])
+		}
+	}
+	if p := etp.pending["0x1"]; p.BlockNumber != "0x10" {
+		t.Errorf("EthTxParser.promotePending() block number = %v, want %v", p.BlockNumber, "0x10")
+	}
+	if p := etp.pending["0x2"]; p.ReplacedBy != "0x6" {
+		t.Errorf("EthTxParser.promotePending() replaced by = %v, want %v", p.ReplacedBy, "0x6")
+	}
+	if _, err := etp.GetPendingTransactions(bob); err != ErrAddressNotTracked {
+		t.Errorf("EthTxParser.GetPendingTransactions() error = %v, want %v", err, ErrAddressNotTracked)
+	}
+
+	// the resolved transactions are forgotten after the timeout.
+	etp.pending["0x1"].UpdatedAt = time.Now().Add(-2 * time.Hour)
+	etp.promotePending(nil)
+	if _, ok := etp.pending["0x1"]; ok {
+		t.Errorf("EthTxParser.promotePending() kept a transaction mined for longer than the timeout")
+	}
+}
+
+func TestEthTxParser_pollPending(t *testing.T) {
+	alice := "0xc0ffee254729296a45a3885639ac7e10f9d54979"
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req rpc.Request
+		json.NewDecoder(r.Body).Decode(&req)
+		if req.Method != GetCurrentBlockByNumber || req.Params[0] != PendingBlockParam {
+			t.Errorf("%v(%v), want the pending block", req.Method, req.Params)
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"jsonrpc": rpc.Version, "id": req.Id, "result": EthBlock{
+			Transactions: []EthTransaction{{Hash: "0x1", From: "0x456", To: alice}, {Hash: "0x2", From: "0x456", To: "0x789"}},
+		}})
+	}))
+	defer srv.Close()
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+	etp := NewEthTxParser(store.NewMemTxStore[EthTransaction](), &http.Client{}, logger, 0,
+		WithEndpoints(srv.URL),
+		WithPending(PendingModeSubscribe, 10*time.Millisecond, 0),
+	)
+	etp.Subscribe(alice)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		etp.watchPending(ctx)
+	}()
+	deadline := time.Now().Add(5 * time.Second)
+	for pending, _ := etp.GetPendingTransactions(alice); len(pending) == 0; pending, _ = etp.GetPendingTransactions(alice) {
+		if time.Now().After(deadline) {
+			t.Fatalf("EthTxParser.watchPending() recorded no pending transaction")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	cancel()
+	<-done
+	pending, _ := etp.GetPendingTransactions(alice)
+	if len(pending) != 1 || pending[0].Hash != "0x1" || pending[0].Status != PendingStatusPending {
+		t.Errorf("EthTxParser.GetPendingTransactions() = %+v, want 0x1 pending", pending)
+	}
+}
+
+func TestEthTxParser_fetchPending(t *testing.T) {
+	alice := "0xc0ffee254729296a45a3885639ac7e10f9d54979"
+	var requests atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		var reqs []rpc.Request
+		json.NewDecoder(r.Body).Decode(&reqs)
+		var resps []map[string]interface{}
+		for _, req := range reqs {
+			resp := map[string]interface{}{"jsonrpc": rpc.Version, "id": req.Id, "result": nil}
+			// 0x2 is mined or dropped already.
+			switch hash := req.Params[0].(string); hash {
+			case "0x1":
+				resp["result"] = EthTransaction{Hash: hash, From: "0x456", To: alice}
+			case "0x3":
+				resp["result"] = EthTransaction{Hash: hash, From: "0x456", To: "0x789"}
+			}
+			resps = append(resps, resp)
+		}
+		json.NewEncoder(w).Encode(resps)
+	}))
+	defer srv.Close()
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+	etp := NewEthTxParser(store.NewMemTxStore[EthTransaction](), &http.Client{}, logger, 0,
+		WithEndpoints(srv.URL),
+		WithBatch(10, 0),
+	)
+	etp.Subscribe(alice)
+	hashes := make(chan string, pendingFetchQueueSize)
+	for _, hash := range []string{"0x1", "0x2", "0x3"} {
+		hashes <- hash
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		etp.fetchPending(ctx, hashes)
+	}()
+	deadline := time.Now().Add(5 * time.Second)
+	for pending, _ := etp.GetPendingTransactions(alice); len(pending) == 0; pending, _ = etp.GetPendingTransactions(alice) {
+		if time.Now().After(deadline) {
+			t.Fatalf("EthTxParser.fetchPending() recorded no pending transaction")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	cancel()
+	<-done
+	pending, _ := etp.GetPendingTransactions(alice)
+	if len(pending) != 1 || pending[0].Hash != "0x1" {
+		t.Errorf("EthTxParser.GetPendingTransactions() = %+v, want 0x1", pending)
+	}
+	if requests.Load() != 1 {
+		t.Errorf("EthTxParser.fetchPending() = %d requests, want a single batch", requests.Load())
+	}
+}
@@ -0,0 +1,211 @@
+package parser
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/pmes126/tx-parser-service/internal/conc"
+	"github.com/pmes126/tx-parser-service/internal/store"
+)
+
+// pipeline is the block processing shared by the parsers. The blocks up to the head of the chain
+// are scheduled into the lanes of a worker pool, fetched in parallel and committed in block order
+// by a sequencer. A block failing all its retries holds back the blocks after it, so it is requeued
+// into the replay lane until it succeeds, only the blocks failing with a permanent error being
+// kept in the dead-letter queue of the pool. D is the data fetched for a block.
+type pipeline[D any] struct {
+	wp           *conc.WorkerPool[int64, D]
+	seq          *sequencer[D]
+	cursorStore  store.CursorStore
+	fetch        func(ctx context.Context, number int64) (D, error)
+	drainTimeout time.Duration
+	logger       *slog.Logger
+	// maxAhead is the number of blocks scheduled past the last committed block.
+	maxAhead int64
+	// onStart is called with the block before the first one processed, once it is known.
+	onStart func(block int64)
+	// onProcessed is called with every block processed successfully.
+	onProcessed func(res conc.Result[int64, D])
+	// lastBlock is the last block scheduled, along with all the blocks before it. The live blocks
+	// pushed ahead of the backfill are in live, up to liveBlock.
+	lastBlock int64
+	live      []blockRange
+	liveBlock int64
+}
+
+// pipelineConfig is the configuration of the worker pool and the sequencer of a pipeline.
+type pipelineConfig struct {
+	cursorStore  store.CursorStore
+	workers      int
+	queueSize    int
+	queuePolicy  QueuePolicy
+	retryPolicy  conc.RetryPolicy
+	drainTimeout time.Duration
+}
+
+// newPipeline creates a pipeline fetching the blocks with fetch and committing them with commit.
+func newPipeline[D any](cfg pipelineConfig, logger *slog.Logger, fetch func(ctx context.Context, number int64) (D, error), commit func(D) error) *pipeline[D] {
+	p := &pipeline[D]{
+		cursorStore:  cfg.cursorStore,
+		fetch:        fetch,
+		drainTimeout: cfg.drainTimeout,
+		logger:       logger,
+		maxAhead:     MaxBlocksAhead,
+	}
+	p.seq = newSequencer(cfg.cursorStore, commit, p.requeue)
+	retryPolicy := cfg.retryPolicy
+	retryPolicy.Requeue = true
+	p.wp = conc.NewWorkerPool(cfg.workers, p.process, cfg.queueSize,
+		conc.WithRetryPolicy(retryPolicy),
+		conc.WithQueuePolicy(cfg.queuePolicy.conc()),
+		conc.WithLanes(lanes...),
+		conc.WithReplayLane(LaneReplay),
+	)
+	return p
+}
+
+// start resumes after the cursor, if any, and starts the workers. The returned function drains the
+// queued blocks within the drain timeout.
+func (p *pipeline[D]) start(ctx context.Context) (drain func()) {
+	cursor, err := p.cursorStore.GetCursor()
+	if err != nil {
+		p.logger.Error("Error getting cursor, starting from the latest block", slog.String("error", err.Error()))
+	} else if cursor > 0 {
+		p.logger.Info("Resuming block processing", slog.Int64("cursor", cursor))
+		p.resetTo(cursor)
+	}
+
+	// the workers outlive ctx so that the queued blocks can be drained on shutdown, their requests
+	// are cancelled once the drain timeout expires.
+	resChan := p.wp.Start(context.WithoutCancel(ctx))
+
+	// results are drained on their own so that a full queue never blocks the workers.
+	go func() {
+		for res := range resChan {
+			if res.Err != nil {
+				p.logger.Error("Error processing block transactions", slog.Int64("block id", res.Task), slog.Duration("duration", res.Duration), slog.String("error", res.Err.Error()))
+				continue
+			}
+			if p.onProcessed != nil {
+				p.onProcessed(res)
+			}
+		}
+	}()
+
+	return func() {
+		dctx, cancel := context.WithTimeout(context.Background(), p.drainTimeout)
+		defer cancel()
+		p.logger.Info("Draining queued blocks", slog.Int("queued", p.wp.QueueStats().Depth))
+		if err := p.wp.Shutdown(dctx); err != nil {
+			p.logger.Error("Error draining queued blocks", slog.String("error", err.Error()))
+		}
+	}
+}
+
+// resetTo starts processing after block.
+func (p *pipeline[D]) resetTo(block int64) {
+	p.lastBlock = block
+	if err := p.seq.reset(block); err != nil {
+		p.logger.Error("Error setting cursor", slog.String("error", err.Error()))
+	}
+	if p.onStart != nil {
+		p.onStart(block)
+	}
+}
+
+// schedule pushes the blocks after the last scheduled one up to latestBlock into the worker pool.
+// The newest LiveWindow blocks are pushed into the live lane first so that they are not queued
+// behind the backfill, then the blocks before them into the backfill lane while it has room.
+// Blocks rejected by a full queue, or more than maxAhead blocks past the last committed block, are
+// left for the next tick.
+func (p *pipeline[D]) schedule(ctx context.Context, latestBlock int64) {
+	if latestBlock <= p.lastBlock {
+		return
+	}
+	// process the latest block on startup.
+	if p.lastBlock == 0 {
+		p.resetTo(latestBlock - 1)
+	}
+	latestBlock = min(latestBlock, p.seq.committed()+p.maxAhead)
+	if latestBlock <= p.lastBlock {
+		return
+	}
+	from := max(p.lastBlock, p.liveBlock, latestBlock-LiveWindow) + 1
+	if len(p.live) == 0 || from > p.liveBlock+1 {
+		// the head moved past the live blocks, the ones in between are backfilled.
+		if n := len(p.live); n > 0 && p.live[n-1].to < p.live[n-1].from {
+			p.live = p.live[:n-1]
+		}
+		p.live = append(p.live, blockRange{from: from, to: from - 1})
+	}
+	for i := from; i <= latestBlock; i++ {
+		if err := p.wp.PushTaskTo(ctx, LaneLive, i); err != nil {
+			p.logger.Warn("Error scheduling block", slog.Int64("block id", i), slog.String("error", err.Error()))
+			break
+		}
+		p.liveBlock = i
+		p.live[len(p.live)-1].to = i
+	}
+	// lastBlock only advances past contiguous scheduled blocks, skipping the live ones.
+	defer p.pruneLive()
+	for i := p.lastBlock + 1; i <= p.liveBlock; i++ {
+		if !p.isLive(i) {
+			if p.wp.Available(LaneBackfill) == 0 {
+				return
+			}
+			if err := p.wp.PushTaskTo(ctx, LaneBackfill, i); err != nil {
+				p.logger.Warn("Error scheduling block", slog.Int64("block id", i), slog.String("error", err.Error()))
+				return
+			}
+		}
+		p.lastBlock = i
+	}
+}
+
+// blockRange is a range of blocks, from and to included.
+type blockRange struct {
+	from, to int64
+}
+
+// isLive reports whether a block has been pushed into the live lane.
+func (p *pipeline[D]) isLive(block int64) bool {
+	for _, r := range p.live {
+		if block >= r.from && block <= r.to {
+			return true
+		}
+	}
+	return false
+}
+
+// pruneLive forgets the ranges of live blocks the backfill has moved past.
+func (p *pipeline[D]) pruneLive() {
+	live := p.live[:0]
+	for _, r := range p.live {
+		if r.to > p.lastBlock {
+			live = append(live, r)
+		}
+	}
+	p.live = live
+}
+
+// process is the job of the worker pool. It fetches a block then hands it to the sequencer, which
+// commits it in block order. Everything is fetched before the block is committed, so that the
+// commits, serialised by the sequencer, do not wait for the node.
+func (p *pipeline[D]) process(ctx context.Context, number int64) (D, error) {
+	data, err := p.fetch(ctx, number)
+	if err != nil {
+		return data, err
+	}
+	return data, p.seq.complete(number, data)
+}
+
+// requeue pushes a block whose commit failed back into the replay lane, without blocking the worker
+// that committed it.
+func (p *pipeline[D]) requeue(block int64) {
+	go func() {
+		if err := p.wp.PushTaskTo(context.Background(), LaneReplay, block); err != nil {
+			p.logger.Error("Error requeuing block", slog.Int64("block id", block), slog.String("error", err.Error()))
+		}
+	}()
+}
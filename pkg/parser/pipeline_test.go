@@ -0,0 +1,135 @@
+package parser
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"os"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pmes126/tx-parser-service/internal/conc"
+	"github.com/pmes126/tx-parser-service/internal/store"
+)
+
+// newTestPipeline creates a pipeline whose blocks are counted in processed instead of fetched.
+func newTestPipeline(queueSize int, mx *sync.Mutex, processed map[int64]int) *pipeline[int64] {
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+	return newPipeline(pipelineConfig{
+		cursorStore: store.NewMemCursorStore(),
+		workers:     1,
+		queueSize:   queueSize,
+		queuePolicy: QueueBlock,
+	}, logger, func(ctx context.Context, number int64) (int64, error) {
+		mx.Lock()
+		defer mx.Unlock()
+		processed[number]++
+		return number, nil
+	}, func(int64) error { return nil })
+}
+
+func TestPipeline_schedule(t *testing.T) {
+	var mx sync.Mutex
+	p := newTestPipeline(4, &mx, map[int64]int{})
+	// resuming 10 blocks behind the head, the pool is not started so the lanes fill up.
+	p.lastBlock = 100
+	p.schedule(context.Background(), 110)
+	depths := map[string]int{}
+	for _, lane := range p.wp.QueueStats().Lanes {
+		depths[lane.Name] = lane.Depth
+	}
+	if depths[LaneLive] != LiveWindow || depths[LaneBackfill] != 4 || depths[LaneReplay] != 0 {
+		t.Errorf("pipeline.schedule() lane depths = %v, want %d live and 4 backfill", depths, LiveWindow)
+	}
+	// blocks 101 to 104 are backfilled, 105 and 106 wait for room in the backfill lane.
+	if p.lastBlock != 104 || !reflect.DeepEqual(p.live, []blockRange{{107, 110}}) || p.liveBlock != 110 {
+		t.Errorf("pipeline.schedule() lastBlock = %v, live = %v, want 104, [{107 110}]", p.lastBlock, p.live)
+	}
+}
+
+func TestPipeline_scheduleHeadJump(t *testing.T) {
+	var mx sync.Mutex
+	processed := map[int64]int{}
+	p := newTestPipeline(8, &mx, processed)
+	// the backfill lane fills up, then the head jumps while blocks 99 to 106 are still to backfill.
+	p.lastBlock = 90
+	p.schedule(context.Background(), 110)
+	p.schedule(context.Background(), 120)
+	if !reflect.DeepEqual(p.live, []blockRange{{107, 110}, {117, 120}}) {
+		t.Errorf("pipeline.schedule() live = %v, want [{107 110} {117 120}]", p.live)
+	}
+	res := p.wp.Start(context.Background())
+	go func() {
+		for range res {
+		}
+	}()
+	deadline := time.Now().Add(5 * time.Second)
+	for p.lastBlock < 120 {
+		if time.Now().After(deadline) {
+			t.Fatalf("pipeline.schedule() lastBlock = %v, want 120", p.lastBlock)
+		}
+		time.Sleep(time.Millisecond)
+		p.schedule(context.Background(), 120)
+	}
+	if err := p.wp.Shutdown(context.Background()); err != nil {
+		t.Fatalf("WorkerPool.Shutdown() error = %v", err)
+	}
+	for i := int64(91); i <= 120; i++ {
+		if processed[i] != 1 {
+			t.Errorf("pipeline.schedule() block %d scheduled %d times, want 1", i, processed[i])
+		}
+	}
+	if len(p.live) != 0 {
+		t.Errorf("pipeline.schedule() live = %v, want none", p.live)
+	}
+}
+
+func TestPipeline_scheduleMaxAhead(t *testing.T) {
+	var mx sync.Mutex
+	p := newTestPipeline(8, &mx, map[int64]int{})
+	p.resetTo(100)
+	p.maxAhead = 3
+	p.schedule(context.Background(), 110)
+	if p.lastBlock != 103 || p.liveBlock != 103 {
+		t.Errorf("pipeline.schedule() lastBlock = %v, liveBlock = %v, want 103", p.lastBlock, p.liveBlock)
+	}
+}
+
+func TestPipeline_requeueExhausted(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+	var mx sync.Mutex
+	attempts := 0
+	cursor := store.NewMemCursorStore()
+	p := newPipeline(pipelineConfig{
+		cursorStore:  cursor,
+		workers:      2,
+		queueSize:    8,
+		retryPolicy:  conc.RetryPolicy{MaxAttempts: 2, BaseDelay: time.Millisecond, MaxDelay: time.Millisecond},
+		drainTimeout: time.Second,
+	}, logger, func(ctx context.Context, number int64) (int64, error) {
+		mx.Lock()
+		defer mx.Unlock()
+		// block 101 fails all of its attempts twice before the node recovers.
+		if number == 101 && attempts < 4 {
+			attempts++
+			return 0, errors.New("node unavailable")
+		}
+		return number, nil
+	}, func(int64) error { return nil })
+	p.resetTo(100)
+	drain := p.start(context.Background())
+	defer drain()
+	p.schedule(context.Background(), 104)
+	deadline := time.Now().Add(5 * time.Second)
+	for got, _ := cursor.GetCursor(); got != 104; got, _ = cursor.GetCursor() {
+		if time.Now().After(deadline) {
+			t.Fatalf("pipeline cursor = %v, want %v", got, 104)
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if n := len(p.wp.DeadLetters()); n != 0 {
+		t.Errorf("WorkerPool.DeadLetters() = %v, want %v", n, 0)
+	}
+}
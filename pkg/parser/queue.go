@@ -0,0 +1,80 @@
+package parser
+
+import (
+	"fmt"
+
+	"github.com/pmes126/tx-parser-service/internal/conc"
+)
+
+// QueuePolicy is the behaviour of the queue of blocks waiting for a worker when it is full.
+type QueuePolicy int
+
+const (
+	// QueueBlock waits for room in the queue.
+	QueueBlock QueuePolicy = iota
+	// QueueReject leaves the blocks that do not fit in the queue for the next tick.
+	QueueReject
+)
+
+// ParseQueuePolicy parses the name of a queue policy: block or reject.
+func ParseQueuePolicy(name string) (QueuePolicy, error) {
+	switch name {
+	case "", "block":
+		return QueueBlock, nil
+	case "reject":
+		return QueueReject, nil
+	case "drop-oldest":
+		// the blocks are committed in order, evicting the oldest queued block would stall the cursor.
+		return QueueBlock, fmt.Errorf("queue policy %q is not supported by the parser, use %q or %q", name, QueueBlock, QueueReject)
+	}
+	return QueueBlock, fmt.Errorf("unknown queue policy %q", name)
+}
+
+func (p QueuePolicy) String() string {
+	if p == QueueReject {
+		return "reject"
+	}
+	return "block"
+}
+
+// conc returns the policy of the worker pool.
+func (p QueuePolicy) conc() conc.QueuePolicy {
+	if p == QueueReject {
+		return conc.QueueReject
+	}
+	return conc.QueueBlock
+}
+
+// QueueStats is a snapshot of the state of the queue of blocks waiting for a worker.
+type QueueStats struct {
+	Depth    int         `json:"depth"`
+	Capacity int         `json:"capacity"`
+	Dropped  int64       `json:"dropped"`
+	Policy   string      `json:"policy"`
+	Workers  int         `json:"workers"`
+	Lanes    []LaneStats `json:"lanes"`
+}
+
+// LaneStats is the state of a lane of the queue, LaneLive, LaneBackfill or LaneReplay.
+type LaneStats struct {
+	Name     string `json:"name"`
+	Weight   int    `json:"weight"`
+	Depth    int    `json:"depth"`
+	Capacity int    `json:"capacity"`
+}
+
+// queueStats converts the queue stats of a worker pool of blocks.
+func queueStats(s conc.QueueStats) QueueStats {
+	res := QueueStats{
+		Depth:    s.Depth,
+		Capacity: s.Capacity,
+		Dropped:  s.Dropped,
+		Policy:   s.Policy,
+		Workers:  s.Workers,
+		Lanes:    make([]LaneStats, len(s.Lanes)),
+	}
+	for i, lane := range s.Lanes {
+		res.Lanes[i] = LaneStats(lane)
+	}
+	return res
+}
@@ -0,0 +1,31 @@
+package parser
+
+import (
+	"testing"
+)
+
+func TestParseQueuePolicy(t *testing.T) {
+	tests := []struct {
+		name    string
+		policy  string
+		want    QueuePolicy
+		wantErr bool
+	}{
+		{name: "Test default", policy: "", want: QueueBlock},
+		{name: "Test block", policy: "block", want: QueueBlock},
+		{name: "Test reject", policy: "reject", want: QueueReject},
+		{name: "Test drop-oldest", policy: "drop-oldest", wantErr: true},
+		{name: "Test unknown", policy: "drop-newest", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseQueuePolicy(tt.policy)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseQueuePolicy() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("ParseQueuePolicy() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
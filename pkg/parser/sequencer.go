@@ -0,0 +1,92 @@
+package parser
+
+import (
+	"sync"
+
+	"github.com/pmes126/tx-parser-service/internal/store"
+)
+
+// sequencer commits the blocks fetched in parallel by the workers strictly in block order. A
+// fetched block waits until all the blocks before it are committed, so the cursor only ever
+// advances past contiguous committed blocks and a failed block holds back the ones after it
+// until it is retried or replayed. D is the data fetched for a block.
+type sequencer[D any] struct {
+	cursor  store.CursorStore
+	commit  func(D) error
+	requeue func(number int64)
+	next    int64
+	pending map[int64]D
+	mx      sync.Mutex
+}
+
+// newSequencer creates a sequencer committing the blocks with commit. requeue schedules a block
+// again when its commit fails while another block is being completed.
+func newSequencer[D any](cursor store.CursorStore, commit func(D) error, requeue func(number int64)) *sequencer[D] {
+	return &sequencer[D]{
+		cursor:  cursor,
+		commit:  commit,
+		requeue: requeue,
+		pending: make(map[int64]D),
+	}
+}
+
+// reset sets the last committed block, dropping the blocks waiting to be committed.
+func (s *sequencer[D]) reset(block int64) error {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	s.next = block + 1
+	s.pending = make(map[int64]D)
+	return s.cursor.SetCursor(block)
+}
+
+// complete queues the fetched data of a block and commits every contiguous block from the
+// cursor on. Blocks at or before the cursor have already been committed and are ignored. A commit
+// error is only returned for the completed block: a later block failing to commit is dropped and
+// requeued, so that it is fetched again and its error goes through its own retries.
+func (s *sequencer[D]) complete(number int64, data D) error {
+	failed := int64(-1)
+	defer func() {
+		if failed >= 0 {
+			s.requeue(failed)
+		}
+	}()
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	if number < s.next {
+		return nil
+	}
+	s.pending[number] = data
+	for {
+		next, ok := s.pending[s.next]
+		if !ok {
+			return nil
+		}
+		if err := s.commit(next); err != nil {
+			if s.next == number {
+				return err
+			}
+			failed = s.next
+			delete(s.pending, s.next)
+			return nil
+		}
+		if err := s.cursor.SetCursor(s.next); err != nil {
+			return err
+		}
+		delete(s.pending, s.next)
+		s.next++
+	}
+}
+
+// committed returns the last committed block.
+func (s *sequencer[D]) committed() int64 {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	return s.next - 1
+}
+
+// waiting returns the number of fetched blocks waiting for an earlier block to be committed.
+func (s *sequencer[D]) waiting() int {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	return len(s.pending)
+}
@@ -0,0 +1,96 @@
+package parser
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/pmes126/tx-parser-service/internal/store"
+)
+
+func TestSequencer_Complete(t *testing.T) {
+	var committed []int64
+	failing := map[int64]bool{}
+	commit := func(data *blockData) error {
+		if failing[data.number] {
+			return errors.New("commit failed")
+		}
+		committed = append(committed, data.number)
+		return nil
+	}
+	cursor := store.NewMemCursorStore()
+	seq := newSequencer(cursor, commit, func(int64) { t.Error("sequencer requeued a block") })
+	if err := seq.reset(9); err != nil {
+		t.Fatal(err)
+	}
+
+	steps := []struct {
+		name       string
+		block      int64
+		fail       bool
+		wantErr    bool
+		wantCursor int64
+		wantWait   int
+	}{
+		{name: "Test out of order block waits", block: 12, wantCursor: 9, wantWait: 1},
+		{name: "Test next block commits", block: 10, wantCursor: 10, wantWait: 1},
+		{name: "Test gap filled commits contiguous blocks", block: 11, wantCursor: 12, wantWait: 0},
+		{name: "Test committed block ignored", block: 11, wantCursor: 12, wantWait: 0},
+		{name: "Test failed commit holds the cursor", block: 13, fail: true, wantErr: true, wantCursor: 12, wantWait: 1},
+		{name: "Test retried commit advances", block: 13, wantCursor: 13, wantWait: 0},
+	}
+	for _, st := range steps {
+		t.Run(st.name, func(t *testing.T) {
+			failing[st.block] = st.fail
+			if err := seq.complete(st.block, &blockData{number: st.block}); (err != nil) != st.wantErr {
+				t.Fatalf("sequencer.complete() error = %v, wantErr %v", err, st.wantErr)
+			}
+			if got, _ := cursor.GetCursor(); got != st.wantCursor {
+				t.Errorf("sequencer cursor = %v, want %v", got, st.wantCursor)
+			}
+			if got := seq.waiting(); got != st.wantWait {
+				t.Errorf("sequencer.waiting() = %v, want %v", got, st.wantWait)
+			}
+		})
+	}
+	want := []int64{10, 11, 12, 13}
+	if len(committed) != len(want) {
+		t.Fatalf("sequencer committed = %v, want %v", committed, want)
+	}
+	for i := range want {
+		if committed[i] != want[i] {
+			t.Errorf("sequencer committed = %v, want %v", committed, want)
+		}
+	}
+}
+
+func TestSequencer_CompleteRequeue(t *testing.T) {
+	failing := map[int64]bool{11: true}
+	commit := func(data *blockData) error {
+		if failing[data.number] {
+			return errors.New("commit failed")
+		}
+		return nil
+	}
+	var requeued []int64
+	cursor := store.NewMemCursorStore()
+	seq := newSequencer(cursor, commit, func(block int64) { requeued = append(requeued, block) })
+	seq.reset(9)
+	seq.complete(11, &blockData{number: 11})
+	// block 11 fails while block 10 is completed, its own task retries it.
+	if err := seq.complete(10, &blockData{number: 10}); err != nil {
+		t.Errorf("sequencer.complete() error = %v, want the error of block 11 not returned for block 10", err)
+	}
+	if got, _ := cursor.GetCursor(); got != 10 || seq.waiting() != 0 || len(requeued) != 1 || requeued[0] != 11 {
+		t.Fatalf("sequencer cursor = %v, waiting %v, requeued %v, want 10, 0, [11]", got, seq.waiting(), requeued)
+	}
+	if err := seq.complete(11, &blockData{number: 11}); err == nil {
+		t.Errorf("sequencer.complete() error = nil, want the error of block 11")
+	}
+	failing[11] = false
+	if err := seq.complete(11, &blockData{number: 11}); err != nil {
+		t.Errorf("sequencer.complete() error = %v", err)
+	}
+	if got, _ := cursor.GetCursor(); got != 11 {
+		t.Errorf("sequencer cursor = %v, want %v", got, 11)
+	}
+}
@@ -0,0 +1,211 @@
+package parser
+
+import (
+	"errors"
+	"math/big"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrStatsNotTracked is returned for the statistics of an address when they are not tracked.
+var ErrStatsNotTracked = errors.New("statistics not tracked")
+
+// AddressStats are the aggregates of the transactions of a tracked address since it is tracked,
+// the values in the smallest unit of the chain.
+type AddressStats struct {
+	Address  string `json:"address"`
+	TotalIn  string `json:"totalIn"`
+	TotalOut string `json:"totalOut"`
+	// FeesPaid are the fees of the transactions sent by the address, when known.
+	FeesPaid             string `json:"feesPaid"`
+	TxCount              int    `json:"txCount"`
+	InCount              int    `json:"inCount"`
+	OutCount             int    `json:"outCount"`
+	FirstSeenBlock       int64  `json:"firstSeenBlock"`
+	LastSeenBlock        int64  `json:"lastSeenBlock"`
+	UniqueCounterparties int    `json:"uniqueCounterparties"`
+	// Volume is the value received and sent per time bucket, oldest first.
+	Volume []VolumeBucket `json:"volume"`
+}
+
+// VolumeBucket is the value received and sent by an address in the bucket starting at Start.
+type VolumeBucket struct {
+	Start   time.Time `json:"start"`
+	In      string    `json:"in"`
+	Out     string    `json:"out"`
+	TxCount int       `json:"txCount"`
+}
+
+// addressStats are the running aggregates of an address.
+type addressStats struct {
+	in, out, fees                 *big.Int
+	txCount, inCount, outCount    int
+	firstSeenBlock, lastSeenBlock int64
+	counterparties                map[string]bool
+	buckets                       map[int64]*bucketStats
+}
+
+type bucketStats struct {
+	in, out *big.Int
+	txCount int
+}
+
+// statsCollector maintains the statistics of the tracked addresses as their transactions are
+// committed, so that they are never computed from the stores.
+type statsCollector struct {
+	bucket time.Duration
+	stats  map[string]*addressStats
+	mx     sync.Mutex
+}
+
+// newStatsCollector creates a statsCollector with volume buckets of the given duration, nil when
+// it is not positive.
+func newStatsCollector(bucket time.Duration) *statsCollector {
+	if bucket <= 0 {
+		return nil
+	}
+	return &statsCollector{
+		bucket: bucket,
+		stats:  make(map[string]*addressStats),
+	}
+}
+
+// add accounts a committed transaction to the statistics of its tracked addresses. The fee is
+// accounted to the addresses of its inputs, the ones paying it. Transactions without a timestamp
+// are left out of the volume buckets.
+func (c *statsCollector) add(tx Transaction, tracked map[string]bool) {
+	c.mx.Lock()
+	defer c.mx.Unlock()
+	fee, _ := new(big.Int).SetString(tx.Fee, 10)
+	for _, addr := range tx.Addresses() {
+		if !tracked[addr] {
+			continue
+		}
+		s := c.stats[addr]
+		if s == nil {
+			s = &addressStats{
+				in:             new(big.Int),
+				out:            new(big.Int),
+				fees:           new(big.Int),
+				firstSeenBlock: tx.BlockNumber,
+				counterparties: make(map[string]bool),
+				buckets:        make(map[int64]*bucketStats),
+			}
+			c.stats[addr] = s
+		}
+		in, out := transferred(tx.Outputs, addr), transferred(tx.Inputs, addr)
+		s.in.Add(s.in, in)
+		s.out.Add(s.out, out)
+		s.txCount++
+		if slices.ContainsFunc(tx.Outputs, func(t Transfer) bool { return t.Address == addr }) {
+			s.inCount++
+		}
+		if slices.ContainsFunc(tx.Inputs, func(t Transfer) bool { return t.Address == addr }) {
+			s.outCount++
+			if fee != nil {
+				s.fees.Add(s.fees, fee)
+			}
+		}
+		s.firstSeenBlock = min(s.firstSeenBlock, tx.BlockNumber)
+		s.lastSeenBlock = max(s.lastSeenBlock, tx.BlockNumber)
+		for _, other := range tx.Addresses() {
+			if other != addr {
+				s.counterparties[other] = true
+			}
+		}
+		if tx.Timestamp == 0 {
+			continue
+		}
+		start := time.Unix(tx.Timestamp, 0).Truncate(c.bucket).Unix()
+		b := s.buckets[start]
+		if b == nil {
+			b = &bucketStats{in: new(big.Int), out: new(big.Int)}
+			s.buckets[start] = b
+		}
+		b.in.Add(b.in, in)
+		b.out.Add(b.out, out)
+		b.txCount++
+	}
+}
+
+// get returns the statistics of an address, zero if it has no transactions.
+func (c *statsCollector) get(addr string) AddressStats {
+	c.mx.Lock()
+	defer c.mx.Unlock()
+	res := AddressStats{Address: addr, TotalIn: "0", TotalOut: "0", FeesPaid: "0", Volume: []VolumeBucket{}}
+	s := c.stats[addr]
+	if s == nil {
+		return res
+	}
+	res.TotalIn, res.TotalOut, res.FeesPaid = s.in.String(), s.out.String(), s.fees.String()
+	res.TxCount, res.InCount, res.OutCount = s.txCount, s.inCount, s.outCount
+	res.FirstSeenBlock, res.LastSeenBlock = s.firstSeenBlock, s.lastSeenBlock
+	res.UniqueCounterparties = len(s.counterparties)
+	for start, b := range s.buckets {
+		res.Volume = append(res.Volume, VolumeBucket{Start: time.Unix(start, 0).UTC(), In: b.in.String(), Out: b.out.String(), TxCount: b.txCount})
+	}
+	slices.SortFunc(res.Volume, func(a, b VolumeBucket) int {
+		return a.Start.Compare(b.Start)
+	})
+	return res
+}
+
+// transferred returns the total value of the transfers of an address.
+func transferred(transfers []Transfer, addr string) *big.Int {
+	total := new(big.Int)
+	for _, t := range transfers {
+		if t.Address != addr {
+			continue
+		}
+		if v, ok := new(big.Int).SetString(t.Value, 10); ok {
+			total.Add(total, v)
+		}
+	}
+	return total
+}
+
+// WithStats maintains the statistics of the tracked addresses, with volume buckets of the given
+// duration. The fees paid are taken from the receipts of the transactions, fetched along with the
+// blocks. A bucket of 0 does not maintain statistics.
+func WithStats(bucket time.Duration) Option {
+	return func(ep *EthTxParser) {
+		ep.stats = newStatsCollector(bucket)
+	}
+}
+
+// updateStats accounts the transactions of the tracked addresses in a committed block, in the
+// chain agnostic model. A failed transaction only moves its fee.
+func (ep *EthTxParser) updateStats(data *blockData) {
+	ep.mx.RLock()
+	defer ep.mx.RUnlock()
+	for _, txs := range [][]EthTransaction{data.transactions, data.internal} {
+		for _, tx := range txs {
+			if !ep.addresses[strings.ToLower(tx.From)] && !ep.addresses[strings.ToLower(tx.To)] {
+				continue
+			}
+			t := tx.Normalise()
+			t.BlockNumber, t.Timestamp = data.number, data.timestamp
+			if receipt, ok := data.receipts[tx.Hash]; ok && tx.Kind != TxKindInternal {
+				t.Fee = receipt.Fee(tx.GasPrice).String()
+				if receipt.Status == "0x0" {
+					t.Inputs[0].Value, t.Outputs[0].Value = "0", "0"
+				}
+			}
+			ep.stats.add(t, ep.addresses)
+		}
+	}
+}
+
+// GetStats returns the statistics of an address.
+func (ep *EthTxParser) GetStats(address string) (AddressStats, error) {
+	addr, ok := ep.tracked(address)
+	if !ok {
+		return AddressStats{}, ErrAddressNotTracked
+	}
+	if ep.stats == nil {
+		return AddressStats{}, ErrStatsNotTracked
+	}
+	return ep.stats.get(addr), nil
+}
@@ -0,0 +1,121 @@
+package parser
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/pmes126/tx-parser-service/internal/store"
+)
+
+func TestEthTxParser_updateStats(t *testing.T) {
+	alice := "0xc0ffee254729296a45a3885639ac7e10f9d54979"
+	bob := "0x999999cf1046e68e36e1aa2e0e07105eddd1f08e"
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+	etp := NewEthTxParser(store.NewMemTxStore[EthTransaction](), &http.Client{}, logger, 0, WithStats(time.Hour))
+	etp.Subscribe(alice)
+	if _, err := etp.GetStats(bob); !errors.Is(err, ErrAddressNotTracked) {
+		t.Errorf("EthTxParser.GetStats() error = %v, want %v", err, ErrAddressNotTracked)
+	}
+
+	receipts := map[string]Receipt{
+		"0x1": {Status: "0x1", GasUsed: "0x2", EffectiveGasPrice: "0x5"},
+		"0x2": {Status: "0x0", GasUsed: "0x3", EffectiveGasPrice: "0x5"},
+		"0x3": {Status: "0x1", GasUsed: "0x1", EffectiveGasPrice: "0x1"},
+	}
+	etp.commitBlock(&blockData{number: 10, timestamp: 3600, receipts: receipts, transactions: []EthTransaction{
+		// alice sends 100 and pays 10 of fees.
+		{Hash: "0x1", BlockNumber: "0xa", From: alice, To: bob, Value: "0x64"},
+		// a failed transaction only costs its fees of 15.
+		{Hash: "0x2", BlockNumber: "0xa", From: alice, To: bob, Value: "0x64"},
+		// bob pays the fees of the transactions he sends.
+		{Hash: "0x3", BlockNumber: "0xa", From: bob, To: alice, Value: "0x32"},
+		{Hash: "0x4", BlockNumber: "0xa", From: bob, To: "0x456", Value: "0x32"},
+	}})
+	etp.commitBlock(&blockData{number: 12, timestamp: 7300, internal: []EthTransaction{
+		{Kind: TxKindInternal, Hash: "0x5", From: "0x456", To: alice, Value: "0x5"},
+	}})
+
+	got, err := etp.GetStats(alice)
+	if err != nil {
+		t.Fatalf("EthTxParser.GetStats() error = %v", err)
+	}
+	want := AddressStats{
+		Address: alice, TotalIn: "55", TotalOut: "100", FeesPaid: "25",
+		TxCount: 4, InCount: 2, OutCount: 2, FirstSeenBlock: 10, LastSeenBlock: 12, UniqueCounterparties: 2,
+		Volume: []VolumeBucket{
+			{Start: time.Unix(3600, 0).UTC(), In: "50", Out: "100", TxCount: 3},
+			{Start: time.Unix(7200, 0).UTC(), In: "5", Out: "0", TxCount: 1},
+		},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("EthTxParser.GetStats() = %+v, want %+v", got, want)
+	}
+}
+
+// failingTxStore fails the fails transactions added to it after the first after ones.
+type failingTxStore[T any] struct {
+	store.TxStore[T]
+	after int
+	fails int
+}
+
+func (s *failingTxStore[T]) AddTransaction(address string, tx T) error {
+	if s.after > 0 {
+		s.after--
+	} else if s.fails > 0 {
+		s.fails--
+		return errors.New("store unavailable")
+	}
+	return s.TxStore.AddTransaction(address, tx)
+}
+
+func TestEthTxParser_commitBlock_retry(t *testing.T) {
+	alice := "0xc0ffee254729296a45a3885639ac7e10f9d54979"
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+	txStore := &failingTxStore[EthTransaction]{TxStore: store.NewMemTxStore[EthTransaction](), fails: 1}
+	etp := NewEthTxParser(txStore, &http.Client{}, logger, 0, WithStats(time.Hour))
+	etp.Subscribe(alice)
+	data := &blockData{number: 10, timestamp: 3600, receipts: map[string]Receipt{"0x1": {Status: "0x1"}}, transactions: []EthTransaction{
+		{Hash: "0x1", BlockNumber: "0xa", From: "0x456", To: alice, Value: "0x64"},
+	}}
+	if err := etp.commitBlock(data); err == nil {
+		t.Fatalf("EthTxParser.commitBlock() error = %v, want an error", err)
+	}
+	if err := etp.commitBlock(data); err != nil {
+		t.Fatalf("EthTxParser.commitBlock() error = %v", err)
+	}
+	// the failed commit is not accounted.
+	got, _ := etp.GetStats(alice)
+	if got.TxCount != 1 || got.TotalIn != "100" {
+		t.Errorf("EthTxParser.GetStats() = %+v, want 1 transaction of 100", got)
+	}
+}
+
+func TestEthTxParser_commitBlock_retryNoDuplicates(t *testing.T) {
+	alice := "0xc0ffee254729296a45a3885639ac7e10f9d54979"
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+	// the transaction is written, then the store fails on its internal transaction.
+	txStore := &failingTxStore[EthTransaction]{TxStore: store.NewMemTxStore[EthTransaction](), after: 1, fails: 1}
+	etp := NewEthTxParser(txStore, &http.Client{}, logger, 0)
+	etp.Subscribe(alice)
+	data := &blockData{number: 10, transactions: []EthTransaction{
+		{Hash: "0x1", BlockNumber: "0xa", From: "0x456", To: alice, Value: "0x64"},
+	}, internal: []EthTransaction{
+		{Kind: TxKindInternal, Hash: "0x1", BlockNumber: "0xa", From: "0x456", To: alice, Value: "0x5", TraceAddress: []int{0}},
+	}}
+	if err := etp.commitBlock(data); err == nil {
+		t.Fatalf("EthTxParser.commitBlock() error = %v, want an error", err)
+	}
+	if err := etp.commitBlock(data); err != nil {
+		t.Fatalf("EthTxParser.commitBlock() error = %v", err)
+	}
+	got, _ := txStore.GetTransactions(alice)
+	if len(got) != 2 {
+		t.Errorf("EthTxParser.commitBlock() stored %d transactions, want %d", len(got), 2)
+	}
+}
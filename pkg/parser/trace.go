@@ -0,0 +1,202 @@
+package parser
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/pmes126/tx-parser-service/internal/rpc"
+)
+
+const (
+	DebugTraceBlockByNumber = "debug_traceBlockByNumber"
+	TraceBlock              = "trace_block"
+
+	// TraceModeDebug traces blocks with the geth callTracer.
+	TraceModeDebug = "debug"
+	// TraceModeTrace traces blocks with the parity/erigon trace module.
+	TraceModeTrace = "trace"
+)
+
+// CallFrame is a call of the geth callTracer output.
+type CallFrame struct {
+	Type    string      `json:"type"`
+	From    string      `json:"from"`
+	To      string      `json:"to"`
+	Value   string      `json:"value"`
+	Gas     string      `json:"gas"`
+	GasUsed string      `json:"gasUsed"`
+	Input   string      `json:"input"`
+	Error   string      `json:"error"`
+	Calls   []CallFrame `json:"calls"`
+}
+
+// TxCallFrame is the call tree of a transaction in the debug_traceBlockByNumber output.
+type TxCallFrame struct {
+	TxHash string    `json:"txHash"`
+	Result CallFrame `json:"result"`
+}
+
+// BlockTrace is a flattened call of the trace_block output.
+type BlockTrace struct {
+	Action struct {
+		CallType      string `json:"callType"`
+		From          string `json:"from"`
+		To            string `json:"to"`
+		Value         string `json:"value"`
+		Gas           string `json:"gas"`
+		Input         string `json:"input"`
+		Address       string `json:"address"`
+		RefundAddress string `json:"refundAddress"`
+		Balance       string `json:"balance"`
+	} `json:"action"`
+	Result *struct {
+		Address string `json:"address"`
+	} `json:"result"`
+	Error           string `json:"error"`
+	TraceAddress    []int  `json:"traceAddress"`
+	TransactionHash string `json:"transactionHash"`
+	Type            string `json:"type"`
+}
+
+// traceCall is the trace call of a block for the trace mode, along with the conversion of its
+// result to internal transactions once it is decoded.
+func (ep *EthTxParser) traceCall(blockNum int64) (rpc.BatchElem, func() []EthTransaction, error) {
+	block := fmt.Sprintf("0x%x", blockNum)
+	switch ep.traceMode {
+	case TraceModeDebug:
+		var traces []TxCallFrame
+		call := rpc.BatchElem{
+			Method: DebugTraceBlockByNumber,
+			Params: []interface{}{block, map[string]string{"tracer": "callTracer"}},
+			Result: &traces,
+		}
+		return call, func() []EthTransaction {
+			return internalTransactionsFromCallFrames(traces, block)
+		}, nil
+	case TraceModeTrace:
+		var traces []BlockTrace
+		call := rpc.BatchElem{Method: TraceBlock, Params: []interface{}{block}, Result: &traces}
+		return call, func() []EthTransaction {
+			return internalTransactionsFromTraces(traces, block)
+		}, nil
+	}
+	return rpc.BatchElem{}, nil, fmt.Errorf("unsupported trace mode %q", ep.traceMode)
+}
+
+// internalTransactionsFromCallFrames converts the value bearing calls of a debug_traceBlockByNumber
+// output. The callTracer only sets the error of a reverted transaction on its top level frame, so
+// the whole tree of a reverted transaction is skipped.
+func internalTransactionsFromCallFrames(traces []TxCallFrame, block string) []EthTransaction {
+	var txs []EthTransaction
+	for _, tx := range traces {
+		if tx.Result.Error != "" {
+			continue
+		}
+		// The top level frame is the external transaction itself.
+		for i, c := range tx.Result.Calls {
+			txs = appendCallFrames(txs, c, tx.TxHash, block, []int{i})
+		}
+	}
+	return txs
+}
+
+// appendCallFrames walks the call tree depth first, appending the value bearing calls.
+// Reverted calls and their children are skipped as they did not move any value.
+func appendCallFrames(txs []EthTransaction, call CallFrame, hash, block string, traceAddress []int) []EthTransaction {
+	if call.Error != "" {
+		return txs
+	}
+	if hasValue(call.Value) && call.Type != "DELEGATECALL" && call.Type != "STATICCALL" {
+		txs = append(txs, EthTransaction{
+			Kind:         TxKindInternal,
+			Hash:         hash,
+			BlockNumber:  block,
+			From:         call.From,
+			To:           call.To,
+			Value:        call.Value,
+			Input:        call.Input,
+			Gas:          call.Gas,
+			TraceAddress: traceAddress,
+		})
+	}
+	for i, c := range call.Calls {
+		child := append(append([]int{}, traceAddress...), i)
+		txs = appendCallFrames(txs, c, hash, block, child)
+	}
+	return txs
+}
+
+// internalTransactionsFromTraces converts the value bearing calls of a trace_block output.
+// Traces are ordered depth first, so a reverted call is always seen before its children.
+func internalTransactionsFromTraces(traces []BlockTrace, block string) []EthTransaction {
+	var txs []EthTransaction
+	reverted := make(map[string][]string)
+	for _, tr := range traces {
+		if len(tr.TraceAddress) == 0 {
+			// The top level trace is the external transaction itself.
+			if tr.Error != "" {
+				reverted[tr.TransactionHash] = append(reverted[tr.TransactionHash], "")
+			}
+			continue
+		}
+		path := traceAddressKey(tr.TraceAddress)
+		if isReverted(reverted[tr.TransactionHash], path) {
+			continue
+		}
+		if tr.Error != "" {
+			reverted[tr.TransactionHash] = append(reverted[tr.TransactionHash], path)
+			continue
+		}
+		tx := EthTransaction{
+			Kind:         TxKindInternal,
+			Hash:         tr.TransactionHash,
+			BlockNumber:  block,
+			TraceAddress: tr.TraceAddress,
+		}
+		switch tr.Type {
+		case "call":
+			if tr.Action.CallType == "delegatecall" || tr.Action.CallType == "staticcall" {
+				continue
+			}
+			tx.From, tx.To, tx.Value = tr.Action.From, tr.Action.To, tr.Action.Value
+			tx.Input, tx.Gas = tr.Action.Input, tr.Action.Gas
+		case "create":
+			tx.From, tx.Value, tx.Gas = tr.Action.From, tr.Action.Value, tr.Action.Gas
+			if tr.Result != nil {
+				tx.To = tr.Result.Address
+			}
+		case "suicide":
+			tx.From, tx.To, tx.Value = tr.Action.Address, tr.Action.RefundAddress, tr.Action.Balance
+		default:
+			continue
+		}
+		if hasValue(tx.Value) {
+			txs = append(txs, tx)
+		}
+	}
+	return txs
+}
+
+func traceAddressKey(traceAddress []int) string {
+	parts := make([]string, len(traceAddress))
+	for i, a := range traceAddress {
+		parts[i] = fmt.Sprint(a)
+	}
+	return strings.Join(parts, ".")
+}
+
+// isReverted reports whether the call at path is nested in one of the reverted paths.
+func isReverted(reverted []string, path string) bool {
+	for _, r := range reverted {
+		if r == "" || path == r || strings.HasPrefix(path, r+".") {
+			return true
+		}
+	}
+	return false
+}
+
+// hasValue reports whether a hex quantity is non zero.
+func hasValue(value string) bool {
+	v, ok := parseQuantity(value)
+	return ok && v.Sign() != 0
+}
@@ -0,0 +1,87 @@
+package parser
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestAppendCallFrames(t *testing.T) {
+	var frame CallFrame
+	err := json.Unmarshal([]byte(`{
+		"type": "CALL", "from": "0xaaaa", "to": "0xwallet", "value": "0x0",
+		"calls": [
+			{"type": "CALL", "from": "0xwallet", "to": "0xbbbb", "value": "0xde0b6b3a7640000"},
+			{"type": "DELEGATECALL", "from": "0xwallet", "to": "0xlib", "value": "0x1"},
+			{"type": "CALL", "from": "0xwallet", "to": "0xcccc", "value": "0x1", "error": "execution reverted",
+				"calls": [{"type": "CALL", "from": "0xcccc", "to": "0xdddd", "value": "0x1"}]},
+			{"type": "CALL", "from": "0xwallet", "to": "0xeeee", "value": "0x0",
+				"calls": [{"type": "CALL", "from": "0xeeee", "to": "0xffff", "value": "0x2"}]}
+		]
+	}`), &frame)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var txs []EthTransaction
+	for i, call := range frame.Calls {
+		txs = appendCallFrames(txs, call, "0x1", "0x10", []int{i})
+	}
+	want := []struct {
+		to           string
+		traceAddress string
+	}{
+		{to: "0xbbbb", traceAddress: "0"},
+		{to: "0xffff", traceAddress: "3.0"},
+	}
+	if len(txs) != len(want) {
+		t.Fatalf("appendCallFrames() = %v, want %v", len(txs), len(want))
+	}
+	for i, w := range want {
+		if txs[i].To != w.to || traceAddressKey(txs[i].TraceAddress) != w.traceAddress || txs[i].Kind != TxKindInternal {
+			t.Errorf("appendCallFrames()[%d] = %+v, want to %s at %s", i, txs[i], w.to, w.traceAddress)
+		}
+	}
+}
+
+func TestInternalTransactionsFromCallFrames(t *testing.T) {
+	var traces []TxCallFrame
+	err := json.Unmarshal([]byte(`[
+		{"txHash": "0x1", "result": {"type": "CALL", "from": "0xaaaa", "to": "0xwallet", "value": "0x0",
+			"calls": [{"type": "CALL", "from": "0xwallet", "to": "0xbbbb", "value": "0x5"}]}},
+		{"txHash": "0x2", "result": {"type": "CALL", "from": "0xaaaa", "to": "0xwallet", "value": "0x0", "error": "execution reverted",
+			"calls": [{"type": "CALL", "from": "0xwallet", "to": "0xcccc", "value": "0x5"}]}}
+	]`), &traces)
+	if err != nil {
+		t.Fatal(err)
+	}
+	txs := internalTransactionsFromCallFrames(traces, "0x10")
+	if len(txs) != 1 || txs[0].Hash != "0x1" || txs[0].To != "0xbbbb" {
+		t.Errorf("internalTransactionsFromCallFrames() = %+v, want the call to 0xbbbb of 0x1", txs)
+	}
+}
+
+func TestInternalTransactionsFromTraces(t *testing.T) {
+	var traces []BlockTrace
+	err := json.Unmarshal([]byte(`[
+		{"type": "call", "action": {"callType": "call", "from": "0xaaaa", "to": "0xwallet", "value": "0x0"}, "traceAddress": [], "transactionHash": "0x1"},
+		{"type": "call", "action": {"callType": "call", "from": "0xwallet", "to": "0xbbbb", "value": "0x5"}, "traceAddress": [0], "transactionHash": "0x1"},
+		{"type": "call", "action": {"callType": "call", "from": "0xwallet", "to": "0xcccc", "value": "0x5"}, "error": "Reverted", "traceAddress": [1], "transactionHash": "0x1"},
+		{"type": "call", "action": {"callType": "call", "from": "0xcccc", "to": "0xdddd", "value": "0x5"}, "traceAddress": [1, 0], "transactionHash": "0x1"},
+		{"type": "create", "action": {"from": "0xwallet", "value": "0x7"}, "result": {"address": "0xnew"}, "traceAddress": [2], "transactionHash": "0x1"},
+		{"type": "suicide", "action": {"address": "0xnew", "refundAddress": "0xwallet", "balance": "0x7"}, "traceAddress": [3], "transactionHash": "0x1"},
+		{"type": "call", "action": {"callType": "call", "from": "0xaaaa", "to": "0xbbbb", "value": "0x1"}, "error": "Reverted", "traceAddress": [], "transactionHash": "0x2"},
+		{"type": "call", "action": {"callType": "call", "from": "0xbbbb", "to": "0xcccc", "value": "0x1"}, "traceAddress": [0], "transactionHash": "0x2"}
+	]`), &traces)
+	if err != nil {
+		t.Fatal(err)
+	}
+	txs := internalTransactionsFromTraces(traces, "0x10")
+	wantTo := []string{"0xbbbb", "0xnew", "0xwallet"}
+	if len(txs) != len(wantTo) {
+		t.Fatalf("internalTransactionsFromTraces() = %v, want %v", len(txs), len(wantTo))
+	}
+	for i, to := range wantTo {
+		if txs[i].To != to {
+			t.Errorf("internalTransactionsFromTraces()[%d].To = %v, want %v", i, txs[i].To, to)
+		}
+	}
+}